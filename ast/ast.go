@@ -11,6 +11,7 @@ package ast
 import (
 	"bytes"
 	"interpreter/token2"
+	"math/big"
 	"strings"
 )
 
@@ -52,10 +53,16 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// LetStatement binds Name, or every name of Pattern for a destructuring let
+// such as `let [a, b] = pair;`. Exactly one of Name and Pattern is set.
+// `const x = 1;` is a LetStatement whose Token is the const token.
+// Type is the annotation of Name, `let x: int = 1;`, nil when absent.
 type LetStatement struct {
-	Token token2.Token // token.Let token
-	Name  *Identifier
-	Value Expression
+	Token   token2.Token // token.Let token
+	Name    *Identifier
+	Type    Type
+	Pattern Pattern
+	Value   Expression
 }
 
 func (ls *LetStatement) statementNode() {
@@ -72,7 +79,14 @@ func (ls *LetStatement) String() string {
 	}
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -84,6 +98,10 @@ func (ls *LetStatement) String() string {
 type Identifier struct {
 	Token token2.Token
 	Value string
+	// filled in by the resolver: the binding lives Depth environments out
+	// from the current one, at index Slot. Slot is -1 for builtins.
+	Depth int
+	Slot  int
 }
 
 func (i *Identifier) expressionNode() {
@@ -138,6 +156,8 @@ func (es *ExpressionStatement) String() string {
 type IntegerLiteral struct {
 	Token token2.Token
 	Value int64
+	// set instead of Value when the literal does not fit in int64
+	Big *big.Int
 }
 
 func (il *IntegerLiteral) TokenLiteral() string {
@@ -234,6 +254,7 @@ func (ie *IfExpression) String() string {
 type BlockStatement struct {
 	Token      token2.Token
 	Statements []Statement
+	End        token2.Token // the '}' token, unset for a match arm expression
 }
 
 func (bs *BlockStatement) statementNode() {}
@@ -249,10 +270,20 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
+// FunctionLiteral: fn(x, y = 10, ...rest) { body }
+// Defaults runs parallel to Parameters, a nil entry means no default value.
+// Rest collects the extra positional arguments, it is nil when absent.
+// ParameterTypes runs parallel to Parameters too, a nil entry means the
+// parameter is not annotated, and ReturnType is nil without `-> type`.
 type FunctionLiteral struct {
-	Token      token2.Token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Token          token2.Token
+	Name           string // set for a declaration, fn name() {}
+	Parameters     []*Identifier
+	ParameterTypes []Type
+	Defaults       []Expression
+	Rest           *Identifier
+	ReturnType     Type
+	Body           *BlockStatement
 }
 
 func (fl *FunctionLiteral) TokenLiteral() string {
@@ -264,18 +295,61 @@ func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
+	params := ParameterList(fl.Parameters, fl.ParameterTypes, fl.Defaults, fl.Rest)
 	out.WriteString(fl.TokenLiteral())
+	if fl.Name != "" {
+		out.WriteString(" " + fl.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ","))
 	out.WriteString(")")
+	if fl.ReturnType != nil {
+		out.WriteString(" -> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 	return out.String()
 }
 
+// FunctionDeclaration: fn name(params) { body }
+// Declarations are hoisted, name is bound before any other statement of the
+// enclosing block runs, so declared functions can call each other freely.
+type FunctionDeclaration struct {
+	Token    token2.Token // the 'fn' token
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (fd *FunctionDeclaration) statementNode() {}
+func (fd *FunctionDeclaration) TokenLiteral() string {
+	return fd.Token.Literal
+}
+func (fd *FunctionDeclaration) String() string {
+	return fd.Function.String()
+}
+
+// ParameterList renders each parameter of a function signature with its
+// annotation and default value, followed by the rest parameter. types may be
+// nil.
+func ParameterList(params []*Identifier, types []Type, defaults []Expression, rest *Identifier) []string {
+	list := []string{}
+	for i, p := range params {
+		text := p.String()
+		if i < len(types) && types[i] != nil {
+			text += ": " + types[i].String()
+		}
+		if i < len(defaults) && defaults[i] != nil {
+			text += " = " + defaults[i].String()
+		}
+		list = append(list, text)
+	}
+	if rest != nil {
+		list = append(list, "..."+rest.String())
+	}
+	return list
+}
+
+// CallExpression: the arguments may include SpreadExpressions and, after
+// the positional ones, KeywordArguments
 type CallExpression struct {
 	Token     token2.Token
 	Function  Expression
@@ -313,6 +387,29 @@ func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
 
+// InterpolatedString: "Hello ${name}!" is the concatenation of its parts,
+// string literals for the text and any expression for each ${...}
+type InterpolatedString struct {
+	Token token2.Token // the INTERP_START token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode() {}
+func (is *InterpolatedString) TokenLiteral() string {
+	return is.Token.Literal
+}
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+	for _, part := range is.Parts {
+		if literal, ok := part.(*StringLiteral); ok {
+			out.WriteString(literal.Value)
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token    token2.Token
 	Elements []Expression
@@ -363,6 +460,7 @@ func (ie *IndexExpression) expressionNode() {}
 type HashLiteral struct {
 	Token token2.Token
 	Pairs map[Expression]Expression
+	Keys  []Expression // the keys of Pairs in source order
 }
 
 func (hl *HashLiteral) TokenLiteral() string {
@@ -373,8 +471,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
@@ -384,3 +482,199 @@ func (hl *HashLiteral) String() string {
 }
 
 func (hl *HashLiteral) expressionNode() {}
+
+// SliceExpression: arr[start:end] or arr[start:end:step], every part is optional
+type SliceExpression struct {
+	Token token2.Token // the '[' token
+	Left  Expression
+	Start Expression
+	End   Expression
+	Step  Expression
+}
+
+func (se *SliceExpression) expressionNode() {}
+func (se *SliceExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
+// RangeExpression: 1..10 includes the end, 1..<10 excludes it
+type RangeExpression struct {
+	Token     token2.Token // the '..' or '..<' token
+	Start     Expression
+	End       Expression
+	Inclusive bool
+}
+
+func (re *RangeExpression) expressionNode() {}
+func (re *RangeExpression) TokenLiteral() string {
+	return re.Token.Literal
+}
+func (re *RangeExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(re.Start.String())
+	out.WriteString(re.Token.Literal)
+	out.WriteString(re.End.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+// SpreadExpression: f(...arr) passes the elements of arr as separate arguments
+type SpreadExpression struct {
+	Token token2.Token // the '...' token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode() {}
+func (se *SpreadExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+func (se *SpreadExpression) String() string {
+	return "..." + se.Value.String()
+}
+
+// KeywordArgument: f(y: 2) binds the argument to the parameter named y
+type KeywordArgument struct {
+	Token token2.Token // the parameter name
+	Name  string
+	Value Expression
+}
+
+func (ka *KeywordArgument) expressionNode() {}
+func (ka *KeywordArgument) TokenLiteral() string {
+	return ka.Token.Literal
+}
+func (ka *KeywordArgument) String() string {
+	return ka.Name + ": " + ka.Value.String()
+}
+
+// AssignExpression: x = value, the value of the expression is the value
+// assigned
+type AssignExpression struct {
+	Token  token2.Token // the '=' token
+	Target Expression
+	Value  Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AssignExpression) String() string {
+	return "(" + ae.Target.String() + " = " + ae.Value.String() + ")"
+}
+
+// StructStatement: struct Point { x, y } binds Name to a struct type
+type StructStatement struct {
+	Token  token2.Token // the 'struct' token
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ss *StructStatement) statementNode() {}
+func (ss *StructStatement) TokenLiteral() string {
+	return ss.Token.Literal
+}
+func (ss *StructStatement) String() string {
+	fields := []string{}
+	for _, field := range ss.Fields {
+		fields = append(fields, field.String())
+	}
+	return "struct " + ss.Name.String() + " { " + strings.Join(fields, ", ") + " }"
+}
+
+// ImplStatement: impl Point { fn norm(self) { ... } } adds methods to the
+// struct type bound to Name
+type ImplStatement struct {
+	Token   token2.Token // the 'impl' token
+	Name    *Identifier
+	Methods []*FunctionDeclaration
+}
+
+func (is *ImplStatement) statementNode() {}
+func (is *ImplStatement) TokenLiteral() string {
+	return is.Token.Literal
+}
+func (is *ImplStatement) String() string {
+	var out bytes.Buffer
+	methods := []string{}
+	for _, method := range is.Methods {
+		methods = append(methods, method.String())
+	}
+	out.WriteString("impl " + is.Name.String() + " { ")
+	out.WriteString(strings.Join(methods, " "))
+	out.WriteString(" }")
+	return out.String()
+}
+
+// DotExpression: p.x reads a field or looks up a method
+type DotExpression struct {
+	Token token2.Token // the '.' token
+	Left  Expression
+	Name  string
+}
+
+func (de *DotExpression) expressionNode() {}
+func (de *DotExpression) TokenLiteral() string {
+	return de.Token.Literal
+}
+func (de *DotExpression) String() string {
+	return "(" + de.Left.String() + "." + de.Name + ")"
+}
+
+// EnumStatement: enum Result { Ok(value), Err(msg), None } binds Name to the
+// enum type and every variant name to its constructor, or to its value when
+// the variant carries nothing
+type EnumStatement struct {
+	Token    token2.Token // the 'enum' token
+	Name     *Identifier
+	Variants []*EnumVariant
+}
+
+// EnumVariant is one variant of an enum, Fields name its positional payload
+type EnumVariant struct {
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (es *EnumStatement) statementNode() {}
+func (es *EnumStatement) TokenLiteral() string {
+	return es.Token.Literal
+}
+func (es *EnumStatement) String() string {
+	variants := []string{}
+	for _, variant := range es.Variants {
+		variants = append(variants, variant.String())
+	}
+	return "enum " + es.Name.String() + " { " + strings.Join(variants, ", ") + " }"
+}
+
+func (ev *EnumVariant) String() string {
+	if len(ev.Fields) == 0 {
+		return ev.Name.String()
+	}
+	fields := []string{}
+	for _, field := range ev.Fields {
+		fields = append(fields, field.String())
+	}
+	return ev.Name.String() + "(" + strings.Join(fields, ", ") + ")"
+}
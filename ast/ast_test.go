@@ -2,6 +2,7 @@ package ast
 
 import (
 	"interpreter/token2"
+	"strings"
 	"testing"
 )
 
@@ -35,3 +36,54 @@ func TestString(i *testing.T) {
 		i.Errorf("prgram.String() wrong. got=%q", program.String())
 	}
 }
+
+// input = `let add = fn(x) { x + 1 };`
+func TestInspect(t *testing.T) {
+	ident := func(name string) *Identifier {
+		return &Identifier{Token: token2.Token{Type: token2.IDENT, Literal: name}, Value: name}
+	}
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token2.Token{Type: token2.LET, Literal: "let"},
+				Name:  ident("add"),
+				Value: &FunctionLiteral{
+					Token:      token2.Token{Type: token2.FUNCTION, Literal: "fn"},
+					Parameters: []*Identifier{ident("x")},
+					Body: &BlockStatement{
+						Statements: []Statement{
+							&ExpressionStatement{
+								Expression: &InfixExpression{
+									Operator: "+",
+									Left:     ident("x"),
+									Right:    &IntegerLiteral{Token: token2.Token{Literal: "1"}, Value: 1},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	names := []string{}
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		return true
+	})
+	if strings.Join(names, " ") != "add x x" {
+		t.Errorf("identifiers wrong. got=%q", names)
+	}
+
+	count := 0
+	Inspect(program, func(node Node) bool {
+		count++
+		_, ok := node.(*FunctionLiteral)
+		return !ok
+	})
+	if count != 4 {
+		t.Errorf("function body not skipped, visited %d nodes, want=4", count)
+	}
+}
@@ -0,0 +1,185 @@
+package ast
+
+import (
+	"bytes"
+	"interpreter/token2"
+	"strings"
+)
+
+// Pattern describes the shape a value is matched against, see MatchExpression.
+// Names in a pattern are bound to the matching parts of the value.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// an identifier in a pattern matches anything and binds it
+func (i *Identifier) patternNode() {}
+
+// WildcardPattern: _ matches anything and binds nothing
+type WildcardPattern struct {
+	Token token2.Token
+}
+
+func (wp *WildcardPattern) patternNode() {}
+func (wp *WildcardPattern) TokenLiteral() string {
+	return wp.Token.Literal
+}
+func (wp *WildcardPattern) String() string {
+	return "_"
+}
+
+// LiteralPattern matches a value equal to an integer, string or boolean literal
+type LiteralPattern struct {
+	Token token2.Token
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode() {}
+func (lp *LiteralPattern) TokenLiteral() string {
+	return lp.Token.Literal
+}
+func (lp *LiteralPattern) String() string {
+	return lp.Value.String()
+}
+
+// ArrayPattern: [a, b, ...rest] matches an array with exactly as many elements
+// as patterns, or at least as many when Rest is set. Rest is an *Identifier or
+// a *WildcardPattern.
+type ArrayPattern struct {
+	Token    token2.Token // the '[' token
+	Elements []Pattern
+	Rest     Pattern
+}
+
+func (ap *ArrayPattern) patternNode() {}
+func (ap *ArrayPattern) TokenLiteral() string {
+	return ap.Token.Literal
+}
+func (ap *ArrayPattern) String() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// HashPattern: {"type": "user", "name": n} matches a hash that has every key
+// with a value matching its pattern, other keys are ignored. A bare name as
+// key stands for the string, {name, age: years} is {"name": name, "age": years}.
+type HashPattern struct {
+	Token  token2.Token // the '{' token
+	Keys   []Expression
+	Values []Pattern
+}
+
+func (hp *HashPattern) patternNode() {}
+func (hp *HashPattern) TokenLiteral() string {
+	return hp.Token.Literal
+}
+func (hp *HashPattern) String() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for i, key := range hp.Keys {
+		pairs = append(pairs, key.String()+": "+hp.Values[i].String())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// VariantPattern: Ok(v) matches a value of the enum variant bound to Name and
+// matches its payload against Elements, None matches a variant without
+// payload. A capitalized name in a pattern is always a variant.
+type VariantPattern struct {
+	Token    token2.Token // the variant name token
+	Name     *Identifier
+	Elements []Pattern
+}
+
+func (vp *VariantPattern) patternNode() {}
+func (vp *VariantPattern) TokenLiteral() string {
+	return vp.Token.Literal
+}
+func (vp *VariantPattern) String() string {
+	if vp.Elements == nil {
+		return vp.Name.String()
+	}
+	elements := []string{}
+	for _, el := range vp.Elements {
+		elements = append(elements, el.String())
+	}
+	return vp.Name.String() + "(" + strings.Join(elements, ", ") + ")"
+}
+
+// DefaultPattern: an element of an array or hash pattern written
+// `pattern = expr`. When the element is missing or null, Default is evaluated
+// and matched against Pattern instead.
+type DefaultPattern struct {
+	Token   token2.Token // the '=' token
+	Pattern Pattern
+	Default Expression
+}
+
+func (dp *DefaultPattern) patternNode() {}
+func (dp *DefaultPattern) TokenLiteral() string {
+	return dp.Token.Literal
+}
+func (dp *DefaultPattern) String() string {
+	return dp.Pattern.String() + " = " + dp.Default.String()
+}
+
+// MatchExpression: match (value) { pattern if guard => body, ... }
+// The arms are tried in order and the first one whose pattern matches and
+// whose guard holds gives the result.
+type MatchExpression struct {
+	Token token2.Token // the 'match' token
+	Value Expression
+	Arms  []*MatchArm
+}
+
+// MatchArm binds the names of its pattern in a scope of its own, the guard
+// and the body see them. An expression body is kept as a one statement block.
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression
+	Body    *BlockStatement
+}
+
+func (me *MatchExpression) expressionNode() {}
+func (me *MatchExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	out.WriteString("match (")
+	out.WriteString(me.Value.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+	return out.String()
+}
+
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(ma.Guard.String())
+	}
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+	return out.String()
+}
@@ -0,0 +1,81 @@
+package ast
+
+import (
+	"interpreter/token2"
+	"strings"
+)
+
+// Type is an optional annotation on a let binding, a parameter or the
+// result of a function, `let x: int = 1;` or `fn(a: [string]) -> bool`.
+// Annotations are checked by the typecheck package, Eval ignores them.
+type Type interface {
+	Node
+	typeNode()
+}
+
+// NamedType: int, string, bool, null, any, or the name of a struct or enum
+type NamedType struct {
+	Token token2.Token // the name token
+	Name  string
+}
+
+func (nt *NamedType) typeNode() {}
+func (nt *NamedType) TokenLiteral() string {
+	return nt.Token.Literal
+}
+func (nt *NamedType) String() string {
+	return nt.Name
+}
+
+// ArrayType: [int] is an array of integers
+type ArrayType struct {
+	Token   token2.Token // the '[' token
+	Element Type
+}
+
+func (at *ArrayType) typeNode() {}
+func (at *ArrayType) TokenLiteral() string {
+	return at.Token.Literal
+}
+func (at *ArrayType) String() string {
+	return "[" + at.Element.String() + "]"
+}
+
+// HashType: {string: int} is a hash from strings to integers
+type HashType struct {
+	Token token2.Token // the '{' token
+	Key   Type
+	Value Type
+}
+
+func (ht *HashType) typeNode() {}
+func (ht *HashType) TokenLiteral() string {
+	return ht.Token.Literal
+}
+func (ht *HashType) String() string {
+	return "{" + ht.Key.String() + ": " + ht.Value.String() + "}"
+}
+
+// FunctionType: fn(int, string) -> bool, Return is nil when the result is
+// not given
+type FunctionType struct {
+	Token      token2.Token // the 'fn' token
+	Parameters []Type
+	Return     Type
+}
+
+func (ft *FunctionType) typeNode() {}
+func (ft *FunctionType) TokenLiteral() string {
+	return ft.Token.Literal
+}
+func (ft *FunctionType) String() string {
+	params := []string{}
+	for _, param := range ft.Parameters {
+		params = append(params, param.String())
+	}
+	text := "fn(" + strings.Join(params, ", ") + ")"
+	if ft.Return != nil {
+		text += " -> " + ft.Return.String()
+	}
+	return text
+}
@@ -0,0 +1,182 @@
+package ast
+
+// Inspect walks the tree rooted at node depth first, calling f for node and
+// then for each of its children in source order. When f returns false the
+// children of that node are skipped. Patterns and type annotations are walked
+// like any other node, match arms and enum variants are not nodes, their parts
+// are walked in place.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+	switch node := node.(type) {
+	case *Program:
+		for _, s := range node.Statements {
+			Inspect(s, f)
+		}
+	case *LetStatement:
+		if node.Pattern != nil {
+			Inspect(node.Pattern, f)
+		} else if node.Name != nil {
+			Inspect(node.Name, f)
+		}
+		inspectType(node.Type, f)
+		inspectExpression(node.Value, f)
+	case *ReturnStatement:
+		inspectExpression(node.ReturnValue, f)
+	case *ExpressionStatement:
+		inspectExpression(node.Expression, f)
+	case *BlockStatement:
+		for _, s := range node.Statements {
+			Inspect(s, f)
+		}
+	case *FunctionDeclaration:
+		Inspect(node.Name, f)
+		Inspect(node.Function, f)
+	case *StructStatement:
+		Inspect(node.Name, f)
+		for _, field := range node.Fields {
+			Inspect(field, f)
+		}
+	case *ImplStatement:
+		Inspect(node.Name, f)
+		for _, method := range node.Methods {
+			Inspect(method, f)
+		}
+	case *EnumStatement:
+		Inspect(node.Name, f)
+		for _, variant := range node.Variants {
+			Inspect(variant.Name, f)
+			for _, field := range variant.Fields {
+				Inspect(field, f)
+			}
+		}
+	case *PrefixExpression:
+		inspectExpression(node.Right, f)
+	case *InfixExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Right, f)
+	case *IfExpression:
+		inspectExpression(node.Condition, f)
+		if node.Consequence != nil {
+			Inspect(node.Consequence, f)
+		}
+		if node.Alternative != nil {
+			Inspect(node.Alternative, f)
+		}
+	case *FunctionLiteral:
+		for i, p := range node.Parameters {
+			Inspect(p, f)
+			if i < len(node.ParameterTypes) {
+				inspectType(node.ParameterTypes[i], f)
+			}
+			if i < len(node.Defaults) {
+				inspectExpression(node.Defaults[i], f)
+			}
+		}
+		if node.Rest != nil {
+			Inspect(node.Rest, f)
+		}
+		inspectType(node.ReturnType, f)
+		if node.Body != nil {
+			Inspect(node.Body, f)
+		}
+	case *CallExpression:
+		inspectExpression(node.Function, f)
+		for _, a := range node.Arguments {
+			inspectExpression(a, f)
+		}
+	case *InterpolatedString:
+		for _, part := range node.Parts {
+			inspectExpression(part, f)
+		}
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			inspectExpression(el, f)
+		}
+	case *IndexExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Index, f)
+	case *HashLiteral:
+		for _, key := range node.Keys {
+			inspectExpression(key, f)
+			inspectExpression(node.Pairs[key], f)
+		}
+	case *SliceExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Start, f)
+		inspectExpression(node.End, f)
+		inspectExpression(node.Step, f)
+	case *RangeExpression:
+		inspectExpression(node.Start, f)
+		inspectExpression(node.End, f)
+	case *SpreadExpression:
+		inspectExpression(node.Value, f)
+	case *KeywordArgument:
+		inspectExpression(node.Value, f)
+	case *AssignExpression:
+		inspectExpression(node.Target, f)
+		inspectExpression(node.Value, f)
+	case *DotExpression:
+		inspectExpression(node.Left, f)
+	case *MatchExpression:
+		inspectExpression(node.Value, f)
+		for _, arm := range node.Arms {
+			if arm.Pattern != nil {
+				Inspect(arm.Pattern, f)
+			}
+			inspectExpression(arm.Guard, f)
+			if arm.Body != nil {
+				Inspect(arm.Body, f)
+			}
+		}
+	case *LiteralPattern:
+		inspectExpression(node.Value, f)
+	case *ArrayPattern:
+		for _, el := range node.Elements {
+			Inspect(el, f)
+		}
+		if node.Rest != nil {
+			Inspect(node.Rest, f)
+		}
+	case *HashPattern:
+		for i, key := range node.Keys {
+			inspectExpression(key, f)
+			if i < len(node.Values) {
+				Inspect(node.Values[i], f)
+			}
+		}
+	case *VariantPattern:
+		Inspect(node.Name, f)
+		for _, el := range node.Elements {
+			Inspect(el, f)
+		}
+	case *DefaultPattern:
+		Inspect(node.Pattern, f)
+		inspectExpression(node.Default, f)
+	case *ArrayType:
+		inspectType(node.Element, f)
+	case *HashType:
+		inspectType(node.Key, f)
+		inspectType(node.Value, f)
+	case *FunctionType:
+		for _, param := range node.Parameters {
+			inspectType(param, f)
+		}
+		inspectType(node.Return, f)
+	}
+}
+
+// the optional parts of a node are nil interfaces, which Inspect would
+// otherwise pass on to f
+func inspectExpression(e Expression, f func(Node) bool) {
+	if e != nil {
+		Inspect(e, f)
+	}
+}
+
+func inspectType(t Type, f func(Node) bool) {
+	if t != nil {
+		Inspect(t, f)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+	Write the syntax tree of a Monkey program in a machine format, JSON or
+	s-expressions, and read it back into ast nodes.
+		- every node is an object with its type, the name of its Go struct,
+		  and its fields named like the struct fields with a lower case first
+		  letter, in the order of the struct
+		- tokens are objects of their type, literal, line and column, so
+		  every node carries its position
+		- a field that is not set is null, nil in an s-expression, an empty
+		  list is kept apart from a missing one
+		- the pairs of a hash literal are written as its keys and a list of
+		  values in the same order
+		- what the resolver fills in is not written, a tree read back must
+		  be resolved again before it runs
+	In JSON the type of a node is its "node" member:
+		{"node": "Identifier", "token": {"type": "IDENT", "literal": "x",
+		"line": 1, "column": 5}, "value": "x"}
+	and in an s-expression the symbol after the parenthesis, with the fields
+	as keywords:
+		(Identifier :token (:type "IDENT" :literal "x" :line 1 :column 5) :value "x")
+*/
+package astcodec
+
+import (
+	"bytes"
+	"encoding/json"
+	"interpreter/ast"
+	"reflect"
+)
+
+// JSON returns node as an indented JSON document
+func JSON(node ast.Node) ([]byte, error) {
+	return json.MarshalIndent(encode(reflect.ValueOf(node)), "", "  ")
+}
+
+// FromJSON reads back a node written by JSON
+func FromJSON(data []byte) (ast.Node, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	v, err := fromJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	return decodeNode(v)
+}
+
+// SExpr returns node as an s-expression, a node that does not fit on a line
+// has a field per line
+func SExpr(node ast.Node) string {
+	var out bytes.Buffer
+	writeSExpr(&out, encode(reflect.ValueOf(node)), 0)
+	out.WriteString("\n")
+	return out.String()
+}
+
+// FromSExpr reads back a node written by SExpr
+func FromSExpr(src string) (ast.Node, error) {
+	r := &reader{src: src}
+	v, err := r.value()
+	if err != nil {
+		return nil, err
+	}
+	if r.next(); r.token.kind != eof {
+		return nil, r.errorf("unexpected %s after the node", r.token)
+	}
+	return decodeNode(v)
+}
@@ -0,0 +1,212 @@
+package astcodec
+
+import (
+	"bytes"
+	goast "go/ast"
+	goparser "go/parser"
+	"go/token"
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object2"
+	"interpreter/parser"
+	"interpreter/resolver"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, src string) *ast.Program {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%q: %v", src, p.Errors())
+	}
+	return program
+}
+
+// programs with every kind of node
+var programs = []string{
+	`let x = 5; const y = -x + 2 * 3; return y;`,
+	`let add = fn(a, b = 1, ...rest) { a + b }; fn twice(f) { f(); f() } add(1, b = 2)`,
+	`if (x < 1) { "small" } else { "${x} big" }`,
+	`let h = {"a": 1, true: [1, 2], 3: f(...xs)}; h["a"]; xs[1:2:-1]; 1..10; 1..<10`,
+	`let [first, _, ...more] = xs; let {name, age: years = 0} = person; x = x + 1`,
+	`match (v) { 1 => "one", [a, b] if a > b => a, Ok(value) => value, None => 0, _ => -1 }`,
+	`struct Point { x, y } impl Point { fn norm(self) { self.x * self.x } } Point(1, 2).norm()`,
+	`enum Shape { Circle(r), Square(side), Empty }`,
+	`let f: fn(int, [string]) -> {string: bool} = fn(n: int, names: [string]) -> {string: bool} { {} };`,
+	`99999999999999999999 * 2`,
+	"\"tab\t\" + \"back\\\\slash\"",
+	`puts("` + strings.Repeat("a", 90) + `")`,
+}
+
+// corpus is programs followed by the string literals of the evaluator tests
+func corpus(t *testing.T) []string {
+	file, err := goparser.ParseFile(token.NewFileSet(), "../evaluator/evalutor_test.go", nil, 0)
+	if err != nil {
+		t.Fatalf("cannot read the evaluator tests: %v", err)
+	}
+	inputs := append([]string{}, programs...)
+	goast.Inspect(file, func(node goast.Node) bool {
+		if lit, ok := node.(*goast.BasicLit); ok && lit.Kind == token.STRING {
+			if s, err := strconv.Unquote(lit.Value); err == nil {
+				inputs = append(inputs, s)
+			}
+		}
+		return true
+	})
+	return inputs
+}
+
+// roundTrip returns program read back from its JSON and from its s-expression
+func roundTrip(t *testing.T, program *ast.Program) []ast.Node {
+	data, err := JSON(program)
+	if err != nil {
+		t.Fatalf("%q: %s", program.String(), err)
+	}
+	fromJSON, err := FromJSON(data)
+	if err != nil {
+		t.Fatalf("%q: %s", program.String(), err)
+	}
+	fromSExpr, err := FromSExpr(SExpr(program))
+	if err != nil {
+		t.Fatalf("%q: %s\n%s", program.String(), err, SExpr(program))
+	}
+	return []ast.Node{fromJSON, fromSExpr}
+}
+
+// run resolves and evaluates program in a fresh environment, it returns the
+// result and what the program printed
+func run(program *ast.Program) (string, string) {
+	r := resolver.New(evaluator.NewGlobalScope())
+	r.Resolve(program)
+	if len(r.Errors()) > 0 {
+		return "resolve: " + strings.Join(r.Errors(), "; "), ""
+	}
+	var out bytes.Buffer
+	result := evaluator.Eval(program, evaluator.NewEnvironment(&evaluator.Context{Stdout: &out}))
+	if result == nil {
+		return "nil", out.String()
+	}
+	if err, ok := result.(*object2.Error); ok {
+		return "error: " + strings.Join(append([]string{err.Message}, err.Stack...), "\nat "), out.String()
+	}
+	return string(result.Type()) + " " + result.Inspect(), out.String()
+}
+
+func TestRoundTripRuns(t *testing.T) {
+	checked := 0
+	for _, src := range corpus(t) {
+		p := parser.New(lexer.New(src))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 || len(program.Statements) == 0 {
+			continue
+		}
+		checked++
+		decoded := roundTrip(t, program)
+		want, wantOutput := run(program)
+		for _, node := range decoded {
+			got, output := run(node.(*ast.Program))
+			if got != want || output != wantOutput {
+				t.Errorf("%q read back runs differently.\nwant=%s\n%s\ngot=%s\n%s", src, want, wantOutput, got, output)
+			}
+		}
+	}
+	if checked < 100 {
+		t.Errorf("expected at least 100 programs, got=%d", checked)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, src := range corpus(t) {
+		p := parser.New(lexer.New(src))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 || len(program.Statements) == 0 {
+			continue
+		}
+		data, _ := JSON(program)
+		for _, decoded := range roundTrip(t, program) {
+			if decoded.String() != program.String() {
+				t.Errorf("%q read back as %q", src, decoded.String())
+			}
+			// the positions are kept too
+			again, _ := JSON(decoded)
+			if string(again) != string(data) {
+				t.Errorf("%q dumped again differs:\n%s", src, again)
+			}
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	program := parse(t, "a + 1")
+	expected := `(Program
+  :statements ((ExpressionStatement
+      :token (:type "IDENT" :literal "a" :line 1 :column 1)
+      :expression (InfixExpression
+        :token (:type "+" :literal "+" :line 1 :column 3)
+        :left (Identifier
+          :token (:type "IDENT" :literal "a" :line 1 :column 1)
+          :value "a")
+        :right (IntegerLiteral
+          :token (:type "INT" :literal "1" :line 1 :column 5)
+          :value 1
+          :big nil)
+        :operator "+"))))
+`
+	if got := SExpr(program); got != expected {
+		t.Errorf("s-expression wrong.\nexpected=%s\ngot=%s", expected, got)
+	}
+	data, err := JSON(program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression).Right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `{
+  "node": "IntegerLiteral",
+  "token": {
+    "type": "INT",
+    "literal": "1",
+    "line": 1,
+    "column": 5
+  },
+  "value": 1,
+  "big": null
+}`
+	if string(data) != expected {
+		t.Errorf("JSON wrong.\nexpected=%s\ngot=%s", expected, data)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"node": "Program", "statements": [{"node": "Identifier", "value": "x"}]}`,
+			"Program.statements: element 0: expected a Statement, got Identifier"},
+		{`{"node": "Nothing"}`, `unknown node type "Nothing"`},
+		{`{"node": "Identifier", "value": 1}`, "Identifier.value: expected a string, got 1"},
+		{`{"node": "Identifier", "slot": 1}`, "unknown field slot of Identifier"},
+		{`{"node": "IntegerLiteral", "big": "1x"}`, `IntegerLiteral.big: not an integer: "1x"`},
+		{`{"node": "HashLiteral", "keys": [], "values": [{"node": "Boolean"}]}`, "HashLiteral: 0 keys and 1 values"},
+		{`{"node": "LetStatement", "name": {"node": "StringLiteral"}}`, "LetStatement.name: expected Identifier, got StringLiteral"},
+		{`[1]`, "expected a Node, got a list"},
+		{`(Program :statements (1))`, "Program.statements: element 0: expected a Statement, got 1"},
+		{`(Program :statements ()`, "line 1: expected a field name or ')', got end of input"},
+		{"(Identifier\n  :value x)", `line 2: unexpected "x"`},
+		{`(Identifier) 1`, `line 1: unexpected "1" after the node`},
+		{`nil`, "expected a node, got nil"},
+	}
+	for _, tt := range tests {
+		var err error
+		if strings.HasPrefix(tt.input, "{") || strings.HasPrefix(tt.input, "[") {
+			_, err = FromJSON([]byte(tt.input))
+		} else {
+			_, err = FromSExpr(tt.input)
+		}
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
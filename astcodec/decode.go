@@ -0,0 +1,234 @@
+package astcodec
+
+import (
+	"encoding/json"
+	"fmt"
+	"interpreter/ast"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// fromJSON turns what encoding/json decoded into the data encode gives
+func fromJSON(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		o := &object{}
+		names := []string{}
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value, err := fromJSON(v[name])
+			if err != nil {
+				return nil, err
+			}
+			if name != "node" {
+				o.fields = append(o.fields, field{name, value})
+				continue
+			}
+			tag, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("the node type must be a string, got %s", describe(value))
+			}
+			o.tag = tag
+		}
+		return o, nil
+	case []interface{}:
+		list := []interface{}{}
+		for _, el := range v {
+			el, err := fromJSON(el)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, el)
+		}
+		return list, nil
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("not an integer: %s", v)
+		}
+		return n, nil
+	}
+	return v, nil
+}
+
+func decodeNode(v interface{}) (ast.Node, error) {
+	var node ast.Node
+	if err := decode(v, reflect.ValueOf(&node).Elem()); err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("expected a node, got nil")
+	}
+	return node, nil
+}
+
+// decode sets into, a zero value, to v
+func decode(v interface{}, into reflect.Value) error {
+	t := into.Type()
+	if v == nil {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice:
+			return nil
+		}
+		return fmt.Errorf("expected %s, got nil", describeType(t))
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		if t == bigIntType {
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("expected an integer string, got %s", describe(v))
+			}
+			n, ok := new(big.Int).SetString(s, 10)
+			if !ok {
+				return fmt.Errorf("not an integer: %q", s)
+			}
+			into.Set(reflect.ValueOf(n))
+			return nil
+		}
+		ptr := reflect.New(t.Elem())
+		if err := decode(v, ptr.Elem()); err != nil {
+			return err
+		}
+		into.Set(ptr)
+		return nil
+	case reflect.Interface:
+		o, ok := v.(*object)
+		if !ok || o.tag == "" {
+			return fmt.Errorf("expected %s, got %s", describeType(t), describe(v))
+		}
+		nt, ok := nodes[o.tag]
+		if !ok {
+			return fmt.Errorf("unknown node type %q", o.tag)
+		}
+		ptr := reflect.New(nt)
+		if !ptr.Type().Implements(t) {
+			return fmt.Errorf("expected %s, got %s", describeType(t), o.tag)
+		}
+		if err := decodeStruct(o, ptr.Elem()); err != nil {
+			return err
+		}
+		into.Set(ptr)
+		return nil
+	case reflect.Struct:
+		o, ok := v.(*object)
+		if !ok || (isNode(t) && o.tag != t.Name()) || (!isNode(t) && o.tag != "") {
+			return fmt.Errorf("expected %s, got %s", describeType(t), describe(v))
+		}
+		return decodeStruct(o, into)
+	case reflect.Slice:
+		list, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected a list, got %s", describe(v))
+		}
+		slice := reflect.MakeSlice(t, len(list), len(list))
+		for i, el := range list {
+			if err := decode(el, slice.Index(i)); err != nil {
+				return fmt.Errorf("element %d: %v", i, err)
+			}
+		}
+		into.Set(slice)
+		return nil
+	case reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %s", describe(v))
+		}
+		into.SetString(s)
+		return nil
+	case reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("expected a boolean, got %s", describe(v))
+		}
+		into.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int64:
+		n, ok := v.(int64)
+		if !ok {
+			return fmt.Errorf("expected an integer, got %s", describe(v))
+		}
+		into.SetInt(n)
+		return nil
+	}
+	return fmt.Errorf("cannot decode %s", t)
+}
+
+func decodeStruct(o *object, into reflect.Value) error {
+	t := into.Type()
+	hl, isHash := into.Addr().Interface().(*ast.HashLiteral)
+	var values []ast.Expression
+	for _, f := range o.fields {
+		if isHash && f.name == "values" {
+			if err := decode(f.value, reflect.ValueOf(&values).Elem()); err != nil {
+				return fmt.Errorf("%s.values: %v", t.Name(), err)
+			}
+			continue
+		}
+		sf, ok := structField(t, f.name)
+		if !ok {
+			return fmt.Errorf("unknown field %s of %s", f.name, describeType(t))
+		}
+		if err := decode(f.value, into.FieldByIndex(sf.Index)); err != nil {
+			return fmt.Errorf("%s.%s: %v", t.Name(), f.name, err)
+		}
+	}
+	if isHash {
+		if len(values) != len(hl.Keys) {
+			return fmt.Errorf("%s: %d keys and %d values", t.Name(), len(hl.Keys), len(values))
+		}
+		hl.Pairs = make(map[ast.Expression]ast.Expression)
+		for i, key := range hl.Keys {
+			hl.Pairs[key] = values[i]
+		}
+	}
+	return nil
+}
+
+func structField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if fieldName(f.Name) == name && !resolved[t.Name()+"."+f.Name] && f.Type.Kind() != reflect.Map {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// describe names what v is in an error
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case *object:
+		if v.tag != "" {
+			return v.tag
+		}
+		return "an object"
+	case []interface{}:
+		return "a list"
+	case string:
+		return fmt.Sprintf("%q", v)
+	}
+	return fmt.Sprint(v)
+}
+
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Interface:
+		if strings.ContainsRune("AEIOU", rune(t.Name()[0])) {
+			return "an " + t.Name()
+		}
+		return "a " + t.Name()
+	case reflect.Ptr:
+		return describeType(t.Elem())
+	case reflect.Struct:
+		return t.Name()
+	}
+	return t.String()
+}
@@ -0,0 +1,154 @@
+package astcodec
+
+import (
+	"bytes"
+	"encoding/json"
+	"interpreter/ast"
+	"math/big"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+// a node as data is nil, a bool, an int64, a string, a []interface{} or an
+// *object
+
+// object is a node, or a token, match arm or enum variant, which have no tag
+type object struct {
+	tag    string
+	fields []field
+}
+
+type field struct {
+	name  string
+	value interface{}
+}
+
+func (o *object) get(name string) (interface{}, bool) {
+	for _, f := range o.fields {
+		if f.name == name {
+			return f.value, true
+		}
+	}
+	return nil, false
+}
+
+// MarshalJSON writes the tag first and the fields in order, a map would have
+// them sorted
+func (o *object) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString("{")
+	if o.tag != "" {
+		out.WriteString(`"node":`)
+		tag, _ := json.Marshal(o.tag)
+		out.Write(tag)
+	}
+	for i, f := range o.fields {
+		if i > 0 || o.tag != "" {
+			out.WriteString(",")
+		}
+		name, _ := json.Marshal(f.name)
+		out.Write(name)
+		out.WriteString(":")
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(value)
+	}
+	out.WriteString("}")
+	return out.Bytes(), nil
+}
+
+// every node type, by the name of its struct
+var nodes = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []ast.Node{
+		&ast.Program{}, &ast.LetStatement{}, &ast.ReturnStatement{}, &ast.ExpressionStatement{},
+		&ast.BlockStatement{}, &ast.FunctionDeclaration{}, &ast.StructStatement{},
+		&ast.ImplStatement{}, &ast.EnumStatement{},
+		&ast.Identifier{}, &ast.IntegerLiteral{}, &ast.Boolean{}, &ast.StringLiteral{},
+		&ast.InterpolatedString{}, &ast.PrefixExpression{}, &ast.InfixExpression{},
+		&ast.IfExpression{}, &ast.FunctionLiteral{}, &ast.CallExpression{}, &ast.ArrayLiteral{},
+		&ast.IndexExpression{}, &ast.HashLiteral{}, &ast.SliceExpression{}, &ast.RangeExpression{},
+		&ast.SpreadExpression{}, &ast.KeywordArgument{}, &ast.AssignExpression{},
+		&ast.DotExpression{}, &ast.MatchExpression{},
+		&ast.WildcardPattern{}, &ast.LiteralPattern{}, &ast.ArrayPattern{}, &ast.HashPattern{},
+		&ast.VariantPattern{}, &ast.DefaultPattern{},
+		&ast.NamedType{}, &ast.ArrayType{}, &ast.HashType{}, &ast.FunctionType{},
+	} {
+		t := reflect.TypeOf(node).Elem()
+		nodes[t.Name()] = t
+	}
+}
+
+var (
+	nodeType   = reflect.TypeOf((*ast.Node)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// the fields the resolver fills in
+var resolved = map[string]bool{
+	"Identifier.Depth": true,
+	"Identifier.Slot":  true,
+}
+
+func fieldName(name string) string {
+	first, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(first)) + name[size:]
+}
+
+func isNode(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(nodeType)
+}
+
+func encode(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type() == bigIntType {
+			return v.Interface().(*big.Int).String()
+		}
+		return encode(v.Elem())
+	case reflect.Struct:
+		t := v.Type()
+		o := &object{}
+		if isNode(t) {
+			o.tag = t.Name()
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if resolved[t.Name()+"."+f.Name] || f.Type.Kind() == reflect.Map {
+				continue
+			}
+			o.fields = append(o.fields, field{fieldName(f.Name), encode(v.Field(i))})
+		}
+		if hl, ok := v.Addr().Interface().(*ast.HashLiteral); ok {
+			values := []interface{}{}
+			for _, key := range hl.Keys {
+				values = append(values, encode(reflect.ValueOf(hl.Pairs[key])))
+			}
+			o.fields = append(o.fields, field{"values", values})
+		}
+		return o
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		list := []interface{}{}
+		for i := 0; i < v.Len(); i++ {
+			list = append(list, encode(v.Index(i)))
+		}
+		return list
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int64:
+		return v.Int()
+	}
+	panic("astcodec: cannot encode " + v.Type().String())
+}
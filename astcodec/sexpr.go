@@ -0,0 +1,237 @@
+package astcodec
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// the widest a node written on one line gets
+const lineWidth = 80
+
+// writeSExpr writes v at depth levels of nesting, on one line when it fits
+func writeSExpr(out *bytes.Buffer, v interface{}, depth int) {
+	if line := inline(v); 2*depth+len(line) <= lineWidth {
+		out.WriteString(line)
+		return
+	}
+	newline := "\n" + strings.Repeat("  ", depth+1)
+	switch v := v.(type) {
+	case *object:
+		out.WriteString("(" + v.tag)
+		for i, f := range v.fields {
+			// an object without a type starts with its first field
+			if i > 0 || v.tag != "" {
+				out.WriteString(newline)
+			}
+			out.WriteString(":" + f.name + " ")
+			writeSExpr(out, f.value, depth+1)
+		}
+		out.WriteString(")")
+	case []interface{}:
+		out.WriteString("(")
+		for i, el := range v {
+			if i > 0 {
+				out.WriteString(newline)
+			}
+			writeSExpr(out, el, depth+1)
+		}
+		out.WriteString(")")
+	default:
+		// a scalar cannot be broken over lines
+		out.WriteString(inline(v))
+	}
+}
+
+// inline returns v written on one line
+func inline(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return strconv.Quote(v)
+	case *object:
+		parts := []string{}
+		if v.tag != "" {
+			parts = append(parts, v.tag)
+		}
+		for _, f := range v.fields {
+			parts = append(parts, ":"+f.name, inline(f.value))
+		}
+		return "(" + strings.Join(parts, " ") + ")"
+	case []interface{}:
+		elements := []string{}
+		for _, el := range v {
+			elements = append(elements, inline(el))
+		}
+		return "(" + strings.Join(elements, " ") + ")"
+	}
+	return ""
+}
+
+type tokenKind int
+
+const (
+	eof tokenKind = iota
+	lparen
+	rparen
+	symbol  // a node type, nil, true or false
+	keyword // :name
+	str
+	integer
+)
+
+type sexprToken struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+func (t sexprToken) String() string {
+	if t.kind == eof {
+		return "end of input"
+	}
+	return strconv.Quote(t.text)
+}
+
+// reader reads the s-expressions SExpr writes
+type reader struct {
+	src   string
+	pos   int
+	line  int
+	token sexprToken
+}
+
+func (r *reader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", r.token.line, fmt.Sprintf(format, args...))
+}
+
+// next moves to the next token
+func (r *reader) next() {
+	if r.line == 0 {
+		r.line = 1
+	}
+	for r.pos < len(r.src) && strings.IndexByte(" \t\r\n", r.src[r.pos]) >= 0 {
+		if r.src[r.pos] == '\n' {
+			r.line++
+		}
+		r.pos++
+	}
+	start := r.pos
+	r.token = sexprToken{line: r.line}
+	if r.pos == len(r.src) {
+		r.token.kind = eof
+		return
+	}
+	switch c := r.src[r.pos]; {
+	case c == '(':
+		r.token.kind = lparen
+		r.pos++
+	case c == ')':
+		r.token.kind = rparen
+		r.pos++
+	case c == '"':
+		r.token.kind = str
+		for r.pos++; r.pos < len(r.src) && r.src[r.pos] != '"'; r.pos++ {
+			if r.src[r.pos] == '\\' {
+				r.pos++
+			}
+		}
+		r.pos++
+	default:
+		for r.pos < len(r.src) && strings.IndexByte(" \t\r\n()\"", r.src[r.pos]) < 0 {
+			r.pos++
+		}
+		switch {
+		case c == ':':
+			r.token.kind = keyword
+		case c == '-' || ('0' <= c && c <= '9'):
+			r.token.kind = integer
+		default:
+			r.token.kind = symbol
+		}
+	}
+	if r.pos > len(r.src) {
+		r.pos = len(r.src)
+	}
+	r.token.text = r.src[start:r.pos]
+}
+
+// value reads the value that starts at the next token
+func (r *reader) value() (interface{}, error) {
+	r.next()
+	switch r.token.kind {
+	case lparen:
+		return r.compound()
+	case str:
+		s, err := strconv.Unquote(r.token.text)
+		if err != nil {
+			return nil, r.errorf("bad string %s", r.token.text)
+		}
+		return s, nil
+	case integer:
+		n, err := strconv.ParseInt(r.token.text, 10, 64)
+		if err != nil {
+			return nil, r.errorf("bad integer %s", r.token.text)
+		}
+		return n, nil
+	case symbol:
+		switch r.token.text {
+		case "nil":
+			return nil, nil
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return nil, r.errorf("unexpected %s", r.token)
+}
+
+// compound reads a node, an object or a list after its '('. A node starts
+// with its type and an object with a keyword, anything else is a list.
+func (r *reader) compound() (interface{}, error) {
+	start := *r
+	r.next()
+	isObject := r.token.kind == keyword ||
+		(r.token.kind == symbol && r.token.text != "nil" && r.token.text != "true" && r.token.text != "false")
+	if !isObject {
+		*r = start
+		list := []interface{}{}
+		for {
+			saved := *r
+			if r.next(); r.token.kind == rparen {
+				return list, nil
+			}
+			*r = saved
+			el, err := r.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, el)
+		}
+	}
+	o := &object{}
+	if r.token.kind == symbol {
+		o.tag = r.token.text
+		r.next()
+	}
+	for r.token.kind != rparen {
+		if r.token.kind != keyword {
+			return nil, r.errorf("expected a field name or ')', got %s", r.token)
+		}
+		name := r.token.text[1:]
+		value, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		o.fields = append(o.fields, field{name, value})
+		r.next()
+	}
+	return o, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"interpreter/typecheck"
+	"os"
+)
+
+// monkey check [--json] file ...
+// Reports the type errors and resolver warnings of the files, one per line,
+// or as a JSON array of diagnostics with --json. The exit status is 1 when something is found and
+// 2 when a file cannot be read or does not parse.
+func cmdCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the diagnostics as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey check [--json] file ...")
+		return 2
+	}
+
+	status := 0
+	diagnostics := []typecheck.Diagnostic{}
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey check: %s\n", err)
+			status = 2
+			continue
+		}
+		found, err := typecheck.Source(path, string(src))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s:\n%s\n", path, err)
+			status = 2
+			continue
+		}
+		diagnostics = append(diagnostics, found...)
+	}
+	if len(diagnostics) > 0 && status == 0 {
+		status = 1
+	}
+	if *asJSON {
+		out, err := json.MarshalIndent(diagnostics, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey check: %s\n", err)
+			return 2
+		}
+		fmt.Println(string(out))
+		return status
+	}
+	for _, d := range diagnostics {
+		fmt.Println(d)
+	}
+	return status
+}
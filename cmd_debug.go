@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"interpreter/debugger"
+	"os"
+)
+
+// monkey debug file.mk
+// monkey debug --dap
+// Debugs file.mk from the terminal, stopping before its first statement, or
+// serves the Debug Adapter Protocol on the standard input and output for an
+// editor, which names the program in its launch request.
+func cmdDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	dap := flags.Bool("dap", false, "speak the Debug Adapter Protocol on stdin and stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *dap {
+		if err := debugger.ServeDAP(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey debug: %s\n", err)
+			return 1
+		}
+		return 0
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey debug file.mk | monkey debug --dap")
+		return 2
+	}
+	path := flags.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey debug: %s\n", err)
+		return 2
+	}
+	return debugger.RunTerminal(path, string(src), os.Stdin, os.Stdout)
+}
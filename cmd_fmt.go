@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"interpreter/format"
+	"io"
+	"os"
+)
+
+// monkey fmt [--check] [file ...]
+// Rewrites the files in the canonical layout, or formats the standard input
+// to the standard output when no file is given. With --check nothing is
+// written, the files that are not formatted are listed and the exit status
+// is 1 when there are any. Files that do not parse give exit status 2.
+func cmdFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "list the files that are not formatted instead of rewriting them")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey fmt: %s\n", err)
+			return 2
+		}
+		return formatFile("<stdin>", string(src), *check, func(out string) error {
+			_, err := io.WriteString(os.Stdout, out)
+			return err
+		})
+	}
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey fmt: %s\n", err)
+			status = 2
+			continue
+		}
+		result := formatFile(path, string(src), *check, func(out string) error {
+			if out == string(src) {
+				return nil
+			}
+			return os.WriteFile(path, []byte(out), 0644)
+		})
+		if result > status {
+			status = result
+		}
+	}
+	return status
+}
+
+// formatFile formats src and hands the result to write, or with check only
+// reports whether name is formatted
+func formatFile(name string, src string, check bool, write func(string) error) int {
+	out, err := format.Source(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s:\n%s\n", name, err)
+		return 2
+	}
+	if check {
+		if out != src {
+			fmt.Println(name)
+			return 1
+		}
+		return 0
+	}
+	if err := write(out); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey fmt: %s\n", err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"interpreter/lint"
+	"os"
+	"strings"
+)
+
+// monkey lint [--enable rules] [--disable rules] [--json] [--rules] file ...
+// Reports the mistakes the linter finds in the files, one per line, or as a
+// JSON array of diagnostics with --json. Rules are given as comma separated
+// names, --rules lists them. The exit status is 1 when something is found
+// and 2 when a file cannot be read or does not parse.
+func cmdLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	enable := flags.String("enable", "", "run only these rules")
+	disable := flags.String("disable", "", "do not run these rules")
+	asJSON := flags.Bool("json", false, "print the diagnostics as JSON")
+	list := flags.Bool("rules", false, "list the rules")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *list {
+		for _, rule := range lint.Rules {
+			fmt.Printf("%-20s %s\n", rule.Name, rule.Doc)
+		}
+		return 0
+	}
+	config := lint.Config{Enable: ruleList(*enable), Disable: ruleList(*disable)}
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey lint: %s\n", err)
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey lint [--enable rules] [--disable rules] [--json] file ...")
+		return 2
+	}
+
+	status := 0
+	diagnostics := []lint.Diagnostic{}
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey lint: %s\n", err)
+			status = 2
+			continue
+		}
+		found, err := lint.Source(path, string(src), config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s:\n%s\n", path, err)
+			status = 2
+			continue
+		}
+		diagnostics = append(diagnostics, found...)
+	}
+	if len(diagnostics) > 0 && status == 0 {
+		status = 1
+	}
+	if *asJSON {
+		out, err := json.MarshalIndent(diagnostics, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey lint: %s\n", err)
+			return 2
+		}
+		fmt.Println(string(out))
+		return status
+	}
+	for _, d := range diagnostics {
+		fmt.Println(d)
+	}
+	return status
+}
+
+func ruleList(names string) []string {
+	list := []string{}
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			list = append(list, name)
+		}
+	}
+	return list
+}
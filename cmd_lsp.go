@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"interpreter/lsp"
+	"os"
+)
+
+// monkey lsp
+// Runs the language server on the standard input and output until the
+// editor asks it to exit.
+func cmdLsp(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "monkey lsp: unexpected arguments %q\n", args)
+		return 2
+	}
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey lsp: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/astcodec"
+	"interpreter/format"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"os"
+	"strings"
+)
+
+// monkey parse [--format json|sexpr|source] [--input source|json|sexpr] file
+// Prints the syntax tree of file as JSON, as an s-expression, or as the
+// source formatted back from the tree. With --input the file is a tree
+// dumped before instead of a program, so a dump can be turned back into
+// source. The exit status is 2 when the file cannot be read or parsed.
+func cmdParse(args []string) int {
+	flags := flag.NewFlagSet("parse", flag.ContinueOnError)
+	output := flags.String("format", "json", "what to print: json, sexpr or source")
+	input := flags.String("input", "source", "what the file holds: source, json or sexpr")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey parse [--format json|sexpr|source] [--input source|json|sexpr] file")
+		return 2
+	}
+	path := flags.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey parse: %s\n", err)
+		return 2
+	}
+
+	var node ast.Node
+	switch *input {
+	case "source":
+		p := parser.New(lexer.New(string(src)))
+		node = p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			fmt.Fprintf(os.Stderr, "%s:\n%s\n", path, strings.Join(p.Errors(), "\n"))
+			return 2
+		}
+	case "json":
+		node, err = astcodec.FromJSON(src)
+	case "sexpr":
+		node, err = astcodec.FromSExpr(string(src))
+	default:
+		fmt.Fprintf(os.Stderr, "monkey parse: unknown input %q\n", *input)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		return 2
+	}
+
+	switch *output {
+	case "json":
+		out, err := astcodec.JSON(node)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey parse: %s\n", err)
+			return 2
+		}
+		fmt.Println(string(out))
+	case "sexpr":
+		fmt.Print(astcodec.SExpr(node))
+	case "source":
+		fmt.Print(format.Node(node))
+	default:
+		fmt.Fprintf(os.Stderr, "monkey parse: unknown format %q\n", *output)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/cover"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object2"
+	"interpreter/optimize"
+	"interpreter/parser"
+	"interpreter/profiler"
+	"interpreter/resolver"
+	"os"
+	"strings"
+)
+
+// monkey run [--optimize] [--cover ...] [--profile file] [--profiletop] file.mk
+// Runs file.mk, the warnings of the resolver are printed to stderr first.
+// The exit status is 1 when it ends with an error and 2 when it does not
+// parse. --optimize runs the program through the passes of the
+// optimize package first. See coverFlags for the coverage options. --profile
+// writes a pprof profile of the calls of Monkey functions and builtins for
+// go tool pprof, --profiletop prints them to stderr by the time spent in
+// each function.
+func cmdRun(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	optimized := flags.Bool("optimize", false, "fold constants, drop dead branches and inline small functions first")
+	coverage := addCoverFlags(flags)
+	profile := flags.String("profile", "", "write a pprof profile of the program")
+	top := flags.Bool("profiletop", false, "print the functions by the time spent in them")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey run [--optimize] [--cover ...] [--profile file] [--profiletop] file.mk")
+		return 2
+	}
+	path := flags.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey run: %s\n", err)
+		return 2
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		fmt.Fprintf(os.Stderr, "%s:\n%s\n", path, strings.Join(p.Errors(), "\n"))
+		return 2
+	}
+	if *optimized {
+		optimize.Optimize(program)
+	}
+	r := resolver.New(evaluator.NewGlobalScope())
+	r.Resolve(program)
+	if len(r.Errors()) > 0 {
+		for i, msg := range r.Errors() {
+			token := r.ErrorTokens()[i]
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", path, token.Line, token.Column, msg)
+		}
+		return 2
+	}
+	for i, msg := range r.Warnings() {
+		token := r.WarningTokens()[i]
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: warning: %s\n", path, token.Line, token.Column, msg)
+	}
+
+	ctx := &evaluator.Context{Coverage: coverage.start()}
+	if *profile != "" || *top {
+		ctx.Profile = evaluator.NewProfile()
+	}
+	status := 0
+	if err, ok := evaluator.Eval(program, evaluator.NewEnvironment(ctx)).(*object2.Error); ok {
+		fmt.Fprintln(os.Stderr, err.Inspect())
+		status = 1
+	}
+	if p := ctx.Profile; p != nil {
+		p.Stop()
+		if *top {
+			profiler.Flat(os.Stderr, p, path)
+		}
+		if *profile != "" {
+			if err := writeProfile(*profile, p, path); err != nil {
+				fmt.Fprintf(os.Stderr, "monkey run: %s\n", err)
+				return 2
+			}
+		}
+	}
+	if err := coverage.report([]coveredFile{{path, string(src), program}}); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey run: %s\n", err)
+		return 2
+	}
+	return status
+}
+
+func writeProfile(file string, p *evaluator.Profile, path string) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := profiler.Pprof(out, p, path); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// coverFlags are the coverage options of run and test
+//
+//	--cover               print the share of statements run to stderr
+//	--coverprofile FILE   write the hits of every line as LCOV to FILE
+//	--coverannotate FILE  write the sources with the hits of every line
+//
+// Any of them turns coverage on.
+type coverFlags struct {
+	summary  *bool
+	profile  *string
+	annotate *string
+	counts   *evaluator.Coverage
+}
+
+type coveredFile struct {
+	path    string
+	src     string
+	program *ast.Program
+}
+
+func addCoverFlags(flags *flag.FlagSet) *coverFlags {
+	return &coverFlags{
+		summary:  flags.Bool("cover", false, "print statement coverage"),
+		profile:  flags.String("coverprofile", "", "write an LCOV coverage file"),
+		annotate: flags.String("coverannotate", "", "write the sources annotated with line hits"),
+	}
+}
+
+func (c *coverFlags) enabled() bool {
+	return *c.summary || *c.profile != "" || *c.annotate != ""
+}
+
+// start returns the counts to evaluate with when coverage is asked for, nil
+// when it is not
+func (c *coverFlags) start() *evaluator.Coverage {
+	if c.enabled() {
+		c.counts = evaluator.NewCoverage()
+	}
+	return c.counts
+}
+
+// report writes the coverage of files
+func (c *coverFlags) report(files []coveredFile) error {
+	if c.counts == nil {
+		return nil
+	}
+	profiles := []*cover.Profile{}
+	for _, file := range files {
+		profiles = append(profiles, cover.New(file.path, file.src, file.program, c.counts))
+	}
+	if *c.summary {
+		cover.Summary(os.Stderr, profiles)
+	}
+	if *c.profile != "" {
+		out, err := os.Create(*c.profile)
+		if err != nil {
+			return err
+		}
+		if err := cover.LCOV(out, profiles); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+	if *c.annotate != "" {
+		out, err := os.Create(*c.annotate)
+		if err != nil {
+			return err
+		}
+		for _, p := range profiles {
+			p.Annotate(out)
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"interpreter/tester"
+	"os"
+	"regexp"
+)
+
+// monkey test [--run regexp] [--format human|junit] [-v] [--cover ...] [path ...]
+// Runs the tests of the *_test.mk files found under the paths, the current
+// directory when none is given. --run keeps the tests whose name matches the
+// regular expression. The exit status is 1 when a test fails and 2 when a
+// file cannot be run. The coverage options are those of run, only the test
+// files are covered.
+func cmdTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	run := flags.String("run", "", "run only the tests whose name matches this regular expression")
+	format := flags.String("format", "human", "report format, human or junit")
+	verbose := flags.Bool("v", false, "list the tests that pass too")
+	coverage := addCoverFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey test: bad --run: %s\n", err)
+			return 2
+		}
+	}
+	if *format != "human" && *format != "junit" {
+		fmt.Fprintf(os.Stderr, "monkey test: unknown format %q\n", *format)
+		return 2
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := tester.Discover(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey test: %s\n", err)
+		return 2
+	}
+
+	counts := coverage.start()
+	status := 0
+	results := []*tester.FileResult{}
+	covered := []coveredFile{}
+	for _, path := range files {
+		result := tester.RunFile(path, filter, counts)
+		switch {
+		case result.Err != nil:
+			status = 2
+		case result.Failed() > 0 && status == 0:
+			status = 1
+		}
+		results = append(results, result)
+		if result.Program != nil {
+			covered = append(covered, coveredFile{path, result.Source, result.Program})
+		}
+	}
+	if *format == "junit" {
+		if err := tester.JUnit(os.Stdout, results); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey test: %s\n", err)
+			return 2
+		}
+	} else {
+		if len(files) == 0 {
+			fmt.Println("no test files")
+		}
+		tester.Report(os.Stdout, results, *verbose)
+	}
+	if err := coverage.report(covered); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey test: %s\n", err)
+		return 2
+	}
+	return status
+}
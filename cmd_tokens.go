@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"interpreter/lexer"
+	"os"
+)
+
+// monkey tokens [--json] file.mk
+// Prints the tokens the lexer reads from file.mk, one per line with its
+// position, type and literal, or as a JSON array with --json. The errors of
+// the lexer go to the standard error and make the exit status 1, a file
+// that cannot be read gives 2.
+func cmdTokens(args []string) int {
+	flags := flag.NewFlagSet("tokens", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the tokens as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey tokens [--json] file.mk")
+		return 2
+	}
+	path := flags.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey tokens: %s\n", err)
+		return 2
+	}
+
+	tokens, errs := lexer.Tokenize(string(src))
+	if *asJSON {
+		out, err := json.MarshalIndent(tokens, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey tokens: %s\n", err)
+			return 2
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, token := range tokens {
+			fmt.Printf("%d:%d\t%s\t%q\n", token.Line, token.Column, token.Type, token.Literal)
+		}
+	}
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", path, err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	return 0
+}
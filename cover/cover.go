@@ -0,0 +1,183 @@
+/*
+	Report statement coverage of Monkey programs.
+		- the evaluator counts the statements it runs in the Coverage of its
+		  evaluator.Context, a Profile ties those counts to the lines of one
+		  file
+		- a line is covered when a statement starting on it ran, and partly
+		  covered when another statement starting on it did not, as the
+		  branch of an if written on one line
+	Summary, Annotate and LCOV write the profiles for people and for
+	coverage viewers.
+*/
+package cover
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Line is the coverage of one line with statements starting on it
+type Line struct {
+	Number int
+	// Hits is the most times a statement on the line ran
+	Hits int
+	// Partial is set when a statement on the line never ran while another
+	// one did
+	Partial bool
+}
+
+// Profile is the coverage of one file
+type Profile struct {
+	Path       string
+	source     []string
+	statements int
+	covered    int
+	lines      []Line // in order
+}
+
+// New builds the profile of program, parsed from src, from the counts of c
+func New(path string, src string, program *ast.Program, c *evaluator.Coverage) *Profile {
+	p := &Profile{Path: path, source: strings.Split(strings.TrimSuffix(src, "\n"), "\n")}
+	byLine := make(map[int]*Line)
+	count := func(statements []ast.Statement) {
+		for _, statement := range statements {
+			number := lineOf(statement)
+			if number == 0 {
+				continue
+			}
+			hits := c.Hits(statement)
+			p.statements++
+			if hits > 0 {
+				p.covered++
+			}
+			line, ok := byLine[number]
+			if !ok {
+				byLine[number] = &Line{Number: number, Hits: hits}
+				continue
+			}
+			if (hits == 0) != (line.Hits == 0) {
+				line.Partial = true
+			}
+			if hits > line.Hits {
+				line.Hits = hits
+			}
+		}
+	}
+	// the statements of the program and its blocks are the ones the
+	// evaluator counts
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			count(node.Statements)
+		case *ast.BlockStatement:
+			count(node.Statements)
+		}
+		return true
+	})
+	for _, line := range byLine {
+		p.lines = append(p.lines, *line)
+	}
+	sort.Slice(p.lines, func(i, j int) bool { return p.lines[i].Number < p.lines[j].Number })
+	return p
+}
+
+func lineOf(statement ast.Statement) int {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		return statement.Token.Line
+	case *ast.ReturnStatement:
+		return statement.Token.Line
+	case *ast.ExpressionStatement:
+		return statement.Token.Line
+	case *ast.FunctionDeclaration:
+		return statement.Token.Line
+	case *ast.StructStatement:
+		return statement.Token.Line
+	case *ast.ImplStatement:
+		return statement.Token.Line
+	case *ast.EnumStatement:
+		return statement.Token.Line
+	}
+	return 0
+}
+
+// Lines returns the lines with statements in order
+func (p *Profile) Lines() []Line {
+	return p.lines
+}
+
+// Statements returns how many statements ran and how many there are
+func (p *Profile) Statements() (covered int, total int) {
+	return p.covered, p.statements
+}
+
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+// Summary writes the share of statements run in each file and in all of them
+func Summary(w io.Writer, profiles []*Profile) {
+	covered, total := 0, 0
+	for _, p := range profiles {
+		c, t := p.Statements()
+		covered += c
+		total += t
+		fmt.Fprintf(w, "%s\t%.1f%% of statements (%d/%d)\n", p.Path, percent(c, t), c, t)
+	}
+	if len(profiles) > 1 {
+		fmt.Fprintf(w, "total\t%.1f%% of statements (%d/%d)\n", percent(covered, total), covered, total)
+	}
+}
+
+// Annotate writes the source of the file with how many times each line ran
+// in front of it. A line that never ran is marked with ####, a partly
+// covered line with a ! after its count, and a line without a statement has
+// no count.
+func (p *Profile) Annotate(w io.Writer) {
+	fmt.Fprintf(w, "%s:\n", p.Path)
+	lines := p.lines
+	for i, text := range p.source {
+		count := ""
+		if len(lines) > 0 && lines[0].Number == i+1 {
+			switch line := lines[0]; {
+			case line.Hits == 0:
+				count = "####"
+			case line.Partial:
+				count = fmt.Sprintf("%d!", line.Hits)
+			default:
+				count = fmt.Sprint(line.Hits)
+			}
+			lines = lines[1:]
+		}
+		fmt.Fprintf(w, "%8s  %4d  %s\n", count, i+1, text)
+	}
+}
+
+// LCOV writes the profiles in the LCOV tracefile format, a record for each
+// file with the hits of its lines
+func LCOV(w io.Writer, profiles []*Profile) error {
+	for _, p := range profiles {
+		var out strings.Builder
+		out.WriteString("TN:\n")
+		out.WriteString("SF:" + p.Path + "\n")
+		hit := 0
+		for _, line := range p.lines {
+			fmt.Fprintf(&out, "DA:%d,%d\n", line.Number, line.Hits)
+			if line.Hits > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(&out, "LF:%d\nLH:%d\nend_of_record\n", len(p.lines), hit)
+		if _, err := io.WriteString(w, out.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
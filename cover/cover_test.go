@@ -0,0 +1,117 @@
+package cover
+
+import (
+	"bytes"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"interpreter/resolver"
+	"testing"
+)
+
+const src = `let sign = fn(x) { if (x < 0) { "neg" } else { "pos" } };
+fn abs(x) {
+    if (x < 0) {
+        return -x;
+    }
+    x
+}
+sign(3);
+abs(4) + abs(5)
+`
+
+func profile(t *testing.T, path string, src string) *Profile {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	r := resolver.New(evaluator.NewGlobalScope())
+	r.Resolve(program)
+	if len(r.Errors()) > 0 {
+		t.Fatalf("resolver errors: %v", r.Errors())
+	}
+	counts := evaluator.NewCoverage()
+	evaluator.Eval(program, evaluator.NewEnvironment(&evaluator.Context{Coverage: counts}))
+	return New(path, src, program, counts)
+}
+
+func TestProfile(t *testing.T) {
+	p := profile(t, "abs.mk", src)
+	want := []Line{
+		{Number: 1, Hits: 1, Partial: true},
+		{Number: 2, Hits: 1},
+		{Number: 3, Hits: 2},
+		{Number: 4, Hits: 0},
+		{Number: 6, Hits: 2},
+		{Number: 8, Hits: 1},
+		{Number: 9, Hits: 1},
+	}
+	lines := p.Lines()
+	if len(lines) != len(want) {
+		t.Fatalf("lines wrong. got=%+v", lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d wrong. got=%+v, want=%+v", want[i].Number, lines[i], want[i])
+		}
+	}
+	if covered, total := p.Statements(); covered != 8 || total != 10 {
+		t.Errorf("statements wrong. got=%d/%d", covered, total)
+	}
+}
+
+func TestReports(t *testing.T) {
+	profiles := []*Profile{profile(t, "abs.mk", src), profile(t, "one.mk", "1")}
+
+	var out bytes.Buffer
+	Summary(&out, profiles)
+	want := "abs.mk\t80.0% of statements (8/10)\none.mk\t100.0% of statements (1/1)\ntotal\t81.8% of statements (9/11)\n"
+	if out.String() != want {
+		t.Errorf("summary wrong.\ngot=\n%s\nwant=\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	profiles[0].Annotate(&out)
+	want = `abs.mk:
+      1!     1  let sign = fn(x) { if (x < 0) { "neg" } else { "pos" } };
+       1     2  fn abs(x) {
+       2     3      if (x < 0) {
+    ####     4          return -x;
+             5      }
+       2     6      x
+             7  }
+       1     8  sign(3);
+       1     9  abs(4) + abs(5)
+`
+	if out.String() != want {
+		t.Errorf("annotated source wrong.\ngot=\n%s\nwant=\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := LCOV(&out, profiles); err != nil {
+		t.Fatal(err)
+	}
+	want = `TN:
+SF:abs.mk
+DA:1,1
+DA:2,1
+DA:3,2
+DA:4,0
+DA:6,2
+DA:8,1
+DA:9,1
+LF:7
+LH:6
+end_of_record
+TN:
+SF:one.mk
+DA:1,1
+LF:1
+LH:1
+end_of_record
+`
+	if out.String() != want {
+		t.Errorf("lcov wrong.\ngot=\n%s\nwant=\n%s", out.String(), want)
+	}
+}
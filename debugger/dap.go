@@ -0,0 +1,482 @@
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"interpreter/object2"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The parts of the Debug Adapter Protocol the server speaks, see
+// https://microsoft.github.io/debug-adapter-protocol/specification
+// Monkey has one thread, its id is always 1.
+
+type dapMessage struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    bool            `json:"success,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+}
+
+type dapResponse struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+type dapSource struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type dapStackFrame struct {
+	ID     int       `json:"id"`
+	Name   string    `json:"name"`
+	Source dapSource `json:"source"`
+	Line   int       `json:"line"`
+	Column int       `json:"column"`
+}
+
+type dapScope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+type dapBreakpoint struct {
+	Verified bool `json:"verified"`
+	Line     int  `json:"line,omitempty"`
+}
+
+// a variables reference names the locals of a frame or the globals, the
+// references are only good until the program goes on
+type variablesRef struct {
+	frame   int
+	globals bool
+}
+
+type dapServer struct {
+	in  *textproto.Reader
+	out io.Writer
+
+	mu      sync.Mutex // guards the fields below and the writes
+	seq     int
+	session *Session
+	path    string
+	paused  bool
+	refs    []variablesRef
+	quit    bool
+	// a resumed program is signalled on resume
+	resume chan struct{}
+	// closed when the program has ended
+	done chan struct{}
+}
+
+// ServeDAP debugs one program for a client speaking the Debug Adapter
+// Protocol on in and out. It returns when the client disconnects.
+func ServeDAP(in io.Reader, out io.Writer) error {
+	d := &dapServer{
+		in:     textproto.NewReader(bufio.NewReader(in)),
+		out:    out,
+		resume: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	for {
+		msg, err := d.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Type != "request" {
+			continue
+		}
+		body, err := d.handle(msg)
+		if err != nil {
+			d.send(&dapResponse{Type: "response", RequestSeq: msg.Seq, Command: msg.Command, Message: err.Error()})
+		} else {
+			d.send(&dapResponse{Type: "response", RequestSeq: msg.Seq, Success: true, Command: msg.Command, Body: body})
+		}
+		if msg.Command == "disconnect" || msg.Command == "terminate" {
+			d.mu.Lock()
+			paused := d.paused
+			d.mu.Unlock()
+			d.after(msg.Command)
+			if paused {
+				// the program ends at once, it is waiting to go on
+				<-d.done
+			}
+			return nil
+		}
+		d.after(msg.Command)
+	}
+}
+
+func (d *dapServer) read() (*dapMessage, error) {
+	header, err := d.in.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(d.in.R, body); err != nil {
+		return nil, err
+	}
+	msg := &dapMessage{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// send numbers and writes a response or an event
+func (d *dapServer) send(msg interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seq++
+	switch msg := msg.(type) {
+	case *dapResponse:
+		msg.Seq = d.seq
+	case *dapEvent:
+		msg.Seq = d.seq
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(d.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (d *dapServer) event(name string, body interface{}) {
+	d.send(&dapEvent{Type: "event", Event: name, Body: body})
+}
+
+func (d *dapServer) handle(msg *dapMessage) (interface{}, error) {
+	switch msg.Command {
+	case "initialize":
+		return map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		return nil, d.launch(msg.Arguments)
+	case "setBreakpoints":
+		return d.setBreakpoints(msg.Arguments)
+	case "configurationDone":
+		if d.session == nil {
+			return nil, errors.New("no program launched")
+		}
+		go d.run()
+		return nil, nil
+	case "threads":
+		return map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": 1, "name": "main"}},
+		}, nil
+	case "stackTrace":
+		return d.stackTrace()
+	case "scopes":
+		return d.scopes(msg.Arguments)
+	case "variables":
+		return d.variables(msg.Arguments)
+	case "evaluate":
+		return d.evaluate(msg.Arguments)
+	case "continue":
+		return map[string]bool{"allThreadsContinued": true}, d.step(d.session.Continue)
+	case "next":
+		return nil, d.step(d.session.StepOver)
+	case "stepIn":
+		return nil, d.step(d.session.StepIn)
+	case "stepOut":
+		return nil, d.step(d.session.StepOut)
+	case "pause":
+		if d.session == nil {
+			return nil, errors.New("no program launched")
+		}
+		d.session.Pause()
+		return nil, nil
+	case "disconnect", "terminate":
+		d.mu.Lock()
+		d.quit = true
+		d.mu.Unlock()
+		if d.session != nil {
+			d.session.ClearBreakpoints()
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request: %s", msg.Command)
+}
+
+// after does what has to follow the response to command
+func (d *dapServer) after(command string) {
+	switch command {
+	case "launch":
+		if d.session != nil {
+			// the client sends its breakpoints now, then configurationDone
+			d.event("initialized", nil)
+		}
+	case "continue", "next", "stepIn", "stepOut", "disconnect", "terminate":
+		d.mu.Lock()
+		paused := d.paused
+		d.paused = false
+		d.refs = nil
+		d.mu.Unlock()
+		if paused {
+			d.resume <- struct{}{}
+		}
+	}
+}
+
+func (d *dapServer) launch(arguments json.RawMessage) error {
+	var args struct {
+		Program     string `json:"program"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return err
+	}
+	src, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+	session, err := NewSession(string(src), args.StopOnEntry, d.stopped)
+	if err != nil {
+		return err
+	}
+	d.session = session
+	d.path = args.Program
+	return nil
+}
+
+func (d *dapServer) setBreakpoints(arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if d.session == nil {
+		return nil, errors.New("no program launched")
+	}
+	d.session.ClearBreakpoints()
+	breakpoints := []dapBreakpoint{}
+	for _, bp := range args.Breakpoints {
+		line, ok := d.session.SetBreakpoint(bp.Line)
+		breakpoints = append(breakpoints, dapBreakpoint{Verified: ok, Line: line})
+	}
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+// run evaluates the program, its output goes to the client as output events
+func (d *dapServer) run() {
+	defer close(d.done)
+	exitCode := d.runProgram()
+	d.mu.Lock()
+	quit := d.quit
+	d.mu.Unlock()
+	if !quit {
+		d.event("exited", map[string]int{"exitCode": exitCode})
+		d.event("terminated", nil)
+	}
+}
+
+// runProgram returns the exit code of the program, what it prints is sent
+// as output events
+func (d *dapServer) runProgram() (exitCode int) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(errQuit); !ok {
+				panic(r)
+			}
+		}
+	}()
+	result := d.session.Run(&dapOutput{d: d, category: "stdout"})
+	if err, ok := result.(*object2.Error); ok {
+		d.event("output", map[string]string{"category": "stderr", "output": "error: " + err.Message + "\n"})
+		return 1
+	}
+	return 0
+}
+
+// stopped runs on the goroutine of the program, it waits there until the
+// client lets the program go on
+func (d *dapServer) stopped(reason string) {
+	d.mu.Lock()
+	if d.quit {
+		d.mu.Unlock()
+		panic(errQuit{})
+	}
+	d.paused = true
+	d.mu.Unlock()
+	d.event("stopped", map[string]interface{}{"reason": reason, "threadId": 1, "allThreadsStopped": true})
+	<-d.resume
+	d.mu.Lock()
+	quit := d.quit
+	d.mu.Unlock()
+	if quit {
+		panic(errQuit{})
+	}
+}
+
+// step chooses where the program stops next, the program goes on once the
+// response is sent
+func (d *dapServer) step(choose func()) error {
+	if d.session == nil {
+		return errors.New("no program launched")
+	}
+	d.mu.Lock()
+	paused := d.paused
+	d.mu.Unlock()
+	if !paused {
+		return errors.New("the program is not stopped")
+	}
+	choose()
+	return nil
+}
+
+// whilePaused makes sure the frames do not change under a request
+func (d *dapServer) whilePaused() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.session == nil || !d.paused {
+		return errors.New("the program is not stopped")
+	}
+	return nil
+}
+
+func (d *dapServer) stackTrace() (interface{}, error) {
+	if err := d.whilePaused(); err != nil {
+		return nil, err
+	}
+	frames := []dapStackFrame{}
+	for i, frame := range d.session.Frames() {
+		frames = append(frames, dapStackFrame{
+			ID:     i,
+			Name:   frame.Name,
+			Source: dapSource{Name: filepath.Base(d.path), Path: d.path},
+			Line:   frame.Line,
+			Column: 1,
+		})
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+func (d *dapServer) scopes(arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if err := d.whilePaused(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.refs = append(d.refs, variablesRef{frame: args.FrameID}, variablesRef{globals: true})
+	locals, globals := len(d.refs)-1, len(d.refs)
+	d.mu.Unlock()
+	return map[string]interface{}{"scopes": []dapScope{
+		{Name: "Locals", VariablesReference: locals},
+		{Name: "Globals", VariablesReference: globals},
+	}}, nil
+}
+
+func (d *dapServer) variables(arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if err := d.whilePaused(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	if args.VariablesReference < 1 || args.VariablesReference > len(d.refs) {
+		d.mu.Unlock()
+		return nil, fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+	ref := d.refs[args.VariablesReference-1]
+	d.mu.Unlock()
+
+	var vars []Variable
+	if ref.globals {
+		vars = d.session.Globals()
+	} else {
+		var err error
+		if vars, err = d.session.Locals(ref.frame); err != nil {
+			return nil, err
+		}
+	}
+	variables := []dapVariable{}
+	for _, v := range vars {
+		variables = append(variables, dapVariable{Name: v.Name, Value: v.Value.Inspect(), Type: string(v.Value.Type())})
+	}
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+func (d *dapServer) evaluate(arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		Expression string `json:"expression"`
+		FrameID    int    `json:"frameId"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if err := d.whilePaused(); err != nil {
+		return nil, err
+	}
+	value, err := d.session.Evaluate(args.FrameID, args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"result": value.Inspect(), "type": string(value.Type()), "variablesReference": 0}, nil
+}
+
+// dapOutput hands what the program writes to the client
+type dapOutput struct {
+	d        *dapServer
+	category string
+}
+
+func (o *dapOutput) Write(p []byte) (int, error) {
+	o.d.event("output", map[string]string{"category": o.category, "output": string(p)})
+	return len(p), nil
+}
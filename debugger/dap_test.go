@@ -0,0 +1,313 @@
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// dapClient drives ServeDAP over pipes the way an editor would. Messages are
+// read as soon as they are sent, a pipe has no buffer.
+type dapClient struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan *dapMessage
+	seq      int
+	done     chan error
+	// events received while waiting for responses
+	events []*dapMessage
+}
+
+func newDAPClient(t *testing.T) *dapClient {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	c := &dapClient{
+		t:        t,
+		in:       clientOut,
+		messages: make(chan *dapMessage, 100),
+		done:     make(chan error, 1),
+	}
+	go func() {
+		c.done <- ServeDAP(serverIn, serverOut)
+		serverOut.Close()
+	}()
+	go func() {
+		out := textproto.NewReader(bufio.NewReader(clientIn))
+		defer close(c.messages)
+		for {
+			header, err := out.ReadMIMEHeader()
+			if err != nil {
+				return
+			}
+			length, _ := strconv.Atoi(header.Get("Content-Length"))
+			body := make([]byte, length)
+			if _, err := io.ReadFull(out.R, body); err != nil {
+				return
+			}
+			msg := &dapMessage{}
+			if err := json.Unmarshal(body, msg); err != nil {
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+func (c *dapClient) receive() *dapMessage {
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("the server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("no message from the server")
+	}
+	return nil
+}
+
+// request sends command and returns its response, decoding the body into body
+func (c *dapClient) request(command string, arguments interface{}, body interface{}) *dapMessage {
+	c.seq++
+	msg, err := json.Marshal(map[string]interface{}{
+		"seq": c.seq, "type": "request", "command": command, "arguments": arguments,
+	})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	for {
+		response := c.receive()
+		if response.Type == "event" {
+			c.events = append(c.events, response)
+			continue
+		}
+		if response.RequestSeq != c.seq || response.Command != command {
+			c.t.Fatalf("response to %s %d, want %s %d", response.Command, response.RequestSeq, command, c.seq)
+		}
+		if body != nil && response.Success {
+			if err := json.Unmarshal(response.Body, body); err != nil {
+				c.t.Fatalf("invalid body %s for %s: %s", response.Body, command, err)
+			}
+		}
+		return response
+	}
+}
+
+// event waits for the next event named name, skipping the others, and
+// decodes its body into body
+func (c *dapClient) event(name string, body interface{}) {
+	for {
+		var msg *dapMessage
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.receive()
+		}
+		if msg.Type != "event" || msg.Event != name {
+			continue
+		}
+		if body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+func (c *dapClient) stopped() string {
+	var body struct {
+		Reason   string `json:"reason"`
+		ThreadID int    `json:"threadId"`
+	}
+	c.event("stopped", &body)
+	return body.Reason
+}
+
+func (c *dapClient) topFrame() dapStackFrame {
+	var trace struct {
+		StackFrames []dapStackFrame `json:"stackFrames"`
+	}
+	if r := c.request("stackTrace", map[string]int{"threadId": 1}, &trace); !r.Success {
+		c.t.Fatalf("stackTrace failed: %s", r.Message)
+	}
+	return trace.StackFrames[0]
+}
+
+func writeProgram(t *testing.T, src string) string {
+	path := filepath.Join(t.TempDir(), "prog.mk")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDAPSession(t *testing.T) {
+	path := writeProgram(t, program+"puts(y * 2);\n")
+	c := newDAPClient(t)
+
+	var capabilities map[string]bool
+	c.request("initialize", map[string]string{"adapterID": "monkey"}, &capabilities)
+	if !capabilities["supportsConfigurationDoneRequest"] {
+		t.Errorf("capabilities wrong. got=%v", capabilities)
+	}
+	if r := c.request("launch", map[string]interface{}{"program": path, "stopOnEntry": true}, nil); !r.Success {
+		t.Fatalf("launch failed: %s", r.Message)
+	}
+	c.event("initialized", nil)
+
+	var breakpoints struct {
+		Breakpoints []dapBreakpoint `json:"breakpoints"`
+	}
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      dapSource{Path: path},
+		"breakpoints": []map[string]int{{"line": 5}, {"line": 100}},
+	}, &breakpoints)
+	want := []dapBreakpoint{{Verified: true, Line: 6}, {Verified: false}}
+	if len(breakpoints.Breakpoints) != 2 || breakpoints.Breakpoints[0] != want[0] || breakpoints.Breakpoints[1] != want[1] {
+		t.Errorf("breakpoints wrong. got=%+v", breakpoints.Breakpoints)
+	}
+	c.request("configurationDone", nil, nil)
+
+	if reason := c.stopped(); reason != ReasonEntry {
+		t.Errorf("first stop wrong. got=%s", reason)
+	}
+	var threads struct {
+		Threads []struct {
+			ID int `json:"id"`
+		} `json:"threads"`
+	}
+	c.request("threads", nil, &threads)
+	if len(threads.Threads) != 1 || threads.Threads[0].ID != 1 {
+		t.Errorf("threads wrong. got=%+v", threads)
+	}
+
+	c.request("continue", map[string]int{"threadId": 1}, nil)
+	if reason := c.stopped(); reason != ReasonBreakpoint {
+		t.Errorf("stop wrong. got=%s", reason)
+	}
+	frame := c.topFrame()
+	if frame.Name != "add" || frame.Line != 6 || frame.Source.Path != path {
+		t.Errorf("frame wrong. got=%+v", frame)
+	}
+
+	var scopes struct {
+		Scopes []dapScope `json:"scopes"`
+	}
+	c.request("scopes", map[string]int{"frameId": 0}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("scopes wrong. got=%+v", scopes)
+	}
+	var variables struct {
+		Variables []dapVariable `json:"variables"`
+	}
+	c.request("variables", map[string]int{"variablesReference": scopes.Scopes[0].VariablesReference}, &variables)
+	if len(variables.Variables) != 2 || variables.Variables[0] != (dapVariable{Name: "a", Value: "6", Type: "INTEGER"}) {
+		t.Errorf("locals wrong. got=%+v", variables.Variables)
+	}
+	c.request("variables", map[string]int{"variablesReference": scopes.Scopes[1].VariablesReference}, &variables)
+	if len(variables.Variables) != 3 || variables.Variables[2] != (dapVariable{Name: "x", Value: "6", Type: "INTEGER"}) {
+		t.Errorf("globals wrong. got=%+v", variables.Variables)
+	}
+
+	var result struct {
+		Result string `json:"result"`
+	}
+	c.request("evaluate", map[string]interface{}{"expression": "a * b + x", "frameId": 0}, &result)
+	if result.Result != "12" {
+		t.Errorf("evaluate wrong. got=%s", result.Result)
+	}
+	if r := c.request("evaluate", map[string]interface{}{"expression": "nope", "frameId": 0}, nil); r.Success || r.Message != "identifier not found: nope" {
+		t.Errorf("evaluate error wrong. got=%+v", r)
+	}
+
+	c.request("next", map[string]int{"threadId": 1}, nil)
+	if reason := c.stopped(); reason != ReasonStep || c.topFrame().Line != 7 {
+		t.Errorf("next stopped wrong. got=%s", reason)
+	}
+	c.request("stepOut", map[string]int{"threadId": 1}, nil)
+	if reason := c.stopped(); reason != ReasonStep || c.topFrame().Line != 11 {
+		t.Errorf("stepOut stopped wrong. got=%s", reason)
+	}
+
+	c.request("continue", map[string]int{"threadId": 1}, nil)
+	var output struct {
+		Category string `json:"category"`
+		Output   string `json:"output"`
+	}
+	c.event("output", &output)
+	if output.Category != "stdout" || output.Output != "14\n" {
+		t.Errorf("output wrong. got=%+v", output)
+	}
+	var exited struct {
+		ExitCode int `json:"exitCode"`
+	}
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("exit code wrong. got=%d", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("server failed: %s", err)
+	}
+}
+
+func TestDAPPauseAndDisconnect(t *testing.T) {
+	// runs until it is paused
+	path := writeProgram(t, "let loop = fn(n) { loop(n + 1) };\nloop(0)\n")
+	c := newDAPClient(t)
+	c.request("initialize", nil, nil)
+	c.request("launch", map[string]interface{}{"program": path}, nil)
+	c.request("configurationDone", nil, nil)
+
+	if r := c.request("next", map[string]int{"threadId": 1}, nil); r.Success {
+		t.Error("step accepted while running")
+	}
+	c.request("pause", map[string]int{"threadId": 1}, nil)
+	if reason := c.stopped(); reason != ReasonPause {
+		t.Errorf("pause stopped wrong. got=%s", reason)
+	}
+	if frame := c.topFrame(); frame.Line != 1 {
+		t.Errorf("paused frame wrong. got=%+v", frame)
+	}
+	c.request("disconnect", nil, nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			t.Errorf("server failed: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+}
+
+func TestDAPErrors(t *testing.T) {
+	c := newDAPClient(t)
+	c.request("initialize", nil, nil)
+	if r := c.request("launch", map[string]string{"program": "/does/not/exist.mk"}, nil); r.Success {
+		t.Error("launch of a missing file succeeded")
+	}
+	path := writeProgram(t, "let x = ;")
+	if r := c.request("launch", map[string]string{"program": path}, nil); r.Success {
+		t.Error("launch of a broken program succeeded")
+	}
+	if r := c.request("configurationDone", nil, nil); r.Success {
+		t.Error("configurationDone without a program succeeded")
+	}
+	if r := c.request("stepBack", nil, nil); r.Success || r.Message != "unsupported request: stepBack" {
+		t.Errorf("unknown request answered with %+v", r)
+	}
+	c.request("disconnect", nil, nil)
+	<-c.done
+}
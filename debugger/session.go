@@ -0,0 +1,422 @@
+/*
+	Step through Monkey programs.
+		- a Session runs one program with the hooks of its evaluator context
+		  and stops at breakpoints, after steps and on request
+		- while it is stopped the frames, their variables and any expression
+		  evaluated in a frame can be looked at
+		- RunTerminal drives a session from a command line, ServeDAP from an
+		  editor speaking the Debug Adapter Protocol
+	Sessions do not share their hooks, several can run at a time.
+*/
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object2"
+	"interpreter/parser"
+	"interpreter/resolver"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Stop reasons
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+type mode int
+
+const (
+	running mode = iota
+	stepIn
+	stepOver
+	stepOut
+)
+
+// Frame is a function call in progress, the program itself is the
+// outermost frame
+type Frame struct {
+	Name string
+	// line of the statement running or about to run
+	Line int
+	// environment of that statement, a match arm has one of its own
+	Env *object2.Environment
+}
+
+// Variable is a name bound in a frame with the value it holds
+type Variable struct {
+	Name  string
+	Value object2.Object
+}
+
+type Session struct {
+	mu          sync.Mutex
+	program     *ast.Program
+	lines       []string
+	statements  map[int]bool // lines where a statement starts
+	breakpoints map[int]bool
+	frames      []*Frame // innermost last
+	mode        mode
+	target      int // number of frames a step over or out compares with
+	pause       bool
+	entry       bool
+	evaluating  bool
+	// stopped is called when the program stops, the program goes on when
+	// it returns. It is called without the lock held, the session can be
+	// inspected and a step chosen from it.
+	stopped func(reason string)
+}
+
+// NewSession parses and resolves src, it returns the errors found as one
+// error. With stopOnEntry the program stops before its first statement.
+func NewSession(src string, stopOnEntry bool, stopped func(reason string)) (*Session, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+	r := resolver.New(evaluator.NewGlobalScope())
+	r.Resolve(program)
+	if len(r.Errors()) > 0 {
+		return nil, errors.New(strings.Join(r.Errors(), "\n"))
+	}
+	s := &Session{
+		program:     program,
+		lines:       strings.Split(src, "\n"),
+		statements:  make(map[int]bool),
+		breakpoints: make(map[int]bool),
+		entry:       stopOnEntry,
+		stopped:     stopped,
+	}
+	if stopOnEntry {
+		s.mode = stepIn
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if statement, ok := node.(ast.Statement); ok && stops(statement) {
+			s.statements[lineOf(statement)] = true
+		}
+		return true
+	})
+	return s, nil
+}
+
+// Run evaluates the program with the hooks of the session and returns its
+// result, what the program prints goes to stdout
+func (s *Session) Run(stdout io.Writer) object2.Object {
+	env := evaluator.NewEnvironment(&evaluator.Context{
+		Stdout:    stdout,
+		Statement: s.statement,
+		Call:      s.call,
+		Return:    s.ret,
+	})
+	s.mu.Lock()
+	s.frames = []*Frame{{Name: "<main>", Env: env}}
+	s.mu.Unlock()
+	return evaluator.Eval(s.program, env)
+}
+
+// a function declaration is bound before its block runs and a block is not
+// run as a statement of its own, there is nothing to stop at
+func stops(statement ast.Statement) bool {
+	_, ok := statement.(*ast.FunctionDeclaration)
+	return !ok && lineOf(statement) > 0
+}
+
+func lineOf(statement ast.Statement) int {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		return statement.Token.Line
+	case *ast.ReturnStatement:
+		return statement.Token.Line
+	case *ast.ExpressionStatement:
+		return statement.Token.Line
+	case *ast.FunctionDeclaration:
+		return statement.Token.Line
+	case *ast.StructStatement:
+		return statement.Token.Line
+	case *ast.ImplStatement:
+		return statement.Token.Line
+	case *ast.EnumStatement:
+		return statement.Token.Line
+	}
+	return 0
+}
+
+func (s *Session) statement(node ast.Statement, env *object2.Environment) {
+	if !stops(node) {
+		return
+	}
+	s.mu.Lock()
+	if s.evaluating {
+		s.mu.Unlock()
+		return
+	}
+	line := lineOf(node)
+	frame := s.frames[len(s.frames)-1]
+	moved := line != frame.Line
+	frame.Line = line
+	frame.Env = env
+	depth := len(s.frames)
+	reason := ""
+	switch {
+	case s.entry:
+		reason = ReasonEntry
+	case s.pause:
+		reason = ReasonPause
+	case s.mode == stepIn,
+		s.mode == stepOver && depth <= s.target,
+		s.mode == stepOut && depth < s.target:
+		reason = ReasonStep
+	case moved && s.breakpoints[line]:
+		reason = ReasonBreakpoint
+	}
+	if reason == "" {
+		s.mu.Unlock()
+		return
+	}
+	s.entry = false
+	s.pause = false
+	s.mode = running
+	s.mu.Unlock()
+	s.stopped(reason)
+}
+
+func (s *Session) call(fn *object2.Function, env *object2.Environment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.evaluating {
+		return
+	}
+	name := fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	s.frames = append(s.frames, &Frame{Name: name, Env: env})
+}
+
+func (s *Session) ret(fn *object2.Function) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.evaluating || len(s.frames) == 1 {
+		return
+	}
+	s.frames = s.frames[:len(s.frames)-1]
+}
+
+// SetBreakpoint stops the program at the first statement on line or after
+// it, and returns the line it stops at. It reports false when no statement
+// starts there or later.
+func (s *Session) SetBreakpoint(line int) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ; line <= len(s.lines); line++ {
+		if s.statements[line] {
+			s.breakpoints[line] = true
+			return line, true
+		}
+	}
+	return 0, false
+}
+
+func (s *Session) ClearBreakpoint(line int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.breakpoints, line)
+}
+
+func (s *Session) ClearBreakpoints() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breakpoints = make(map[int]bool)
+}
+
+// Breakpoints returns the lines with a breakpoint in order
+func (s *Session) Breakpoints() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := []int{}
+	for line := range s.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// The steps, called while the program is stopped they choose where it stops
+// next once it goes on
+
+func (s *Session) Continue() {
+	s.setMode(running)
+}
+
+// StepIn stops at the next statement, inside a call if there is one
+func (s *Session) StepIn() {
+	s.setMode(stepIn)
+}
+
+// StepOver stops at the next statement of the current function or its callers
+func (s *Session) StepOver() {
+	s.setMode(stepOver)
+}
+
+// StepOut stops at the next statement after the current function returns
+func (s *Session) StepOut() {
+	s.setMode(stepOut)
+}
+
+func (s *Session) setMode(m mode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mode = m
+	s.target = len(s.frames)
+}
+
+// Pause stops the running program at its next statement
+func (s *Session) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pause = true
+}
+
+// Frames returns the frames innermost first
+func (s *Session) Frames() []Frame {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	frames := make([]Frame, len(s.frames))
+	for i, frame := range s.frames {
+		frames[len(s.frames)-1-i] = *frame
+	}
+	return frames
+}
+
+// Line returns the text of line, empty when there is no such line
+func (s *Session) Line(line int) string {
+	if line < 1 || line > len(s.lines) {
+		return ""
+	}
+	return s.lines[line-1]
+}
+
+// Lines is the number of lines of the source
+func (s *Session) Lines() int {
+	return len(s.lines)
+}
+
+func (s *Session) frame(index int) (*Frame, error) {
+	if index < 0 || index >= len(s.frames) {
+		return nil, fmt.Errorf("no frame %d", index)
+	}
+	return s.frames[len(s.frames)-1-index], nil
+}
+
+// Locals returns the variables of frame index, innermost first, walking out
+// through the enclosing environments up to the globals. A name hidden by an
+// inner binding is left out.
+func (s *Session) Locals(index int) ([]Variable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	frame, err := s.frame(index)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	vars := []Variable{}
+	for env := frame.Env; env != nil && env.Outer() != nil; env = env.Outer() {
+		vars = append(vars, variables(env, seen)...)
+	}
+	return vars, nil
+}
+
+// Globals returns the variables of the program
+func (s *Session) Globals() []Variable {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	env := s.frames[0].Env
+	for env.Outer() != nil {
+		env = env.Outer()
+	}
+	return variables(env, make(map[string]bool))
+}
+
+func variables(env *object2.Environment, seen map[string]bool) []Variable {
+	vars := []Variable{}
+	for slot, name := range env.Names() {
+		value, ok := env.Get(0, slot)
+		if name == "" || seen[name] || !ok {
+			continue
+		}
+		seen[name] = true
+		vars = append(vars, Variable{Name: name, Value: value})
+	}
+	return vars
+}
+
+// Evaluate evaluates src in frame index, the names of the frame are in
+// scope. Statements are allowed, a let binds in the frame. Nothing stops
+// while it runs.
+func (s *Session) Evaluate(index int, src string) (object2.Object, error) {
+	s.mu.Lock()
+	frame, err := s.frame(index)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	env := frame.Env
+	s.evaluating = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.evaluating = false
+		s.mu.Unlock()
+	}()
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+	r := resolver.New(scopeOf(env))
+	r.AllowRedeclaration()
+	r.Resolve(program)
+	if len(r.Errors()) > 0 {
+		return nil, errors.New(strings.Join(r.Errors(), "\n"))
+	}
+	result := evaluator.Eval(program, env)
+	if result == nil {
+		result = evaluator.NULL
+	}
+	if err, ok := result.(*object2.Error); ok {
+		return nil, errors.New(err.Message)
+	}
+	return result, nil
+}
+
+// scopeOf rebuilds the resolver scopes of env from the names of its slots,
+// so an identifier resolves to the slot that holds its value
+func scopeOf(env *object2.Environment) *resolver.Scope {
+	var scope *resolver.Scope
+	if env.Outer() == nil {
+		scope = evaluator.NewGlobalScope()
+	} else {
+		scope = resolver.NewScope(scopeOf(env.Outer()))
+	}
+	defined := make(map[string]bool)
+	for slot, name := range env.Names() {
+		if name == "" || defined[name] {
+			// keeps the slots in step, no identifier can have this name
+			name = fmt.Sprintf("$%d", slot)
+		}
+		defined[name] = true
+		scope.Define(name)
+	}
+	return scope
+}
@@ -0,0 +1,270 @@
+package debugger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const program = `let double = fn(n) {
+    let twice = n * 2;
+    twice
+};
+fn add(a, b) {
+    let sum = a + b;
+    sum
+}
+let x = double(3);
+let y = add(x, 1);
+y
+`
+
+// runSteps runs src and returns where it stopped, choosing the next step
+// from the steps given in turn, then continuing
+func runSteps(t *testing.T, src string, entry bool, breakpoints []int, steps ...func(*Session)) []string {
+	var session *Session
+	seen := []string{}
+	session, err := NewSession(src, entry, func(reason string) {
+		frame := session.Frames()[0]
+		seen = append(seen, fmt.Sprintf("%s %s:%d", reason, frame.Name, frame.Line))
+		if len(steps) == 0 {
+			session.Continue()
+			return
+		}
+		steps[0](session)
+		steps = steps[1:]
+	})
+	if err != nil {
+		t.Fatalf("NewSession failed: %s", err)
+	}
+	for _, line := range breakpoints {
+		session.SetBreakpoint(line)
+	}
+	session.Run(io.Discard)
+	return seen
+}
+
+func TestStepping(t *testing.T) {
+	in := (*Session).StepIn
+	over := (*Session).StepOver
+	out := (*Session).StepOut
+	tests := []struct {
+		name        string
+		entry       bool
+		breakpoints []int
+		steps       []func(*Session)
+		want        []string
+	}{
+		{"entry", true, nil, nil, []string{"entry <main>:1"}},
+		{"step over", true, nil, []func(*Session){over, over, over},
+			[]string{"entry <main>:1", "step <main>:9", "step <main>:10", "step <main>:11"}},
+		{"step in", true, nil, []func(*Session){in, in, in},
+			[]string{"entry <main>:1", "step <main>:9", "step <anonymous>:2", "step <anonymous>:3"}},
+		{"step out", true, nil, []func(*Session){in, in, out},
+			[]string{"entry <main>:1", "step <main>:9", "step <anonymous>:2", "step <main>:10"}},
+		{"breakpoints", false, []int{6, 11}, nil, []string{"breakpoint add:6", "breakpoint <main>:11"}},
+		// no statement on line 5, the breakpoint moves to line 6
+		{"moved breakpoint", false, []int{5}, []func(*Session){over}, []string{"breakpoint add:6", "step add:7"}},
+		{"step over a breakpoint", true, []int{2}, []func(*Session){over, over},
+			[]string{"entry <main>:1", "step <main>:9", "breakpoint <anonymous>:2"}},
+	}
+	for _, tt := range tests {
+		got := runSteps(t, program, tt.entry, tt.breakpoints, tt.steps...)
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("%s: stops wrong.\ngot= %q\nwant=%q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecursionBacktrace(t *testing.T) {
+	src := `fn fact(n) {
+    if (n < 2) {
+        return 1;
+    }
+    n * fact(n - 1)
+}
+fact(3)
+`
+	var session *Session
+	var frames []Frame
+	session, err := NewSession(src, false, func(reason string) {
+		frames = session.Frames()
+		session.ClearBreakpoints()
+		session.Continue()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.SetBreakpoint(3)
+	if result := session.Run(io.Discard); result.Inspect() != "6" {
+		t.Errorf("result wrong. got=%s", result.Inspect())
+	}
+	want := []string{"fact:3", "fact:5", "fact:5", "<main>:7"}
+	got := []string{}
+	for _, frame := range frames {
+		got = append(got, fmt.Sprintf("%s:%d", frame.Name, frame.Line))
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("backtrace wrong. got=%v, want=%v", got, want)
+	}
+}
+
+func TestInspectFrames(t *testing.T) {
+	src := `let scale = 10;
+let f = fn(a) {
+    let b = a + 1;
+    match (b) {
+        n => n * scale
+    }
+};
+f(4)
+`
+	var session *Session
+	checked := false
+	session, err := NewSession(src, false, func(reason string) {
+		defer session.Continue()
+		if checked {
+			return
+		}
+		checked = true
+		locals, err := session.Locals(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := variableList(locals); got != "n=5 a=4 b=5" {
+			t.Errorf("locals wrong. got=%s", got)
+		}
+		if got := variableList(session.Globals()); got != "scale=10 f=fn(a) {\nlet b = (a + 1);match (b) { n => (n * scale) }\n}" {
+			t.Errorf("globals wrong. got=%q", got)
+		}
+		tests := []struct {
+			frame int
+			src   string
+			want  string
+		}{
+			{0, "n * scale + a", "54"},
+			{0, "let c = b * 2; c", "10"},
+			{0, "c", "10"},
+			{1, "scale", "10"},
+		}
+		for _, tt := range tests {
+			value, err := session.Evaluate(tt.frame, tt.src)
+			if err != nil {
+				t.Errorf("Evaluate(%q) failed: %s", tt.src, err)
+				continue
+			}
+			if value.Inspect() != tt.want {
+				t.Errorf("Evaluate(%q) wrong. got=%s, want=%s", tt.src, value.Inspect(), tt.want)
+			}
+		}
+		errors := []struct {
+			frame int
+			src   string
+			want  string
+		}{
+			{1, "a", "identifier not found: a"},
+			{0, "len(1)", "argument to `len` not supported, got=INTEGER"},
+			{0, "let = 1", "expected next token to be IDENT, got = instead"},
+			{2, "1", "no frame 2"},
+		}
+		for _, tt := range errors {
+			if _, err := session.Evaluate(tt.frame, tt.src); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Evaluate(%q) error wrong. got=%v, want=%s", tt.src, err, tt.want)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.SetBreakpoint(5)
+	if result := session.Run(io.Discard); result.Inspect() != "50" {
+		t.Errorf("result wrong. got=%s", result.Inspect())
+	}
+	if !checked {
+		t.Fatal("breakpoint not hit")
+	}
+}
+
+func variableList(vars []Variable) string {
+	list := []string{}
+	for _, v := range vars {
+		list = append(list, v.Name+"="+v.Value.Inspect())
+	}
+	return strings.Join(list, " ")
+}
+
+func TestSessionErrors(t *testing.T) {
+	if _, err := NewSession("let x = ;", false, nil); err == nil {
+		t.Error("parse error not reported")
+	}
+	if _, err := NewSession("y", false, nil); err == nil || err.Error() != "identifier not found: y" {
+		t.Errorf("resolver error wrong. got=%v", err)
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	input := strings.Join([]string{
+		"b 6",
+		"c",
+		"bt",
+		"locals",
+		"p a * b",
+		"frame 1",
+		"p x",
+		"n",
+		"list",
+		"c",
+	}, "\n")
+	var out bytes.Buffer
+	status := RunTerminal("prog.mk", program, strings.NewReader(input), &out)
+	if status != 0 {
+		t.Errorf("status wrong. got=%d", status)
+	}
+	want := `stopped (entry) in <main> at prog.mk:1
+>   1  let double = fn(n) {
+(debug) breakpoint at prog.mk:6
+(debug) stopped (breakpoint) in add at prog.mk:6
+>   6      let sum = a + b;
+(debug) *#0 add at prog.mk:6
+ #1 <main> at prog.mk:10
+(debug) a = 6
+b = 1
+(debug) 6
+(debug) #1 <main> at prog.mk:10
+(debug) 6
+(debug) stopped (step) in add at prog.mk:7
+>   7      sum
+(debug)     4  };
+    5  fn add(a, b) {
+    6      let sum = a + b;
+>   7      sum
+    8  }
+    9  let x = double(3);
+   10  let y = add(x, 1);
+(debug) program finished
+`
+	if out.String() != want {
+		t.Errorf("output wrong.\ngot=\n%s\nwant=\n%s", out.String(), want)
+	}
+}
+
+func TestTerminalErrors(t *testing.T) {
+	var out bytes.Buffer
+	status := RunTerminal("bad.mk", "let x = 1;\nlen(x)\n", strings.NewReader("c\n"), &out)
+	if status != 1 || !strings.HasSuffix(out.String(), "error: argument to `len` not supported, got=INTEGER\nprogram finished\n") {
+		t.Errorf("runtime error wrong. status=%d output=%q", status, out.String())
+	}
+
+	out.Reset()
+	status = RunTerminal("loop.mk", "let f = fn() { f() };\nf()\n", strings.NewReader("quit\n"), &out)
+	if status != 0 || strings.Contains(out.String(), "program finished") {
+		t.Errorf("quit wrong. status=%d output=%q", status, out.String())
+	}
+
+	out.Reset()
+	if status := RunTerminal("bad.mk", "let = 1;", strings.NewReader(""), &out); status != 2 {
+		t.Errorf("parse error status wrong. got=%d", status)
+	}
+}
@@ -0,0 +1,206 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"interpreter/object2"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const terminalHelp = `commands:
+    break N, b N      stop at line N
+    clear N           remove the breakpoint at line N
+    breakpoints       list the breakpoints
+    continue, c       run to the next breakpoint
+    step, s           step to the next statement, into calls
+    next, n           step to the next statement, over calls
+    out, o            run until the current function returns
+    print EXPR, p     evaluate EXPR in the selected frame
+    locals            list the variables of the selected frame
+    globals           list the global variables
+    backtrace, bt     list the frames, innermost first
+    frame N, f N      select frame N of the backtrace
+    list, l           show the source around the current line
+    quit, q           stop debugging
+`
+
+// terminal is a line oriented debugger reading commands from in
+type terminal struct {
+	name    string
+	in      *bufio.Scanner
+	out     io.Writer
+	session *Session
+	frame   int // selected frame, 0 is the innermost
+	quit    bool
+}
+
+// errQuit ends the program being debugged, see the quit command
+type errQuit struct{}
+
+// RunTerminal debugs src, stopping before its first statement, and returns
+// the exit status of the program: 1 when it ends with an error
+func RunTerminal(name string, src string, in io.Reader, out io.Writer) int {
+	t := &terminal{name: name, in: bufio.NewScanner(in), out: out}
+	session, err := NewSession(src, true, t.stopped)
+	if err != nil {
+		fmt.Fprintf(out, "%s:\n%s\n", name, err)
+		return 2
+	}
+	t.session = session
+
+	status := 0
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(errQuit); !ok {
+					panic(r)
+				}
+			}
+		}()
+		result := session.Run(out)
+		if err, ok := result.(*object2.Error); ok {
+			fmt.Fprintf(out, "error: %s\n", err.Message)
+			status = 1
+		}
+	}()
+	if t.quit {
+		return status
+	}
+	fmt.Fprintln(out, "program finished")
+	return status
+}
+
+// stopped reads commands until one of them lets the program go on
+func (t *terminal) stopped(reason string) {
+	t.frame = 0
+	frame := t.session.Frames()[0]
+	fmt.Fprintf(t.out, "stopped (%s) in %s at %s:%d\n", reason, frame.Name, t.name, frame.Line)
+	t.printLine(frame.Line, true)
+	for {
+		fmt.Fprint(t.out, "(debug) ")
+		if !t.in.Scan() {
+			// no more input, run to the end
+			fmt.Fprintln(t.out)
+			t.session.ClearBreakpoints()
+			t.session.Continue()
+			return
+		}
+		if t.command(strings.TrimSpace(t.in.Text())) {
+			return
+		}
+	}
+}
+
+// command runs one command line and reports whether the program goes on
+func (t *terminal) command(line string) bool {
+	name, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	switch name {
+	case "":
+		return false
+	case "continue", "c":
+		t.session.Continue()
+		return true
+	case "step", "s":
+		t.session.StepIn()
+		return true
+	case "next", "n":
+		t.session.StepOver()
+		return true
+	case "out", "o":
+		t.session.StepOut()
+		return true
+	case "quit", "q":
+		t.quit = true
+		panic(errQuit{})
+	case "break", "b":
+		n, ok := t.lineArg(arg)
+		if !ok {
+			return false
+		}
+		if at, ok := t.session.SetBreakpoint(n); ok {
+			fmt.Fprintf(t.out, "breakpoint at %s:%d\n", t.name, at)
+		} else {
+			fmt.Fprintf(t.out, "no statement at or after line %d\n", n)
+		}
+	case "clear":
+		if n, ok := t.lineArg(arg); ok {
+			t.session.ClearBreakpoint(n)
+		}
+	case "breakpoints":
+		for _, n := range t.session.Breakpoints() {
+			fmt.Fprintf(t.out, "%s:%d\n", t.name, n)
+		}
+	case "print", "p":
+		value, err := t.session.Evaluate(t.frame, arg)
+		if err != nil {
+			fmt.Fprintf(t.out, "error: %s\n", err)
+		} else {
+			fmt.Fprintln(t.out, value.Inspect())
+		}
+	case "locals":
+		vars, err := t.session.Locals(t.frame)
+		if err != nil {
+			fmt.Fprintf(t.out, "error: %s\n", err)
+		}
+		t.printVariables(vars)
+	case "globals":
+		t.printVariables(t.session.Globals())
+	case "backtrace", "bt":
+		for i, frame := range t.session.Frames() {
+			marker := " "
+			if i == t.frame {
+				marker = "*"
+			}
+			fmt.Fprintf(t.out, "%s#%d %s at %s:%d\n", marker, i, frame.Name, t.name, frame.Line)
+		}
+	case "frame", "f":
+		n, err := strconv.Atoi(arg)
+		frames := t.session.Frames()
+		if err != nil || n < 0 || n >= len(frames) {
+			fmt.Fprintf(t.out, "frame must be between 0 and %d\n", len(frames)-1)
+			return false
+		}
+		t.frame = n
+		fmt.Fprintf(t.out, "#%d %s at %s:%d\n", n, frames[n].Name, t.name, frames[n].Line)
+	case "list", "l":
+		current := t.session.Frames()[t.frame].Line
+		for n := current - 3; n <= current+3; n++ {
+			if n >= 1 && n <= t.session.Lines() {
+				t.printLine(n, n == current)
+			}
+		}
+	case "help", "h":
+		fmt.Fprint(t.out, terminalHelp)
+	default:
+		fmt.Fprintf(t.out, "unknown command %q, try help\n", name)
+	}
+	return false
+}
+
+func (t *terminal) lineArg(arg string) (int, bool) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		fmt.Fprintf(t.out, "not a line number: %q\n", arg)
+		return 0, false
+	}
+	return n, true
+}
+
+func (t *terminal) printLine(n int, current bool) {
+	marker := " "
+	if current {
+		marker = ">"
+	}
+	fmt.Fprintf(t.out, "%s%4d  %s\n", marker, n, t.session.Line(n))
+}
+
+func (t *terminal) printVariables(vars []Variable) {
+	for _, v := range vars {
+		fmt.Fprintf(t.out, "%s = %s\n", v.Name, v.Value.Inspect())
+	}
+}
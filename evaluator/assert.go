@@ -0,0 +1,158 @@
+package evaluator
+
+import (
+	"bytes"
+	"interpreter/object2"
+	"strings"
+)
+
+// test, assert, assert_eq and assert_error call back into Monkey functions,
+// like to_string they are registered here instead of in the builtins literal
+func init() {
+	builtins["test"] = &object2.Builtin{
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			name, ok := args[0].(*object2.String)
+			if !ok {
+				return newError("first argument to `test` must be STRING, got=%s", args[0].Type())
+			}
+			fn := args[1]
+			if !isCallable(fn) {
+				return newError("second argument to `test` must be FUNCTION, got=%s", fn.Type())
+			}
+			run := func() object2.Object {
+				return applyFunction(env, fn, nil, nil)
+			}
+			if test := contextOf(env).Test; test != nil {
+				return test(name.Value, run)
+			}
+			if result := run(); isError(result) {
+				return result
+			}
+			return NULL
+		},
+	}
+	builtins["assert"] = &object2.Builtin{
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			if isTruthy(args[0]) {
+				return NULL
+			}
+			return assertionError("assert failed", args[1:])
+		},
+	}
+	builtins["assert_eq"] = &object2.Builtin{
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+			got, want := args[0], args[1]
+			if got.Type() == want.Type() && got.Inspect() == want.Inspect() {
+				return NULL
+			}
+			wantText, gotText := want.Inspect(), got.Inspect()
+			if got.Type() != want.Type() {
+				// the same text, tell them apart by their types
+				wantText += " (" + string(want.Type()) + ")"
+				gotText += " (" + string(got.Type()) + ")"
+			}
+			err := assertionError("assert_eq failed", args[2:])
+			err.Message += "\n" + diff(wantText, gotText)
+			return err
+		},
+	}
+	builtins["assert_error"] = &object2.Builtin{
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			if !isCallable(args[0]) {
+				return newError("argument to `assert_error` must be FUNCTION, got=%s", args[0].Type())
+			}
+			var want *object2.String
+			if len(args) == 2 {
+				str, ok := args[1].(*object2.String)
+				if !ok {
+					return newError("second argument to `assert_error` must be STRING, got=%s", args[1].Type())
+				}
+				want = str
+			}
+			result := applyFunction(env, args[0], nil, nil)
+			err, ok := result.(*object2.Error)
+			if !ok {
+				return newError("assert_error failed: no error, got=%s", result.Inspect())
+			}
+			if want != nil && !strings.Contains(err.Message, want.Value) {
+				return newError("assert_error failed: error does not contain %q\n%s",
+					want.Value, diff(want.Value, err.Message))
+			}
+			return NULL
+		},
+	}
+}
+
+func isCallable(obj object2.Object) bool {
+	switch obj.(type) {
+	case *object2.Function, *object2.Builtin, *object2.Method:
+		return true
+	}
+	return false
+}
+
+// assertionError is the error of a failed assertion, with the message given
+// to the assertion after what failed
+func assertionError(failed string, message []object2.Object) *object2.Error {
+	if len(message) == 0 {
+		return newError("%s", failed)
+	}
+	if str, ok := message[0].(*object2.String); ok {
+		return newError("%s: %s", failed, str.Value)
+	}
+	return newError("%s: %s", failed, message[0].Inspect())
+}
+
+// diff compares want and got line by line. Lines only in want start with
+// "- ", lines only in got with "+ " and lines in both with two spaces.
+func diff(want, got string) string {
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+	// common[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				common[i][j] = common[i+1][j+1] + 1
+			case common[i+1][j] >= common[i][j+1]:
+				common[i][j] = common[i+1][j]
+			default:
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+	var out bytes.Buffer
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case j == len(b) || i < len(a) && common[i+1][j] >= common[i][j+1]:
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
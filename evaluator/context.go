@@ -0,0 +1,60 @@
+package evaluator
+
+import (
+	"interpreter/ast"
+	"interpreter/object2"
+	"io"
+	"os"
+)
+
+// Context holds what one evaluation writes to and reports to. The global
+// environment carries it and every environment enclosed in it shares it, so
+// programs evaluated side by side in one process do not see each other's.
+type Context struct {
+	// Stdout is where puts writes, os.Stdout when it is nil
+	Stdout io.Writer
+	// Coverage counts the statements run when it is set
+	Coverage *Coverage
+	// Profile records the calls when it is set
+	Profile *Profile
+	// Hooks for a debugger. Statement runs before every statement with the
+	// environment the statement runs in. Call runs when a Monkey function
+	// starts running in env and Return when it is done, a tail call leaves
+	// the caller before it enters the callee. Nil hooks are skipped.
+	Statement func(node ast.Statement, env *object2.Environment)
+	Call      func(fn *object2.Function, env *object2.Environment)
+	Return    func(fn *object2.Function)
+	// Test is called by the test builtin with the name of the test and a
+	// function that runs it, its result is the result of the call. A test
+	// runner sets it to choose which tests run. When it is nil a test runs
+	// right away and its error, if any, is returned.
+	Test func(name string, run func() object2.Object) object2.Object
+
+	// number of function applications currently running
+	depth int
+}
+
+// NewEnvironment returns a global environment evaluated with ctx
+func NewEnvironment(ctx *Context) *object2.Environment {
+	env := object2.NewEnvironment()
+	env.Context = ctx
+	return env
+}
+
+// contextOf returns the context env is evaluated with. An environment made
+// without one gets an empty context the first time it is evaluated.
+func contextOf(env *object2.Environment) *Context {
+	ctx, _ := env.Context.(*Context)
+	if ctx == nil {
+		ctx = &Context{}
+		env.Context = ctx
+	}
+	return ctx
+}
+
+func (c *Context) stdout() io.Writer {
+	if c.Stdout == nil {
+		return os.Stdout
+	}
+	return c.Stdout
+}
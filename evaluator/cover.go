@@ -0,0 +1,32 @@
+package evaluator
+
+import (
+	"interpreter/ast"
+	"interpreter/object2"
+)
+
+// Coverage counts how many times each statement was run while it is the
+// Coverage of a Context
+type Coverage struct {
+	hits map[ast.Statement]int
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{hits: make(map[ast.Statement]int)}
+}
+
+// Hits returns how many times statement was run
+func (c *Coverage) Hits(statement ast.Statement) int {
+	return c.hits[statement]
+}
+
+// beforeStatement runs in every statement loop before statement is evaluated
+func beforeStatement(statement ast.Statement, env *object2.Environment) {
+	ctx := contextOf(env)
+	if ctx.Coverage != nil {
+		ctx.Coverage.hits[statement]++
+	}
+	if ctx.Statement != nil {
+		ctx.Statement(statement, env)
+	}
+}
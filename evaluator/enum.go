@@ -0,0 +1,70 @@
+package evaluator
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/object2"
+)
+
+// evalEnumStatement binds the enum type and every variant, a variant without
+// fields is bound to its only value
+func evalEnumStatement(node *ast.EnumStatement, env *object2.Environment) {
+	enumType := &object2.EnumType{Name: node.Name.Value}
+	for _, decl := range node.Variants {
+		fields := make([]string, len(decl.Fields))
+		for i, field := range decl.Fields {
+			fields[i] = field.Value
+		}
+		variant := &object2.Variant{Enum: enumType, Name: decl.Name.Value, Fields: fields}
+		enumType.Variants = append(enumType.Variants, variant)
+		env.Set(decl.Name.Slot, decl.Name.Value, variantBinding(variant))
+	}
+	env.Set(node.Name.Slot, node.Name.Value, enumType)
+}
+
+// the value a variant name evaluates to, its constructor or its only value
+func variantBinding(variant *object2.Variant) object2.Object {
+	if len(variant.Fields) == 0 {
+		return &object2.EnumValue{Variant: variant}
+	}
+	return variant
+}
+
+func newEnumValue(variant *object2.Variant, args []object2.Object, keywords []keywordArgument) object2.Object {
+	if len(keywords) > 0 {
+		return newError("enum variants do not take keyword arguments, got %s", keywords[0].name)
+	}
+	if len(args) != len(variant.Fields) {
+		return newError("wrong number of arguments. got=%d, want=%d", len(args), len(variant.Fields))
+	}
+	return &object2.EnumValue{Variant: variant, Values: args}
+}
+
+// the variant named by a variant pattern has to be an enum variant in scope,
+// a pattern with the wrong number of payload patterns is an error too
+func matchVariantPattern(pattern *ast.VariantPattern, value object2.Object, env *object2.Environment) (string, object2.Object) {
+	var variant *object2.Variant
+	switch bound := evalIdentifier(pattern.Name, env).(type) {
+	case *object2.Error:
+		return "", bound
+	case *object2.Variant:
+		variant = bound
+	case *object2.EnumValue:
+		variant = bound.Variant
+	default:
+		return "", newError("not an enum variant: %s", pattern.Name.Value)
+	}
+	if len(pattern.Elements) != len(variant.Fields) {
+		return "", newError("wrong number of fields in pattern %s, want=%d", pattern.String(), len(variant.Fields))
+	}
+	enumValue, ok := value.(*object2.EnumValue)
+	if !ok || enumValue.Variant != variant {
+		return fmt.Sprintf("expected %s, got=%s", variant.Name, value.Inspect()), nil
+	}
+	for i, element := range pattern.Elements {
+		if mismatch, err := matchPattern(element, enumValue.Values[i], env); mismatch != "" || err != nil {
+			return mismatch, err
+		}
+	}
+	return "", nil
+}
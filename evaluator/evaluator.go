@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"interpreter/ast"
 	"interpreter/object2"
+	"interpreter/resolver"
+	"math"
+	"math/big"
+	"sort"
 )
 
 //singleton only has the only TRUE and the only FALSE
@@ -13,10 +17,36 @@ var (
 	NULL  = &object2.Null{}
 )
 
+// MaxCallDepth limits how deeply calls that are not in tail position may nest.
+// Going deeper returns a "stack overflow" error instead of crashing the
+// process once the Go stack is exhausted. Tail calls do not count.
+var MaxCallDepth = 10000
+
+// tailCall is produced instead of a result when a call sits in tail position.
+// applyFunction runs it in a loop, so the Go stack does not grow.
+type tailCall struct {
+	fn       object2.Object
+	args     []object2.Object
+	keywords []keywordArgument
+}
+
+// keywordArgument is an argument passed by name, f(y: 2)
+type keywordArgument struct {
+	name  string
+	value object2.Object
+}
+
+func (tc *tailCall) Type() object2.ObjectType {
+	return "TAIL_CALL"
+}
+func (tc *tailCall) Inspect() string {
+	return "tail call"
+}
+
 var builtins = map[string]object2.Object{
 	// builtin function len
 	"len": &object2.Builtin{
-		Fn: func(args ...object2.Object) object2.Object {
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -24,64 +54,82 @@ var builtins = map[string]object2.Object{
 			case *object2.String:
 				return &object2.Integer{Value: int64(len(arg.Value))}
 			case *object2.Array:
-				return &object2.Integer{Value: int64(len(arg.Elements))}
+				return &object2.Integer{Value: int64(arg.Elements.Len())}
+			case *object2.Range:
+				return &object2.Integer{Value: arg.Len()}
 			default:
 				return newError("argument to `len` not supported, got=%s", args[0].Type())
 			}
 		},
 	},
 	"first": &object2.Builtin{
-		Fn: func(args ...object2.Object) object2.Object {
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
+			if r, ok := args[0].(*object2.Range); ok {
+				if r.Len() > 0 {
+					return &object2.Integer{Value: r.At(0)}
+				}
+				return NULL
+			}
 			if args[0].Type() != object2.ARRAY_OBJ {
 				return newError("argument to `first` must be ARRAY,got=%s", args[0].Type())
 			}
 			arr := args[0].(*object2.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
+			if arr.Elements.Len() > 0 {
+				return arr.Elements.Get(0)
 			} else {
 				return NULL
 			}
 		},
 	},
 	"last": &object2.Builtin{
-		Fn: func(args ...object2.Object) object2.Object {
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
+			if r, ok := args[0].(*object2.Range); ok {
+				if r.Len() > 0 {
+					return &object2.Integer{Value: r.At(r.Len() - 1)}
+				}
+				return NULL
+			}
 			if args[0].Type() != object2.ARRAY_OBJ {
 				return newError("argument to `last` must be ARRAY, got=%s", args[0].Type())
 			}
 			arr := args[0].(*object2.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[len(arr.Elements)-1]
+			if arr.Elements.Len() > 0 {
+				return arr.Elements.Get(arr.Elements.Len() - 1)
 			} else {
 				return NULL
 			}
 		},
 	},
 	"rest": &object2.Builtin{
-		Fn: func(args ...object2.Object) object2.Object {
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
+			// the rest of a range is still a lazy range
+			if r, ok := args[0].(*object2.Range); ok {
+				if r.Len() > 0 {
+					return &object2.Range{Start: r.At(1), Stop: r.Stop, Step: r.Step}
+				}
+				return NULL
+			}
 			if args[0].Type() != object2.ARRAY_OBJ {
 				return newError("argument to `last` must be ARRAY, got=%s", args[0].Type())
 			}
 			arr := args[0].(*object2.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object2.Object, length-1, length-1)
-				copy(newElements, arr.Elements[1:length])
-				return &object2.Array{Elements: newElements}
+			if arr.Elements.Len() > 0 {
+				return &object2.Array{Elements: arr.Elements.Rest()}
 			}
 			return NULL
 		},
 	},
 	"push": &object2.Builtin{
-		Fn: func(args ...object2.Object) object2.Object {
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2", len(args))
 			}
@@ -89,24 +137,100 @@ var builtins = map[string]object2.Object{
 				return newError("argument to `last` must be ARRAY, got=%s", args[0].Type())
 			}
 			arr := args[0].(*object2.Array)
-			length := len(arr.Elements)
-			newElements := make([]object2.Object, length+1, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
-			return &object2.Array{Elements: newElements}
+			return &object2.Array{Elements: arr.Elements.Push(args[1])}
+		},
+	},
+	// put returns a new hash with key bound to value, the original is unchanged
+	"put": &object2.Builtin{
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+			hash, ok := args[0].(*object2.Hash)
+			if !ok {
+				return newError("argument to `put` must be HASH, got=%s", args[0].Type())
+			}
+			key, ok := args[1].(object2.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+			pair := object2.HashPair{Key: args[1], Value: args[2]}
+			return &object2.Hash{Pairs: hash.Pairs.Set(key.HashKey(), pair)}
+		},
+	},
+	// delete returns a new hash without key, the original is unchanged
+	"delete": &object2.Builtin{
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			hash, ok := args[0].(*object2.Hash)
+			if !ok {
+				return newError("argument to `delete` must be HASH, got=%s", args[0].Type())
+			}
+			key, ok := args[1].(object2.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+			return &object2.Hash{Pairs: hash.Pairs.Delete(key.HashKey())}
+		},
+	},
+	// type returns the name of the struct of an instance or the enum of an
+	// enum value, or the type of any other value
+	"type": &object2.Builtin{
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if instance, ok := args[0].(*object2.StructInstance); ok {
+				return &object2.String{Value: instance.Struct.Name}
+			}
+			if value, ok := args[0].(*object2.EnumValue); ok {
+				return &object2.String{Value: value.Variant.Enum.Name}
+			}
+			return &object2.String{Value: string(args[0].Type())}
 		},
 	},
 	"puts": &object2.Builtin{
-		Fn: func(args ...object2.Object) object2.Object {
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(contextOf(env).stdout(), arg.Inspect())
 			}
 			return NULL
 		},
 	},
 }
 
+// NewGlobalScope returns the resolver scope for a fresh global environment,
+// with every builtin function defined
+func NewGlobalScope() *resolver.Scope {
+	scope := resolver.NewScope(nil)
+	for name := range builtins {
+		scope.DefineBuiltin(name)
+	}
+	return scope
+}
+
+// BuiltinNames returns the names of the builtin functions in sorted order
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func Eval(node ast.Node, env *object2.Environment) object2.Object {
+	ctx := contextOf(env)
+	result := eval(node, env)
+	if ctx.Profile != nil && allocates(node, result) {
+		ctx.Profile.allocated()
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object2.Environment) object2.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
@@ -114,7 +238,12 @@ func Eval(node ast.Node, env *object2.Environment) object2.Object {
 		return Eval(node.Expression, env)
 	case *ast.StringLiteral:
 		return &object2.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object2.BigInteger{Value: node.Big}
+		}
 		return &object2.Integer{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
@@ -149,30 +278,62 @@ func Eval(node ast.Node, env *object2.Environment) object2.Object {
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.Pattern != nil {
+			return evalDestructuringLet(node, val, env)
+		}
+		env.Set(node.Name.Slot, node.Name.Value, val)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
+	case *ast.AssignExpression:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if target, ok := node.Target.(*ast.DotExpression); ok {
+			return evalFieldAssignment(target, val, env)
+		}
+		target := node.Target.(*ast.Identifier)
+		if !env.Assign(target.Depth, target.Slot, val) {
+			return newError("identifier not found: " + target.Value)
+		}
+		return val
+	case *ast.StructStatement:
+		fields := make([]string, len(node.Fields))
+		for i, field := range node.Fields {
+			fields[i] = field.Value
+		}
+		structType := &object2.StructType{Name: node.Name.Value, Fields: fields, Methods: map[string]*object2.Function{}}
+		env.Set(node.Name.Slot, node.Name.Value, structType)
+	case *ast.ImplStatement:
+		return evalImplStatement(node, env)
+	case *ast.EnumStatement:
+		evalEnumStatement(node, env)
+	case *ast.DotExpression:
+		return evalDotExpression(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
 		// store function
-		return &object2.Function{Parameters: params, Env: env, Body: body}
+		return &object2.Function{Name: node.Name, Parameters: params, Defaults: node.Defaults, Rest: node.Rest, Env: env, Body: body}
+	case *ast.FunctionDeclaration:
+		// bound by hoistDeclarations when the block was entered
+		return nil
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		args, keywords, err := evalCallArguments(node.Arguments, env)
+		if err != nil {
+			return err
 		}
-		return applyFunction(function, args)
+		return applyFunction(env, function, args, keywords)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object2.Array{Elements: elements}
+		return &object2.Array{Elements: object2.NewVector(elements)}
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
@@ -183,15 +344,23 @@ func Eval(node ast.Node, env *object2.Environment) object2.Object {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.RangeExpression:
+		return evalRangeExpression(node, env)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env, false)
 	}
 	return nil
 }
 
 func evalProgram(program *ast.Program, env *object2.Environment) object2.Object {
 	var result object2.Object
+	hoistDeclarations(program.Statements, env)
 	for _, statement := range program.Statements {
+		beforeStatement(statement, env)
 		result = Eval(statement, env)
 		switch result := result.(type) {
 		case *object2.ReturnValue:
@@ -216,6 +385,8 @@ func evalPrefixExpression(operator string, right object2.Object) object2.Object
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusOperatorExpression(right)
+	case "~":
+		return evalBitNotOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -234,20 +405,17 @@ func evalBangOperatorExpression(right object2.Object) object2.Object {
 	}
 }
 
-func evalMinusOperatorExpression(right object2.Object) object2.Object {
-	if right.Type() != object2.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
-	}
-	value := right.(*object2.Integer).Value
-	return &object2.Integer{Value: -value}
-}
-
 func evalInfixExpression(operator string, left object2.Object, right object2.Object) object2.Object {
 	switch {
 	case left.Type() == object2.STRING_OBJ && right.Type() == object2.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case left.Type() == object2.INTEGER_OBJ && right.Type() == object2.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	// enum values are compared by content
+	case left.Type() == object2.ENUM_OBJ && right.Type() == object2.ENUM_OBJ && operator == "==":
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
+	case left.Type() == object2.ENUM_OBJ && right.Type() == object2.ENUM_OBJ && operator == "!=":
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -261,33 +429,6 @@ func evalInfixExpression(operator string, left object2.Object, right object2.Obj
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left object2.Object, right object2.Object) object2.Object {
-	leftVal := left.(*object2.Integer).Value
-	rightVal := right.(*object2.Integer).Value
-
-	switch operator {
-	case "+":
-		return &object2.Integer{Value: leftVal + rightVal}
-	case "*":
-		return &object2.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object2.Integer{Value: leftVal / rightVal}
-	case "-":
-		return &object2.Integer{Value: leftVal - rightVal}
-	case "<":
-		return nativeBoolToBooleanObject(leftVal < rightVal)
-	case ">":
-		return nativeBoolToBooleanObject(leftVal > rightVal)
-	case "==":
-		return nativeBoolToBooleanObject(leftVal == rightVal)
-	case "!=":
-		return nativeBoolToBooleanObject(leftVal != rightVal)
-	default:
-		return newError("unknown operator: %s %s %s",
-			left.Type(), operator, right.Type())
-	}
-}
-
 func evalIfExpression(ie *ast.IfExpression, env *object2.Environment) object2.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
@@ -315,9 +456,22 @@ func isTruthy(obj object2.Object) bool {
 	}
 }
 
+// hoistDeclarations binds every function declared in statements before the
+// first statement runs, so the functions can call each other
+func hoistDeclarations(statements []ast.Statement, env *object2.Environment) {
+	for _, statement := range statements {
+		if declaration, ok := statement.(*ast.FunctionDeclaration); ok {
+			fn := Eval(declaration.Function, env)
+			env.Set(declaration.Name.Slot, declaration.Name.Value, fn)
+		}
+	}
+}
+
 func evalBlockStatement(block *ast.BlockStatement, env *object2.Environment) object2.Object {
 	var result object2.Object
+	hoistDeclarations(block.Statements, env)
 	for _, statement := range block.Statements {
+		beforeStatement(statement, env)
 		result = Eval(statement, env)
 		if result != nil {
 			rt := result.Type()
@@ -326,10 +480,9 @@ func evalBlockStatement(block *ast.BlockStatement, env *object2.Environment) obj
 			//if rt == object2.RETURN_VALUE_OBJ || rt == object2.ERROR_OBJ {
 			//	return result
 			//}
-			if rt == object2.RETURN_VALUE_OBJ {
-				return result.(*object2.ReturnValue).Value
-			}
-			if rt == object2.ERROR_OBJ {
+			// the return value is unwrapped by applyFunction or evalProgram,
+			// so a return inside nested blocks leaves all of them
+			if rt == object2.RETURN_VALUE_OBJ || rt == object2.ERROR_OBJ {
 				return result
 			}
 		}
@@ -349,13 +502,14 @@ func isError(obj object2.Object) bool {
 }
 
 func evalIdentifier(node *ast.Identifier, env *object2.Environment) object2.Object {
-	if val, ok := env.Get(node.Value); ok {
+	if node.Slot < 0 {
+		if builtin, ok := builtins[node.Value]; ok {
+			return builtin
+		}
+	} else if val, ok := env.Get(node.Depth, node.Slot); ok {
 		return val
 	}
-
-	if builtin, ok := builtins[node.Value]; ok {
-		return builtin
-	}
+	// the binding exists but its let has not run, e.g. it sits in an if branch
 	return newError("identifier not found: " + node.Value)
 }
 
@@ -371,29 +525,259 @@ func evalExpressions(exps []ast.Expression, env *object2.Environment) []object2.
 	return result
 }
 
-func applyFunction(fn object2.Object, args []object2.Object) object2.Object {
-	switch fn := fn.(type) {
-	case *object2.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return evaluated
-	case *object2.Builtin:
-		return fn.Fn(args...)
+// evalCallArguments evaluates the arguments of a call, spreading arrays and
+// collecting the keyword arguments apart
+func evalCallArguments(exps []ast.Expression, env *object2.Environment) ([]object2.Object, []keywordArgument, object2.Object) {
+	args := []object2.Object{}
+	var keywords []keywordArgument
+	for _, e := range exps {
+		switch e := e.(type) {
+		case *ast.SpreadExpression:
+			value := Eval(e.Value, env)
+			if isError(value) {
+				return nil, nil, value
+			}
+			array, ok := value.(*object2.Array)
+			if !ok {
+				return nil, nil, newError("spread argument must be ARRAY, got=%s", value.Type())
+			}
+			args = append(args, array.Elements.Slice()...)
+		case *ast.KeywordArgument:
+			value := Eval(e.Value, env)
+			if isError(value) {
+				return nil, nil, value
+			}
+			keywords = append(keywords, keywordArgument{name: e.Name, value: value})
+		default:
+			value := Eval(e, env)
+			if isError(value) {
+				return nil, nil, value
+			}
+			args = append(args, value)
+		}
+	}
+	return args, keywords, nil
+}
+
+// applyFunction calls fn from env
+func applyFunction(env *object2.Environment, fn object2.Object, args []object2.Object, keywords []keywordArgument) object2.Object {
+	ctx := contextOf(env)
+	if ctx.depth >= MaxCallDepth {
+		return newError("stack overflow")
+	}
+	ctx.depth++
+	defer func() { ctx.depth-- }()
+
+	// trampoline: a tail call replaces fn and args and loops instead of recursing
+	for {
+		switch f := fn.(type) {
+		case *object2.Function:
+			extendedEnv, err := extendFunctionEnv(f, args, keywords)
+			if err != nil {
+				return err
+			}
+			if ctx.Call != nil {
+				ctx.Call(f, extendedEnv)
+			}
+			if ctx.Profile != nil {
+				ctx.Profile.enter(f)
+				ctx.Profile.allocated()
+			}
+			evaluated := evalTailBlock(f.Body, extendedEnv, true)
+			if ctx.Profile != nil {
+				ctx.Profile.exit()
+			}
+			if ctx.Return != nil {
+				ctx.Return(f)
+			}
+			if tc, ok := evaluated.(*tailCall); ok {
+				fn, args, keywords = tc.fn, tc.args, tc.keywords
+				continue
+			}
+			if err, ok := evaluated.(*object2.Error); ok {
+				err.Stack = append(err.Stack, functionName(f))
+			}
+			return unwrapReturnValue(evaluated)
+		case *object2.Method:
+			// self comes first
+			fn = f.Function
+			args = append([]object2.Object{f.Receiver}, args...)
+		case *object2.StructType:
+			return profileAllocation(ctx, newStructInstance(f, args, keywords))
+		case *object2.Variant:
+			return profileAllocation(ctx, newEnumValue(f, args, keywords))
+		case *object2.Builtin:
+			if len(keywords) > 0 {
+				return newError("builtin functions do not take keyword arguments, got %s", keywords[0].name)
+			}
+			if ctx.Profile == nil {
+				return f.Fn(env, args...)
+			}
+			ctx.Profile.enter(f)
+			defer ctx.Profile.exit()
+			return f.Fn(env, args...)
+		default:
+			return newError("not a function: %s", fn.Type())
+		}
+	}
+}
+
+// evalTailBlock evaluates a block of a function body. Every return value is
+// in tail position, and when tail is true so is the last expression.
+func evalTailBlock(block *ast.BlockStatement, env *object2.Environment, tail bool) object2.Object {
+	var result object2.Object
+	hoistDeclarations(block.Statements, env)
+	for i, statement := range block.Statements {
+		beforeStatement(statement, env)
+		switch statement := statement.(type) {
+		case *ast.ReturnStatement:
+			val := evalTail(statement.ReturnValue, env)
+			if isError(val) {
+				return val
+			}
+			if _, ok := val.(*tailCall); ok {
+				return val
+			}
+			return &object2.ReturnValue{Value: val}
+		case *ast.ExpressionStatement:
+			if tail && i == len(block.Statements)-1 {
+				return evalTail(statement.Expression, env)
+			}
+			if ie, ok := statement.Expression.(*ast.IfExpression); ok {
+				// the value is dropped, but a return inside still leaves the function
+				result = evalTailIf(ie, env, false)
+			} else {
+				result = Eval(statement, env)
+			}
+		default:
+			result = Eval(statement, env)
+		}
+		if result != nil {
+			rt := result.Type()
+			if rt == object2.RETURN_VALUE_OBJ || rt == object2.ERROR_OBJ {
+				return result
+			}
+			if _, ok := result.(*tailCall); ok {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// evalTail evaluates an expression in tail position: a call is handed back
+// as a tailCall, and if expressions pass the tail position on to their branches
+func evalTail(node ast.Expression, env *object2.Environment) object2.Object {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args, keywords, err := evalCallArguments(node.Arguments, env)
+		if err != nil {
+			return err
+		}
+		return &tailCall{fn: function, args: args, keywords: keywords}
+	case *ast.IfExpression:
+		return evalTailIf(node, env, true)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env, true)
 	default:
-		return newError("not a function: %s", fn.Type())
+		return Eval(node, env)
 	}
-	// Because we unwrapReturnValue in evalBlockStatement function
-	// So we do not need to call unwrapReturnValue function
-	//return unwrapReturnValue(evaluated)
 }
 
-// map identifier to param value
-func extendFunctionEnv(fn *object2.Function, args []object2.Object) *object2.Environment {
-	env := object2.NewEnclosedEnvironment(fn.Env)
-	for paramIds, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIds])
+func evalTailIf(ie *ast.IfExpression, env *object2.Environment, tail bool) object2.Object {
+	condition := Eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+	if isTruthy(condition) {
+		return evalTailBlock(ie.Consequence, env, tail)
+	} else if ie.Alternative != nil {
+		return evalTailBlock(ie.Alternative, env, tail)
+	}
+	return NULL
+}
+
+// map identifier to param value: positional arguments first, then keyword
+// arguments by name, then default values for the parameters still unbound.
+// A default value is evaluated in the new environment, so it sees the
+// parameters before it.
+func extendFunctionEnv(fn *object2.Function, args []object2.Object, keywords []keywordArgument) (*object2.Environment, object2.Object) {
+	params := fn.Parameters
+	required := 0
+	for i := range params {
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			required++
+		}
+	}
+	if (len(args) > len(params) && fn.Rest == nil) || (len(keywords) == 0 && len(args) < required) {
+		return nil, newError("wrong number of arguments. got=%d, want=%s", len(args), arity(fn, required))
+	}
+
+	bound := make([]object2.Object, len(params))
+	copy(bound, args)
+	for _, kw := range keywords {
+		idx := -1
+		for i, param := range params {
+			if param.Value == kw.name {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, newError("unexpected keyword argument: %s", kw.name)
+		}
+		if bound[idx] != nil {
+			return nil, newError("multiple values for argument: %s", kw.name)
+		}
+		bound[idx] = kw.value
+	}
+
+	env := object2.NewEnclosedEnvironment(fn.Env, len(params)+1)
+	for i, param := range params {
+		value := bound[i]
+		if value == nil {
+			if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+				return nil, newError("missing argument: %s", param.Value)
+			}
+			value = Eval(fn.Defaults[i], env)
+			if isError(value) {
+				return nil, value
+			}
+		}
+		env.Set(param.Slot, param.Value, value)
+	}
+	if fn.Rest != nil {
+		var extra []object2.Object
+		if len(args) > len(params) {
+			extra = args[len(params):]
+		}
+		env.Set(fn.Rest.Slot, fn.Rest.Value, &object2.Array{Elements: object2.NewVector(extra)})
+	}
+	return env, nil
+}
+
+// the name of fn in stack traces
+func functionName(fn *object2.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Name
+}
+
+// how many positional arguments fn accepts, for error messages
+func arity(fn *object2.Function, required int) string {
+	switch {
+	case fn.Rest != nil:
+		return fmt.Sprintf("at least %d", required)
+	case required == len(fn.Parameters):
+		return fmt.Sprintf("%d", required)
+	default:
+		return fmt.Sprintf("%d to %d", required, len(fn.Parameters))
 	}
-	return env
 }
 
 func unwrapReturnValue(obj object2.Object) object2.Object {
@@ -418,6 +802,10 @@ func evalIndexExpression(left object2.Object, index object2.Object) object2.Obje
 	switch {
 	case left.Type() == object2.ARRAY_OBJ && index.Type() == object2.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object2.STRING_OBJ && index.Type() == object2.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object2.RANGE_OBJ && index.Type() == object2.INTEGER_OBJ:
+		return evalRangeIndexExpression(left, index)
 	case left.Type() == object2.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -425,20 +813,221 @@ func evalIndexExpression(left object2.Object, index object2.Object) object2.Obje
 	}
 }
 
-// Notion: error handle
+// Python style index: -1 is the last element. ok is false when idx is out of range
+func normalizeIndex(idx int64, length int64) (int64, bool) {
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return 0, false
+	}
+	return idx, true
+}
+
+// Notion: out of range index returns NULL rather than an error
 func evalArrayIndexExpression(array object2.Object, index object2.Object) object2.Object {
 	arrayObject := array.(*object2.Array)
-	idx := index.(*object2.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	if idx < 0 || idx > max {
+	idx, ok := normalizeIndex(clampedInt64(index), int64(arrayObject.Elements.Len()))
+	if !ok {
+		return NULL
+	}
+	return arrayObject.Elements.Get(int(idx))
+}
+
+func evalStringIndexExpression(str object2.Object, index object2.Object) object2.Object {
+	value := str.(*object2.String).Value
+	idx, ok := normalizeIndex(clampedInt64(index), int64(len(value)))
+	if !ok {
 		return NULL
 	}
-	return arrayObject.Elements[idx]
+	return &object2.String{Value: value[idx : idx+1]}
+}
+
+func evalRangeIndexExpression(rng object2.Object, index object2.Object) object2.Object {
+	r := rng.(*object2.Range)
+	idx, ok := normalizeIndex(clampedInt64(index), r.Len())
+	if !ok {
+		return NULL
+	}
+	return &object2.Integer{Value: r.At(idx)}
+}
+
+func evalRangeExpression(node *ast.RangeExpression, env *object2.Environment) object2.Object {
+	start := Eval(node.Start, env)
+	if isError(start) {
+		return start
+	}
+	end := Eval(node.End, env)
+	if isError(end) {
+		return end
+	}
+	if start.Type() != object2.INTEGER_OBJ || end.Type() != object2.INTEGER_OBJ {
+		return newError("range bounds must be INTEGER, got=%s%s%s",
+			start.Type(), node.Token.Literal, end.Type())
+	}
+	startVal, startOk := start.(*object2.Integer)
+	endVal, endOk := end.(*object2.Integer)
+	if !startOk || !endOk {
+		return newError("range bounds out of range: %s%s%s",
+			start.Inspect(), node.Token.Literal, end.Inspect())
+	}
+	stop := endVal.Value
+	if node.Inclusive {
+		// the stop past the last integer must fit too
+		if stop == math.MaxInt64 {
+			return newError("range bounds out of range: %s%s%s",
+				start.Inspect(), node.Token.Literal, end.Inspect())
+		}
+		stop++
+	}
+	// len and indexes are int64
+	if stop > startVal.Value && uint64(stop-startVal.Value) > math.MaxInt64 {
+		return newError("range too long: %s%s%s",
+			start.Inspect(), node.Token.Literal, end.Inspect())
+	}
+	return &object2.Range{Start: startVal.Value, Stop: stop, Step: 1}
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object2.Environment) object2.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	// evaluate start, end and step, a missing part stays nil
+	bounds := make([]object2.Object, 3)
+	for i, exp := range []ast.Expression{node.Start, node.End, node.Step} {
+		if exp == nil {
+			continue
+		}
+		bound := Eval(exp, env)
+		if isError(bound) {
+			return bound
+		}
+		if bound != NULL {
+			bounds[i] = bound
+		}
+	}
+
+	var length int64
+	switch left := left.(type) {
+	case *object2.Array:
+		length = int64(left.Elements.Len())
+	case *object2.String:
+		length = int64(len(left.Value))
+	case *object2.Range:
+		length = left.Len()
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+	start, step, count, err := sliceIndices(length, bounds[0], bounds[1], bounds[2])
+	if err != nil {
+		return err
+	}
+
+	switch left := left.(type) {
+	case *object2.Array:
+		elements := make([]object2.Object, count)
+		for i := range elements {
+			elements[i] = left.Elements.Get(int(start + int64(i)*step))
+		}
+		return &object2.Array{Elements: object2.NewVector(elements)}
+	case *object2.String:
+		out := make([]byte, count)
+		for i := range out {
+			out[i] = left.Value[start+int64(i)*step]
+		}
+		return &object2.String{Value: string(out)}
+	default:
+		return sliceRange(left.(*object2.Range), start, step, count)
+	}
+}
+
+// sliceRange returns the count integers of r from position start on, taking
+// every step-th one. Every integer fits in int64 but the stop past the last
+// one may not, a stop right after the last one is taken then.
+func sliceRange(r *object2.Range, start, step, count int64) object2.Object {
+	first := r.At(start)
+	newStep := new(big.Int).Mul(big.NewInt(r.Step), big.NewInt(step))
+	stop := new(big.Int).Mul(newStep, big.NewInt(count))
+	stop.Add(stop, big.NewInt(first))
+	if newStep.IsInt64() && stop.IsInt64() {
+		return &object2.Range{Start: first, Stop: stop.Int64(), Step: newStep.Int64()}
+	}
+	if count == 0 {
+		return &object2.Range{Start: first, Stop: first, Step: 1}
+	}
+	last := r.At(start + (count-1)*step)
+	if count == 1 {
+		// one integer, any step in its direction will do
+		newStep.SetInt64(int64(newStep.Sign()))
+	}
+	after := new(big.Int).Add(big.NewInt(last), big.NewInt(int64(newStep.Sign())))
+	if !newStep.IsInt64() || !after.IsInt64() {
+		return newError("slice of range out of range: %s", r.Inspect())
+	}
+	return &object2.Range{Start: first, Stop: after.Int64(), Step: newStep.Int64()}
+}
+
+// sliceIndices clamps start, end and step to a sequence of the given length with
+// the same rules as Python. It returns the first position, the step and how many
+// elements the slice selects.
+func sliceIndices(length int64, startObj, endObj, stepObj object2.Object) (int64, int64, int64, *object2.Error) {
+	for _, bound := range []object2.Object{startObj, endObj, stepObj} {
+		if bound != nil && bound.Type() != object2.INTEGER_OBJ {
+			return 0, 0, 0, newError("slice indices must be INTEGER, got=%s", bound.Type())
+		}
+	}
+	step := int64(1)
+	if stepObj != nil {
+		step = clampedInt64(stepObj)
+	}
+	if step == 0 {
+		return 0, 0, 0, newError("slice step cannot be zero")
+	}
+
+	// when walking backwards -1 means "before the first element"
+	lower, upper := int64(0), length
+	if step < 0 {
+		lower, upper = -1, length-1
+	}
+	clamp := func(bound object2.Object, fallback int64) int64 {
+		if bound == nil {
+			return fallback
+		}
+		idx := clampedInt64(bound)
+		if idx < 0 {
+			idx += length
+		}
+		if idx < lower {
+			return lower
+		}
+		if idx > upper {
+			return upper
+		}
+		return idx
+	}
+
+	var start, end int64
+	if step > 0 {
+		start, end = clamp(startObj, lower), clamp(endObj, upper)
+	} else {
+		start, end = clamp(startObj, upper), clamp(endObj, lower)
+	}
+
+	var count int64
+	if step > 0 && start < end {
+		count = (end-start-1)/step + 1
+	} else if step < 0 && end < start {
+		count = (start-end-1)/(-step) + 1
+	}
+	return start, step, count, nil
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object2.Environment) object2.Object {
-	pairs := make(map[object2.HashKey]object2.HashPair)
-	for keyNode, valueNode := range node.Pairs {
+	var pairs object2.HashMap
+	// in source order, so that side effects happen in that order
+	for _, keyNode := range node.Keys {
+		valueNode := node.Pairs[keyNode]
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
@@ -453,7 +1042,7 @@ func evalHashLiteral(node *ast.HashLiteral, env *object2.Environment) object2.Ob
 			return value
 		}
 		hashed := hashKey.HashKey()
-		pairs[hashed] = object2.HashPair{Key: key, Value: value}
+		pairs = pairs.Set(hashed, object2.HashPair{Key: key, Value: value})
 	}
 	return &object2.Hash{Pairs: pairs}
 }
@@ -463,7 +1052,7 @@ func evalHashIndexExpression(left object2.Object, index object2.Object) object2.
 	if !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
-	hashPair, ok := hashObject.Pairs[hashKey.HashKey()]
+	hashPair, ok := hashObject.Pairs.Get(hashKey.HashKey())
 	if !ok {
 		return NULL
 	}
@@ -1,10 +1,15 @@
 package evaluator
 
 import (
+	"bytes"
+	"fmt"
 	"interpreter/lexer"
 	"interpreter/object2"
 	"interpreter/parser"
+	"interpreter/resolver"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -30,10 +35,19 @@ func TestEvalIntegerExpression(t *testing.T) {
 }
 
 func testEval(input string) object2.Object {
+	return testEvalIn(input, object2.NewEnvironment())
+}
+
+// testEvalIn evaluates input in env, which carries the context to run it with
+func testEvalIn(input string, env *object2.Environment) object2.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
-	env := object2.NewEnvironment()
+	r := resolver.New(NewGlobalScope())
+	r.Resolve(program)
+	if len(r.Errors()) != 0 {
+		return &object2.Error{Message: r.Errors()[0]}
+	}
 	return Eval(program, env)
 }
 
@@ -234,6 +248,8 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5 * 5; a;", 25},
 		{"let a = 5; let b = a;b;", 5},
 		{"let a = 5; let b = a; let c = a + b + 5;c;", 15},
+		// annotations are for the checker, a wrong one changes nothing
+		{`let a: string = 5; let f = fn(x: [int], y: int = 1) -> bool { x + y }; f(a)`, 6},
 	}
 	for _, tt := range tests {
 		testIntegerObject(t, testEval(tt.input), tt.expected)
@@ -314,19 +330,19 @@ func TestArray(t *testing.T) {
 	if !ok {
 		t.Errorf("object is not array. got=%T (%+v)", evaluated, evaluated)
 	}
-	if len(array.Elements) != 4 {
-		t.Errorf("len(array) not equls 4. got=%d", len(array.Elements))
+	if array.Elements.Len() != 4 {
+		t.Errorf("len(array) not equls 4. got=%d", array.Elements.Len())
 	}
-	if !testIntegerObject(t, array.Elements[0], 1) {
+	if !testIntegerObject(t, array.Elements.Get(0), 1) {
 		return
 	}
-	if !testIntegerObject(t, array.Elements[1], 2) {
+	if !testIntegerObject(t, array.Elements.Get(1), 2) {
 		return
 	}
-	if !testIntegerObject(t, array.Elements[2], 3) {
+	if !testIntegerObject(t, array.Elements.Get(2), 3) {
 		return
 	}
-	if !testIntegerObject(t, array.Elements[3], 4) {
+	if !testIntegerObject(t, array.Elements.Get(3), 4) {
 		return
 	}
 }
@@ -374,6 +390,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -442,12 +466,12 @@ func TestHashLiterals(t *testing.T) {
 		FALSE.HashKey():                             6,
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Pairs.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Pairs.Len())
 	}
 
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := result.Pairs.Get(expectedKey)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
 		}
@@ -456,6 +480,30 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestPersistentBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1, 2]; let b = push(a, 3); a", "[1, 2]"},
+		{"let a = [1, 2]; let b = push(a, 3); b", "[1, 2, 3]"},
+		{"let a = [1, 2, 3]; let b = rest(a); push(b, 4)", "[2, 3, 4]"},
+		{"let a = [1, 2, 3]; let b = rest(a); let c = push(b, 4); a", "[1, 2, 3]"},
+		{"rest([1])", "[]"},
+		{`let h = {"a": 1}; let g = put(h, "b", 2); [h["b"], g["b"]]`, "[null, 2]"},
+		{`let h = {"a": 1}; put(h, "a", 5)["a"]`, "5"},
+		{`let h = {"a": 1, "b": 2}; let g = delete(h, "a"); [h["a"], g["a"], g["b"]]`, "[1, null, 2]"},
+		{`delete({}, "a")`, "{}"},
+		{`put({}, [1], 2)`, "ERROR: unusable as hash key: ARRAY"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -481,6 +529,14 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{5: 5}[5]`,
 			5,
 		},
+		{
+			`{18446744073709551616: "big"}[5952119183343170476]`,
+			nil,
+		},
+		{
+			`{18446744073709551616: 7}[18446744073709551615 + 1]`,
+			7,
+		},
 		{
 			`{true: 5}[true]`,
 			5,
@@ -501,3 +557,981 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4, 5][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4, 5][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4, 5][3:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:]", "[1, 2, 3, 4, 5]"},
+		{"[1, 2, 3, 4, 5][-2:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][::2]", "[1, 3, 5]"},
+		{"[1, 2, 3, 4, 5][::-1]", "[5, 4, 3, 2, 1]"},
+		{"[1, 2, 3, 4, 5][3:0:-1]", "[4, 3, 2]"},
+		{"[1, 2, 3, 4, 5][1:100]", "[2, 3, 4, 5]"},
+		{"[1, 2, 3, 4, 5][4:1]", "[]"},
+		{`"hello"[1:4]`, "ell"},
+		{`"hello"[::-1]`, "olleh"},
+		{`"hello"[-1]`, "o"},
+		{`"hello"[0]`, "h"},
+		{"(1..10)[2:5]", "3..<6"},
+		{"(1..10)[::3]", "range(1, 13, 3)"},
+		{"(1..<5)[::-1]", "range(4, 0, -1)"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("input %q evaluated to nil", tt.input)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSliceErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"[1, 2, 3][::0]", "slice step cannot be zero"},
+		{`[1, 2, 3]["a":]`, "slice indices must be INTEGER, got=STRING"},
+		{"5[1:2]", "slice operator not supported: INTEGER"},
+		{`1.."a"`, "range bounds must be INTEGER, got=INTEGER..STRING"},
+		{"1..9223372036854775807", "range bounds out of range: 1..9223372036854775807"},
+		{"1..99999999999999999999", "range bounds out of range: 1..99999999999999999999"},
+		{"len(-9223372036854775807..9223372036854775806)", "range too long: -9223372036854775807..9223372036854775806"},
+		{"(-9223372036854775808..<-1)[::-1]", "slice of range out of range: -9223372036854775808..<-1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object2.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestRangeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"len(1..10)", 10},
+		{"len(1..<10)", 9},
+		{"len(5..1)", 0},
+		{"(1..10)[0]", 1},
+		{"(1..10)[-1]", 10},
+		{"(1..<10)[-1]", 9},
+		{"(1..10)[10]", nil},
+		{"first(3..5)", 3},
+		{"last(3..5)", 5},
+		{"first(rest(3..5))", 4},
+		{"let n = 4; len(0..<n * 2)", 8},
+		{"len(9223372036854775800..<9223372036854775807)", 7},
+		{"last(9223372036854775800..9223372036854775806)", 9223372036854775806},
+		{"len(-9223372036854775808..<-1)", 9223372036854775807},
+		{"len((0..9223372036854775806)[::2])", 4611686018427387904},
+		{"last((0..9223372036854775806)[::2])", 9223372036854775806},
+		{"len((-9223372036854775808..<-1)[-1:0:-1])", 9223372036854775806},
+		{"let sum = fn(r) { if (len(r) == 0) { 0 } else { first(r) + sum(rest(r)) } }; sum(1..10)", 55},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+// building an n element list with push used to copy the array on every call
+func benchmarkPushLoop(b *testing.B, n int) {
+	input := fmt.Sprintf(`
+	let build = fn(arr, n) { if (n == 0) { arr } else { build(push(arr, n), n - 1) } };
+	let drain = fn(arr, sum) { if (len(arr) == 0) { sum } else { drain(rest(arr), sum + first(arr)) } };
+	drain(build([], %d), 0);`, n)
+	for i := 0; i < b.N; i++ {
+		testEval(input)
+	}
+}
+
+func BenchmarkPushLoop1000(b *testing.B) { benchmarkPushLoop(b, 1000) }
+func BenchmarkPushLoop5000(b *testing.B) { benchmarkPushLoop(b, 5000) }
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// the last expression is a call
+		{"let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(1000000, 0);", 1000000},
+		// calls behind return, in either branch
+		{"let count = fn(n) { if (n > 0) { return count(n - 1); } return 42; }; count(200000);", 42},
+		{"let count = fn(n) { if (n == 0) { return 7; } else { return count(n - 1); } }; count(200000);", 7},
+		// mutual recursion through tail calls
+		{`let even = fn(n) { if (n == 0) { 1 } else { odd(n - 1) } };
+		  let odd = fn(n) { if (n == 0) { 0 } else { even(n - 1) } };
+		  even(100001);`, 0},
+		// a tail call to a builtin
+		{"let size = fn(arr) { len(arr) }; size([1, 2, 3]);", 3},
+		// return from nested blocks leaves the whole function
+		{"let f = fn() { if (true) { if (true) { return 1; } return 2; } 3 }; f();", 1},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	defer func(depth int) { MaxCallDepth = depth }(MaxCallDepth)
+	MaxCallDepth = 100
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// not a tail call: the addition runs after the recursive call returns
+		{"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(99);", 4950},
+		{"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(100);", "stack overflow"},
+		{"let loop = fn(n) { 1 + loop(n) }; loop(1);", "stack overflow"},
+		// the depth is released after the error, later calls work again
+		{"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(1000); sum(10);", "stack overflow"},
+		{"let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(5000);", 0},
+	}
+	for _, tt := range tests {
+		ctx := &Context{}
+		evaluated := testEvalIn(tt.input, NewEnvironment(ctx))
+		if ctx.depth != 0 {
+			t.Errorf("%q: call depth not restored. got=%d", tt.input, ctx.depth)
+		}
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object2.Error)
+			if !ok {
+				t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestGlobalsAcrossPrograms(t *testing.T) {
+	scope := NewGlobalScope()
+	env := object2.NewEnvironment()
+	var result object2.Object
+	for _, line := range []string{"let a = 2;", "let twice = fn(x) { x * a };", "twice(21);"} {
+		program := parser.New(lexer.New(line)).ParseProgram()
+		r := resolver.New(scope)
+		r.Resolve(program)
+		if len(r.Errors()) != 0 {
+			t.Fatalf("%q: resolver errors: %v", line, r.Errors())
+		}
+		result = Eval(program, env)
+	}
+	testIntegerObject(t, result, 42)
+}
+
+func TestBindingNotYetInitialized(t *testing.T) {
+	evaluated := testEval("let f = fn() { if (false) { let x = 1; } x }; f();")
+	errObj, ok := evaluated.(*object2.Error)
+	if !ok || errObj.Message != "identifier not found: x" {
+		t.Errorf("expected identifier not found error. got=%T(%+v)", evaluated, evaluated)
+	}
+}
+
+func BenchmarkFibonacci(b *testing.B) {
+	input := "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(20);"
+	for i := 0; i < b.N; i++ {
+		testEval(input)
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775808 - 1", "-9223372036854775809"},
+		{"-(-9223372036854775808)", "9223372036854775808"},
+		{"-9223372036854775808 / -1", "9223372036854775808"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(30)", "265252859812191058636308480000000"},
+		{"123456789012345678901234567890 / 1234567890", "100000000010000000001"},
+		{"99999999999999999999 - 99999999999999999998", "1"},
+		{"99999999999999999999 > 9223372036854775807", "true"},
+		{"-99999999999999999999 < 1", "true"},
+		{"99999999999999999999 == 99999999999999999999", "true"},
+		{"99999999999999999999 != 99999999999999999998", "true"},
+		{"0x1_0000_0000_0000_0000 - 1", "18446744073709551615"},
+		{"1_000 + 0b11 + 0o10 + 0x10", "1027"},
+		{"1 / 0", "ERROR: division by zero"},
+		{"99999999999999999999 / 0", "ERROR: division by zero"},
+		{"[1, 2, 3][99999999999999999999]", "null"},
+		{"[1, 2, 3][-99999999999999999999:]", "[1, 2, 3]"},
+		{`{99999999999999999999: "big"}[99999999999999999998 + 1]`, "big"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+// results that fit in int64 again are plain Integers
+func TestBigIntegerDemotion(t *testing.T) {
+	evaluated := testEval("(9223372036854775807 + 10) - 20")
+	testIntegerObject(t, evaluated, 9223372036854775797)
+
+	evaluated = testEval("99999999999999999999 * 0")
+	testIntegerObject(t, evaluated, 0)
+}
+
+func TestIntegerOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"7 / 2", "3"},
+		{"-7 / 2", "-4"},
+		{"7 / -2", "-4"},
+		{"-7 / -2", "3"},
+		{"7 % 2", "1"},
+		{"-7 % 2", "1"},
+		{"7 % -2", "-1"},
+		{"-7 % -2", "-1"},
+		{"6 % 3", "0"},
+		{"2 ** 10", "1024"},
+		{"2 ** 3 ** 2", "512"},
+		{"-2 ** 2", "-4"},
+		{"(-2) ** 3", "-8"},
+		{"2 ** 0", "1"},
+		{"0 ** 0", "1"},
+		{"(-1) ** 99999999999999999999", "-1"},
+		{"2 ** 64", "18446744073709551616"},
+		{"12 & 10", "8"},
+		{"12 | 10", "14"},
+		{"12 ^ 10", "6"},
+		{"~5", "-6"},
+		{"~-1", "0"},
+		{"1 << 10", "1024"},
+		{"1 << 63", "9223372036854775808"},
+		{"1024 >> 3", "128"},
+		{"-9 >> 1", "-5"},
+		{"-1 >> 100", "-1"},
+		{"1 + 2 << 3", "24"},
+		{"1 | 2 ^ 3 & 4", "3"},
+		{"5 & 1 == 1", "true"},
+		{"-99999999999999999999 / 2", "-50000000000000000000"},
+		{"-99999999999999999999 % 7", "6"},
+		{"(1 << 100) >> 99", "2"},
+		{"(1 << 100) & ((1 << 100) - 1)", "0"},
+		{"~(1 << 100)", "-1267650600228229401496703205377"},
+		{"-9223372036854775808 / -1", "9223372036854775808"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestIntegerOperatorErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"1 % 0", "division by zero"},
+		{"2 ** -1", "negative exponent: -1"},
+		{"2 ** 99999999999", "integer overflow: 2 ** 99999999999 is too large"},
+		{"1 << -1", "negative shift count: -1"},
+		{"1 >> -1", "negative shift count: -1"},
+		{"1 << 99999999999", "shift count too large: 99999999999"},
+		{"~true", "unknown operator: ~BOOLEAN"},
+		{`"a" % "b"`, "unknown operator: STRING % STRING"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object2.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("input %q: wrong error message. expected=%q, got=%q",
+				tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (1) { 1 => \"one\", _ => \"other\" }", "one"},
+		{"match (5) { 1 => \"one\", _ => \"other\" }", "other"},
+		{"match (-3) { -3 => true, _ => false }", "true"},
+		{"match (99999999999999999999) { 99999999999999999999 => 1, _ => 2 }", "1"},
+		{`match ("b") { "a" => 1, "b" => 2 }`, "2"},
+		{"match (1) { true => 1, 1 => 2 }", "2"},
+		{"match (7) { n => n * 2 }", "14"},
+		{"match ([1, 2, 3]) { [] => 0, [h, ...t] => t }", "[2, 3]"},
+		{"match ([1]) { [a, b] => 2, [a] => 1 }", "1"},
+		{"match ([1, 2]) { [a] => 1, [a, ...] => a }", "1"},
+		{"match ([1, [2, 3]]) { [a, [b, c]] => a + b + c }", "6"},
+		{`match ({"type": "user", "name": "ann", "age": 3}) { {"type": "admin"} => "admin", {"type": "user", "name": n} => n }`, "ann"},
+		{`match ({"x": 1}) { {"y": v} => v, _ => "none" }`, "none"},
+		{"match (4) { n if n % 2 == 1 => \"odd\", n => \"even\" }", "even"},
+		{"match (3) { n if n % 2 == 1 => \"odd\", n => \"even\" }", "odd"},
+		{"let n = 10; match (1) { n => n }; n", "10"},
+		{"match (2) { x => { let y = x * 10; y + 1 } }", "21"},
+		{"let f = fn(x) { match (x) { 0 => { return 100; } _ => 1 }; 2 }; f(0)", "100"},
+		{
+			`let build = fn(n, acc) { if (n == 0) { acc } else { build(n - 1, push(acc, n)) } };
+			let sum = fn(list, acc) { match (list) { [] => acc, [h, ...t] => sum(t, acc + h) } };
+			sum(build(20000, []), 0)`,
+			"200010000",
+		},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"match (3) { 1 => 1, 2 => 2 }", "no match arm matches value: 3"},
+		{"match ([1, 2]) { [a] => a }", "no match arm matches value: [1, 2]"},
+		{"match (1) { n if n + true => n }", "type mismatch: INTEGER + BOOLEAN"},
+		{"match (foo) { _ => 1 }", "identifier not found: foo"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object2.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("input %q: wrong error message. expected=%q, got=%q",
+				tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestDestructuringLet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = [1, 2]; a + b", "3"},
+		{"let [h, ...t] = [1, 2, 3]; t", "[2, 3]"},
+		{"let [h, ...t] = [1]; t", "[]"},
+		{"let [a, _, c] = [1, 2, 3]; c", "3"},
+		{"let [a, [b, c]] = [1, [2, 3]]; a + b + c", "6"},
+		{`let {name, age: years} = {"name": "ann", "age": 3}; name`, "ann"},
+		{`let {name, age: years} = {"name": "ann", "age": 3}; years`, "3"},
+		{`let {user: {tags: [first, ...]}} = {"user": {"tags": ["a", "b"]}}; first`, "a"},
+		{"let [x, y = 10] = [1]; x + y", "11"},
+		{"let [x, y = x * 2] = [4]; y", "8"},
+		{`let {name = "anon"} = {}; name`, "anon"},
+		{`let {name = "anon"} = {"name": "bob"}; name`, "bob"},
+		{"let f = fn() { [1, 2] }; let g = fn() { let [a, b] = f(); a * 10 + b }; g()", "12"},
+		{"let [1, a] = [1, 2]; a", "2"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestDestructuringLetErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"let [a, b] = [1];", "cannot destructure [1]: expected 2 elements, got=1"},
+		{"let [a, b] = [1, 2, 3];", "cannot destructure [1, 2, 3]: expected 2 elements, got=3"},
+		{"let [a, b = 1] = [];", "cannot destructure []: expected 1 to 2 elements, got=0"},
+		{"let [a, b, ...c] = [1];", "cannot destructure [1]: expected at least 2 elements, got=1"},
+		{"let [a] = 5;", "cannot destructure 5: expected ARRAY, got=INTEGER"},
+		{`let {name} = {"age": 1};`, "cannot destructure {age: 1}: missing key name"},
+		{"let {name} = [1];", "cannot destructure [1]: expected HASH, got=ARRAY"},
+		{"let [1, a] = [2, 3];", "cannot destructure [2, 3]: expected 1, got=2"},
+		{"let [a = 1 + true] = [];", "type mismatch: INTEGER + BOOLEAN"},
+		{"let [a, a] = [1, 2];", "identifier bound more than once in pattern: a"},
+		{"let [a = b, b] = [];", "identifier used before definition: b"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object2.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("input %q: wrong error message. expected=%q, got=%q",
+				tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(x, y = 10) { x + y }; f(1)", "11"},
+		{"let f = fn(x, y = 10) { x + y }; f(1, 2)", "3"},
+		{"let f = fn(x, y = x * 2) { y }; f(4)", "8"},
+		{"let f = fn(first, ...others) { others }; f(1, 2, 3)", "[2, 3]"},
+		{"let f = fn(first, ...others) { others }; f(1)", "[]"},
+		{"let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(...[1, 2, 3])", "123"},
+		{"let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(1, ...[2], 3)", "123"},
+		{"let f = fn(...all) { len(all) }; f(...[1, 2], ...[3])", "3"},
+		{"let f = fn(x, y) { x - y }; f(y: 1, x: 10)", "9"},
+		{"let f = fn(x, y = 2, z = 3) { x + y * 10 + z * 100 }; f(1, z: 5)", "521"},
+		{"let f = fn(x = 1, y = 2) { x + y }; f(y: 5)", "6"},
+		{"len(...[[1, 2]])", "2"},
+		{
+			"let loop = fn(n, acc = 0) { if (n == 0) { acc } else { loop(n - 1, acc: acc + n) } }; loop(50000)",
+			"1250025000",
+		},
+		{"fn(x, y = 10, ...rest) { x }", "fn(x, y = 10, ...rest) {\nx\n}"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestFunctionArgumentErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"let f = fn(x, y) { x }; f(1)", "wrong number of arguments. got=1, want=2"},
+		{"let f = fn(x, y) { x }; f(1, 2, 3)", "wrong number of arguments. got=3, want=2"},
+		{"let f = fn(x, y = 1) { x }; f()", "wrong number of arguments. got=0, want=1 to 2"},
+		{"let f = fn(x, ...r) { x }; f()", "wrong number of arguments. got=0, want=at least 1"},
+		{"let f = fn(x, y) { x }; f(1, z: 2)", "unexpected keyword argument: z"},
+		{"let f = fn(x, y) { x }; f(1, x: 2)", "multiple values for argument: x"},
+		{"let f = fn(x, y) { x }; f(x: 1)", "missing argument: y"},
+		{"let f = fn(x, ...r) { x }; f(1, r: 2)", "unexpected keyword argument: r"},
+		{"let f = fn(x) { x }; f(...1)", "spread argument must be ARRAY, got=INTEGER"},
+		{"let f = fn(x = 1 + true) { x }; f()", "type mismatch: INTEGER + BOOLEAN"},
+		{"len(x: [1])", "builtin functions do not take keyword arguments, got x"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object2.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("input %q: wrong error message. expected=%q, got=%q",
+				tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn add(x, y) { x + y } add(1, 2)", "3"},
+		{"let r = twice(3); fn twice(x) { x * 2 } r", "6"},
+		{"fn fact(n) { if (n == 0) { 1 } else { n * fact(n - 1) } } fact(20)", "2432902008176640000"},
+		{
+			`fn even(n) { if (n == 0) { true } else { odd(n - 1) } }
+			fn odd(n) { if (n == 0) { false } else { even(n - 1) } }
+			even(100001)`,
+			"false",
+		},
+		{
+			`let f = fn(n) {
+				fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+				fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+				isEven(n)
+			};
+			f(10)`,
+			"true",
+		},
+		{"fn named(x, y = 2) { x }", "null"},
+		{"fn named(x, y = 2) { x } named", "fn named(x, y = 2) {\nx\n}"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			if tt.expected != "null" {
+				t.Errorf("input %q: expected=%q, got=nil", tt.input, tt.expected)
+			}
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `fn inner(x) { x + true }
+fn outer(x) { let y = inner(x); y }
+let anon = fn() { let r = outer(1); r };
+anon()`
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object2.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	expected := []string{"inner", "outer", "<anonymous>"}
+	if fmt.Sprint(errObj.Stack) != fmt.Sprint(expected) {
+		t.Errorf("wrong stack. expected=%v, got=%v", expected, errObj.Stack)
+	}
+	inspected := "ERROR: type mismatch: INTEGER + BOOLEAN\n\tat inner\n\tat outer\n\tat <anonymous>"
+	if errObj.Inspect() != inspected {
+		t.Errorf("wrong Inspect. expected=%q, got=%q", inspected, errObj.Inspect())
+	}
+
+	// deep stacks are cut short when printed
+	evaluated = testEval("fn down(n) { let r = down(n + 1); r } down(0)")
+	errObj, ok = evaluated.(*object2.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if len(errObj.Stack) != MaxCallDepth {
+		t.Errorf("expected %d frames, got=%d", MaxCallDepth, len(errObj.Stack))
+	}
+	lines := strings.Split(errObj.Inspect(), "\n")
+	if len(lines) != 18 || lines[17] != fmt.Sprintf("\t... %d more", MaxCallDepth-16) {
+		t.Errorf("wrong Inspect of a deep stack, last line=%q", lines[len(lines)-1])
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; x = 2; x", "2"},
+		{"let x = 1; let y = x = 5; x + y", "10"},
+		{"let a = 0; let b = 0; a = b = 3; a + b", "6"},
+		// closures capture bindings, they see later assignments
+		{"let n = 1; let get = fn() { n }; n = 2; get()", "2"},
+		{
+			`let counter = fn() { let n = 0; fn() { n = n + 1; n } };
+			let c = counter(); c(); c();
+			let d = counter(); d();
+			c() * 10 + d()`,
+			"32",
+		},
+		{
+			`let pair = fn() { let n = 0; [fn() { n = n + 1 }, fn() { n }] };
+			let [inc, get] = pair();
+			inc(); inc();
+			get()`,
+			"2",
+		},
+		{"let f = fn(x) { x = x * 2; x }; f(21)", "42"},
+		{"const c = 10; let f = fn() { c * 2 }; f()", "20"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+// at the REPL a redeclared global is a new binding, closures created before
+// keep the old one
+func TestRedeclarationKeepsCapturedBinding(t *testing.T) {
+	scope := NewGlobalScope()
+	env := object2.NewEnvironment()
+	var result object2.Object
+	for _, line := range []string{
+		"let x = 1;",
+		"let get = fn() { x };",
+		"let x = 2;",
+		"get() * 10 + x",
+	} {
+		program := parser.New(lexer.New(line)).ParseProgram()
+		r := resolver.New(scope)
+		r.AllowRedeclaration()
+		r.Resolve(program)
+		if len(r.Errors()) != 0 {
+			t.Fatalf("%q: resolver errors: %v", line, r.Errors())
+		}
+		result = Eval(program, env)
+	}
+	testIntegerObject(t, result, 12)
+}
+
+func TestStructs(t *testing.T) {
+	point := `struct Point { x, y }
+	impl Point {
+		fn norm(self) { self.x * self.x + self.y * self.y }
+		fn scale(self, k) { Point(self.x * k, self.y * k) }
+		fn origin() { Point(0, 0) }
+	}
+	`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{point + "Point(1, 2)", "Point{x: 1, y: 2}"},
+		{point + "Point(y: 2, x: 1)", "Point{x: 1, y: 2}"},
+		{point + "Point(1, y: 2).y", "2"},
+		{point + "let p = Point(3, 4); p.norm()", "25"},
+		{point + "Point(1, 2).scale(3)", "Point{x: 3, y: 6}"},
+		{point + "Point.origin()", "Point{x: 0, y: 0}"},
+		{point + "Point.norm(Point(1, 1))", "2"},
+		{point + "let p = Point(1, 2); p.x = 5; p.norm()", "29"},
+		{point + "let p = Point(1, 2); let q = p; q.x = 5; p.x", "5"},
+		{point + "let n = Point(1, 2).norm; n()", "5"},
+		{point + "type(Point(1, 2))", "Point"},
+		{point + "Point", "struct Point { x, y }"},
+		{"type(1)", "INTEGER"},
+		{"struct Node { value, next } let list = Node(1, Node(2, 0)); list.next.value", "2"},
+		// methods see names defined after the impl block
+		{"struct C { n } impl C { fn get(self) { self.n + k } } let k = 10; C(1).get()", "11"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"struct P { x, y } P(1)", "wrong number of arguments. got=1, want=2"},
+		{"struct P { x, y } P(1, 2, 3)", "wrong number of arguments. got=3, want=2"},
+		{"struct P { x, y } P(1, z: 2)", "P has no field z"},
+		{"struct P { x, y } P(1, x: 2)", "multiple values for field: x"},
+		{"struct P { x, y } P(x: 1)", "missing field: y"},
+		{"struct P { x } P(1).y", "P has no field or method y"},
+		{"struct P { x } P.y", "P has no method y"},
+		{"struct P { x } let p = P(1); p.y = 2", "P has no field y"},
+		{"let h = {}; h.x", "field access not supported: HASH"},
+		{"let h = 1; h.x = 2", "field assignment not supported: INTEGER"},
+		{"let f = 1; impl f { fn g() { 1 } }", "impl target must be STRUCT_TYPE, got=INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object2.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("input %q: wrong error message. expected=%q, got=%q",
+				tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestEnums(t *testing.T) {
+	result := `enum Result { Ok(value), Err(msg), None }
+	let unwrap = fn(r, default) { match (r) { Ok(v) => v, Err(_) => default, None => default } };
+	`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{result + "Ok(1)", "Ok(1)"},
+		{result + "Err(\"bad\")", "Err(bad)"},
+		{result + "None", "None"},
+		{result + "Ok", "Result.Ok"},
+		{result + "Result", "enum Result { Ok(value), Err(msg), None }"},
+		{result + "Result.Ok(2)", "Ok(2)"},
+		{result + "Result.None == None", "true"},
+		{result + "type(None)", "Result"},
+		{result + "unwrap(Ok(1), 0) + unwrap(Err(1), 10) + unwrap(None, 100)", "111"},
+		{result + "Ok(1) == Ok(1)", "true"},
+		{result + "Ok(Ok(2)) == Ok(Ok(2))", "true"},
+		{result + "Ok(1) == Ok(2)", "false"},
+		{result + "Ok(1) == Err(1)", "false"},
+		{result + "Ok(1) != Err(1)", "true"},
+		{result + "Ok(1) == 1", "false"},
+		{result + "let h = {Ok(1): \"one\", None: \"none\"}; h[Ok(1)] + h[None]", "onenone"},
+		{result + "let h = {Ok(1): 1}; h[Ok(2)]", "null"},
+		{result + "match (Ok(Ok(3))) { Ok(Err(_)) => 0, Ok(Ok(n)) => n }", "3"},
+		{result + "match (Ok([1, 2])) { Ok([a, b]) => a + b }", "3"},
+		{result + "match (5) { None => 0, n => n }", "5"},
+		// a state machine
+		{
+			`enum State { Idle, Running(ticks), Done }
+			let step = fn(s) {
+				match (s) {
+					Idle => Running(0),
+					Running(n) if n > 1 => Done,
+					Running(n) => Running(n + 1),
+					Done => Done,
+				}
+			};
+			let run = fn(s, i) { if (i == 0) { s } else { run(step(s), i - 1) } };
+			[run(Idle, 2), run(Idle, 5)]`,
+			"[Running(1), Done]",
+		},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestEnumErrors(t *testing.T) {
+	result := "enum Result { Ok(value), Err(msg), None } "
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{result + "Ok()", "wrong number of arguments. got=0, want=1"},
+		{result + "Ok(1, 2)", "wrong number of arguments. got=2, want=1"},
+		{result + "Ok(value: 1)", "enum variants do not take keyword arguments, got value"},
+		{result + "None()", "not a function: ENUM"},
+		{result + "Result.Maybe", "Result has no variant Maybe"},
+		{result + "match (Ok(1)) { Ok(a, b) => a }", "wrong number of fields in pattern Ok(a, b), want=1"},
+		{result + "match (None) { Ok(v) => v }", "no match arm matches value: None"},
+		{"let Foo = 1; match (1) { Foo => 1 }", "not an enum variant: Foo"},
+		{result + "Ok(1) + Ok(2)", "unknown operator: ENUM + ENUM"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object2.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("input %q: wrong error message. expected=%q, got=%q",
+				tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Bob"; let count = 2; "Hello ${name}, you have ${count + 1} items"`, "Hello Bob, you have 3 items"},
+		{`"${1}${true}${[1, 2]}"`, "1true[1, 2]"},
+		{`"no interpolation"`, "no interpolation"},
+		{`let f = fn(x) { "<${x}>" }; "${f("a")}${f(1)}"`, "<a><1>"},
+		{`"outer ${"inner ${1 + 1}"}"`, "outer inner 2"},
+		{`struct P { x } "${P(1)}"`, "P{x: 1}"},
+		{`struct P { x } impl P { fn to_string(self) { "P(${self.x})" } } "${P(1)}"`, "P(1)"},
+		{`to_string(12) + to_string("s")`, "12s"},
+		{`struct P { x } impl P { fn to_string(self) { "p" } } to_string(P(1))`, "p"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object2.String)
+		if !ok {
+			t.Errorf("input %q: object is not String. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+}
+
+func TestStringInterpolationErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`"${1 + true}"`, "type mismatch: INTEGER + BOOLEAN"},
+		{`struct P { x } impl P { fn to_string(self) { 1 } } "${P(1)}"`, "to_string must return STRING, got=INTEGER"},
+		{`to_string(1, 2)`, "wrong number of arguments. got=2, want=1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object2.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("input %q: wrong error message. expected=%q, got=%q",
+				tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	tests := []string{
+		`assert(1 < 2)`,
+		`assert(true, "never shown")`,
+		`assert_eq(push([1, 2], 3), [1, 2, 3])`,
+		`assert_eq({"a": 1}, {"a": 1}, "same hash")`,
+		`assert_error(fn() { 1 + true })`,
+		`assert_error(fn() { len(1) }, "not supported")`,
+		`test("passes", fn() { assert_eq(1 + 1, 2) })`,
+	}
+	for _, input := range tests {
+		if evaluated := testEval(input); evaluated != NULL {
+			t.Errorf("input %q: expected NULL. got=%T(%+v)", input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestAssertionFailures(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`assert(1 > 2)`, "assert failed"},
+		{`assert(false, "must hold")`, "assert failed: must hold"},
+		{`assert_eq(1 + 1, 3)`, "assert_eq failed\n- 3\n+ 2"},
+		{`assert_eq(1, "1")`, "assert_eq failed\n- 1 (STRING)\n+ 1 (INTEGER)"},
+		{"assert_eq(\"a\nb\nc\", \"a\nc\", \"lines\")", "assert_eq failed: lines\n  a\n+ b\n  c"},
+		{`assert_error(fn() { 1 })`, "assert_error failed: no error, got=1"},
+		{`assert_error(fn() { len(1) }, "wrong")`,
+			"assert_error failed: error does not contain \"wrong\"\n- wrong\n+ argument to `len` not supported, got=INTEGER"},
+		{`assert_error(1)`, "argument to `assert_error` must be FUNCTION, got=INTEGER"},
+		{`test("fails", fn() { assert(false) })`, "assert failed"},
+		{`test(1, fn() {})`, "first argument to `test` must be STRING, got=INTEGER"},
+		{`assert_eq(1)`, "wrong number of arguments. got=1, want=2 or 3"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object2.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("input %q: wrong error message. expected=%q, got=%q",
+				tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	var a, b bytes.Buffer
+	tests := []string{}
+	envA := NewEnvironment(&Context{
+		Stdout: &a,
+		Test: func(name string, run func() object2.Object) object2.Object {
+			tests = append(tests, name)
+			return NULL
+		},
+	})
+	envB := NewEnvironment(&Context{Stdout: &b})
+	testEvalIn(`test("a", fn() { puts("in a") }); puts("a")`, envA)
+	testEvalIn(`test("b", fn() { puts("in b") }); puts("b")`, envB)
+	if a.String() != "a\n" || b.String() != "in b\nb\n" {
+		t.Errorf("output wrong. got a=%q b=%q", a.String(), b.String())
+	}
+	if strings.Join(tests, ",") != "a" {
+		t.Errorf("tests wrong. got=%v", tests)
+	}
+}
+
+// evaluations running at the same time count their call depth apart
+func TestContextCallDepth(t *testing.T) {
+	defer func(depth int) { MaxCallDepth = depth }(MaxCallDepth)
+	MaxCallDepth = 100
+	input := "let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(99);"
+	results := make(chan object2.Object)
+	for i := 0; i < 8; i++ {
+		go func() {
+			for j := 0; j < 20; j++ {
+				if result := testEval(input); isError(result) {
+					results <- result
+					return
+				}
+			}
+			results <- nil
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		if result := <-results; result != nil {
+			t.Errorf("sum failed: %s", result.Inspect())
+		}
+	}
+}
+
+func TestProfile(t *testing.T) {
+	input := `fn fib(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }
+let twice = fn(f) { f(); f() };
+twice(fn() { len([fib(3)]) });`
+	// every event takes a millisecond
+	clock := time.Time{}
+	profile := newProfile(func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	})
+	testEvalIn(input, NewEnvironment(&Context{Profile: profile}))
+	profile.Stop()
+
+	got := []string{}
+	for _, sample := range profile.Samples() {
+		names := []string{}
+		for _, fn := range sample.Stack {
+			names = append(names, profile.Functions[fn].Name)
+		}
+		got = append(got, fmt.Sprintf("%s calls=%d time=%s allocs=%d",
+			strings.Join(names, "/"), sample.Calls, sample.Time, sample.Allocations))
+	}
+	// twice leaves before its tail call of f runs, as f does before its
+	// tail call of len
+	want := []string{
+		"<main> calls=1 time=4ms allocs=3",
+		"<main>/<anonymous> calls=1 time=3ms allocs=1",
+		"<main>/<anonymous>/<anonymous> calls=1 time=2ms allocs=3",
+		"<main>/<anonymous>/<anonymous>/fib calls=1 time=3ms allocs=7",
+		"<main>/<anonymous>/<anonymous>/fib/fib calls=2 time=4ms allocs=9",
+		"<main>/<anonymous>/<anonymous>/fib/fib/fib calls=2 time=2ms allocs=4",
+		"<main>/<anonymous>/len calls=1 time=1ms allocs=0",
+		"<main>/<anonymous> calls=1 time=2ms allocs=3",
+		"<main>/<anonymous>/fib calls=1 time=3ms allocs=7",
+		"<main>/<anonymous>/fib/fib calls=2 time=4ms allocs=9",
+		"<main>/<anonymous>/fib/fib/fib calls=2 time=2ms allocs=4",
+		"<main>/len calls=1 time=1ms allocs=0",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("samples wrong.\ngot=\n%s\nwant=\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
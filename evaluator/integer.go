@@ -0,0 +1,254 @@
+package evaluator
+
+import (
+	"interpreter/object2"
+	"math"
+	"math/big"
+)
+
+// Integers are int64 until an operation overflows, then the result is
+// computed again with math/big. object2.IntegerFromBig turns results that
+// fit in int64 back into a plain Integer.
+//
+// Division floors towards negative infinity and the remainder takes the sign
+// of the divisor, so a == (a / b) * b + a % b always holds:
+//	 7 /  2 ==  3	 7 %  2 ==  1
+//	-7 /  2 == -4	-7 %  2 ==  1
+//	 7 / -2 == -4	 7 % -2 == -1
+// Shifts and bitwise operators behave as if integers were two's complement
+// numbers of unlimited width, >> is an arithmetic shift.
+
+// maxIntegerBits bounds the size of results of ** and <<, anything larger is
+// almost certainly a mistake and would exhaust memory
+const maxIntegerBits = 1 << 20
+
+func evalBitNotOperatorExpression(right object2.Object) object2.Object {
+	switch right := right.(type) {
+	case *object2.Integer:
+		return &object2.Integer{Value: ^right.Value}
+	case *object2.BigInteger:
+		return object2.IntegerFromBig(new(big.Int).Not(right.Value))
+	default:
+		return newError("unknown operator: ~%s", right.Type())
+	}
+}
+
+func evalMinusOperatorExpression(right object2.Object) object2.Object {
+	switch right := right.(type) {
+	case *object2.Integer:
+		if right.Value == math.MinInt64 {
+			return object2.IntegerFromBig(new(big.Int).Neg(object2.BigValue(right)))
+		}
+		return &object2.Integer{Value: -right.Value}
+	case *object2.BigInteger:
+		return object2.IntegerFromBig(new(big.Int).Neg(right.Value))
+	default:
+		return newError("unknown operator: -%s", right.Type())
+	}
+}
+
+func evalIntegerInfixExpression(operator string, left object2.Object, right object2.Object) object2.Object {
+	leftInt, leftOk := left.(*object2.Integer)
+	rightInt, rightOk := right.(*object2.Integer)
+	if leftOk && rightOk {
+		if result, ok := evalSmallIntegerInfixExpression(operator, leftInt.Value, rightInt.Value); ok {
+			return result
+		}
+	}
+	return evalBigIntegerInfixExpression(operator, object2.BigValue(left), object2.BigValue(right))
+}
+
+// int64 fast path, ok is false when the result overflows
+func evalSmallIntegerInfixExpression(operator string, leftVal int64, rightVal int64) (object2.Object, bool) {
+	switch operator {
+	case "+":
+		result := leftVal + rightVal
+		if (leftVal^result)&(rightVal^result) < 0 {
+			return nil, false
+		}
+		return &object2.Integer{Value: result}, true
+	case "-":
+		result := leftVal - rightVal
+		if (leftVal^rightVal)&(leftVal^result) < 0 {
+			return nil, false
+		}
+		return &object2.Integer{Value: result}, true
+	case "*":
+		if leftVal == 0 || rightVal == 0 {
+			return &object2.Integer{Value: 0}, true
+		}
+		result := leftVal * rightVal
+		if result/rightVal != leftVal || (leftVal == -1 && rightVal == math.MinInt64) ||
+			(rightVal == -1 && leftVal == math.MinInt64) {
+			return nil, false
+		}
+		return &object2.Integer{Value: result}, true
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero"), true
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return nil, false
+		}
+		result := leftVal / rightVal
+		if leftVal%rightVal != 0 && (leftVal < 0) != (rightVal < 0) {
+			result--
+		}
+		return &object2.Integer{Value: result}, true
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero"), true
+		}
+		result := leftVal % rightVal
+		if result != 0 && (result < 0) != (rightVal < 0) {
+			result += rightVal
+		}
+		return &object2.Integer{Value: result}, true
+	case "&":
+		return &object2.Integer{Value: leftVal & rightVal}, true
+	case "|":
+		return &object2.Integer{Value: leftVal | rightVal}, true
+	case "^":
+		return &object2.Integer{Value: leftVal ^ rightVal}, true
+	case "<<":
+		if rightVal < 0 || rightVal >= 63 {
+			return nil, false
+		}
+		result := leftVal << uint(rightVal)
+		if result>>uint(rightVal) != leftVal {
+			return nil, false
+		}
+		return &object2.Integer{Value: result}, true
+	case ">>":
+		if rightVal < 0 {
+			return nil, false
+		}
+		if rightVal >= 63 {
+			rightVal = 63
+		}
+		return &object2.Integer{Value: leftVal >> uint(rightVal)}, true
+	case "**":
+		return nil, false
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal), true
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal), true
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal), true
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal), true
+	default:
+		return newError("unknown operator: %s %s %s",
+			object2.INTEGER_OBJ, operator, object2.INTEGER_OBJ), true
+	}
+}
+
+func evalBigIntegerInfixExpression(operator string, leftVal *big.Int, rightVal *big.Int) object2.Object {
+	switch operator {
+	case "+":
+		return object2.IntegerFromBig(leftVal.Add(leftVal, rightVal))
+	case "-":
+		return object2.IntegerFromBig(leftVal.Sub(leftVal, rightVal))
+	case "*":
+		return object2.IntegerFromBig(leftVal.Mul(leftVal, rightVal))
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		quo, rem := new(big.Int).QuoRem(leftVal, rightVal, new(big.Int))
+		if rem.Sign() != 0 && rem.Sign() != rightVal.Sign() {
+			quo.Sub(quo, big.NewInt(1))
+		}
+		return object2.IntegerFromBig(quo)
+	case "%":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		rem := new(big.Int).Rem(leftVal, rightVal)
+		if rem.Sign() != 0 && rem.Sign() != rightVal.Sign() {
+			rem.Add(rem, rightVal)
+		}
+		return object2.IntegerFromBig(rem)
+	case "&":
+		return object2.IntegerFromBig(leftVal.And(leftVal, rightVal))
+	case "|":
+		return object2.IntegerFromBig(leftVal.Or(leftVal, rightVal))
+	case "^":
+		return object2.IntegerFromBig(leftVal.Xor(leftVal, rightVal))
+	case "<<", ">>":
+		return evalShift(operator, leftVal, rightVal)
+	case "**":
+		return evalPower(leftVal, rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s",
+			object2.INTEGER_OBJ, operator, object2.INTEGER_OBJ)
+	}
+}
+
+func evalShift(operator string, leftVal *big.Int, rightVal *big.Int) object2.Object {
+	if rightVal.Sign() < 0 {
+		return newError("negative shift count: %s", rightVal)
+	}
+	if operator == ">>" {
+		if !rightVal.IsInt64() || rightVal.Int64() > int64(leftVal.BitLen()) {
+			// everything is shifted out
+			if leftVal.Sign() < 0 {
+				return &object2.Integer{Value: -1}
+			}
+			return &object2.Integer{Value: 0}
+		}
+		return object2.IntegerFromBig(leftVal.Rsh(leftVal, uint(rightVal.Int64())))
+	}
+	if leftVal.Sign() == 0 {
+		return &object2.Integer{Value: 0}
+	}
+	if !rightVal.IsInt64() || int64(leftVal.BitLen())+rightVal.Int64() > maxIntegerBits {
+		return newError("shift count too large: %s", rightVal)
+	}
+	return object2.IntegerFromBig(leftVal.Lsh(leftVal, uint(rightVal.Int64())))
+}
+
+func evalPower(base *big.Int, exponent *big.Int) object2.Object {
+	if exponent.Sign() < 0 {
+		return newError("negative exponent: %s", exponent)
+	}
+	// 0, 1 and -1 stay small whatever the exponent
+	if base.CmpAbs(big.NewInt(1)) <= 0 {
+		if base.Sign() < 0 && exponent.Bit(0) == 0 {
+			return &object2.Integer{Value: 1}
+		}
+		if base.Sign() == 0 && exponent.Sign() == 0 {
+			return &object2.Integer{Value: 1}
+		}
+		return object2.IntegerFromBig(base)
+	}
+	if !exponent.IsInt64() || (int64(base.BitLen())-1)*exponent.Int64() > maxIntegerBits {
+		return newError("integer overflow: %s ** %s is too large", base, exponent)
+	}
+	return object2.IntegerFromBig(base.Exp(base, exponent, nil))
+}
+
+// clampedInt64 returns the value of an INTEGER object, a BigInteger is
+// saturated to the int64 range. Good enough for indexes, which are out of
+// range either way.
+func clampedInt64(obj object2.Object) int64 {
+	switch obj := obj.(type) {
+	case *object2.Integer:
+		return obj.Value
+	case *object2.BigInteger:
+		if obj.Value.Sign() < 0 {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,173 @@
+package evaluator
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/object2"
+)
+
+// evalMatchExpression tries the arms in order. Each attempt binds the names of
+// the pattern in a fresh environment enclosed by env, so a failed attempt
+// leaves nothing behind. When tail is true the body is in tail position.
+func evalMatchExpression(node *ast.MatchExpression, env *object2.Environment, tail bool) object2.Object {
+	value := Eval(node.Value, env)
+	if isError(value) {
+		return value
+	}
+	for _, arm := range node.Arms {
+		armEnv := object2.NewEnclosedEnvironment(env, 0)
+		mismatch, err := matchPattern(arm.Pattern, value, armEnv)
+		if err != nil {
+			return err
+		}
+		if mismatch != "" {
+			continue
+		}
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		if tail {
+			return evalTailBlock(arm.Body, armEnv, true)
+		}
+		return Eval(arm.Body, armEnv)
+	}
+	return newError("no match arm matches value: %s", value.Inspect())
+}
+
+// evalDestructuringLet binds the names of a let pattern in env, a value of
+// the wrong shape is an error
+func evalDestructuringLet(node *ast.LetStatement, value object2.Object, env *object2.Environment) object2.Object {
+	mismatch, err := matchPattern(node.Pattern, value, env)
+	if err != nil {
+		return err
+	}
+	if mismatch != "" {
+		return newError("cannot destructure %s: %s", value.Inspect(), mismatch)
+	}
+	return nil
+}
+
+// matchPattern checks that value has the shape of pattern and binds the names
+// of the pattern in env. mismatch describes why the value does not match and
+// is empty when it does, err is set when evaluating a default value fails.
+func matchPattern(pattern ast.Pattern, value object2.Object, env *object2.Environment) (mismatch string, err object2.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return "", nil
+	case *ast.Identifier:
+		env.Set(pattern.Slot, pattern.Value, value)
+		return "", nil
+	case *ast.LiteralPattern:
+		expected := Eval(pattern.Value, env)
+		if !objectsEqual(expected, value) {
+			return fmt.Sprintf("expected %s, got=%s", expected.Inspect(), value.Inspect()), nil
+		}
+		return "", nil
+	case *ast.DefaultPattern:
+		if value == nil || value == NULL {
+			value = Eval(pattern.Default, env)
+			if isError(value) {
+				return "", value
+			}
+		}
+		return matchPattern(pattern.Pattern, value, env)
+	case *ast.ArrayPattern:
+		return matchArrayPattern(pattern, value, env)
+	case *ast.VariantPattern:
+		return matchVariantPattern(pattern, value, env)
+	case *ast.HashPattern:
+		hash, ok := value.(*object2.Hash)
+		if !ok {
+			return fmt.Sprintf("expected HASH, got=%s", value.Type()), nil
+		}
+		for i, keyNode := range pattern.Keys {
+			key := Eval(keyNode, env).(object2.Hashable)
+			var element object2.Object
+			if pair, ok := hash.Pairs.Get(key.HashKey()); ok {
+				element = pair.Value
+			} else if _, ok := pattern.Values[i].(*ast.DefaultPattern); !ok {
+				return fmt.Sprintf("missing key %s", keyNode.String()), nil
+			}
+			if mismatch, err := matchPattern(pattern.Values[i], element, env); mismatch != "" || err != nil {
+				return mismatch, err
+			}
+		}
+		return "", nil
+	default:
+		return fmt.Sprintf("unsupported pattern %s", pattern.String()), nil
+	}
+}
+
+// elements with a default may be missing from the end of the array
+func matchArrayPattern(pattern *ast.ArrayPattern, value object2.Object, env *object2.Environment) (string, object2.Object) {
+	array, ok := value.(*object2.Array)
+	if !ok {
+		return fmt.Sprintf("expected ARRAY, got=%s", value.Type()), nil
+	}
+	required := 0
+	for i, element := range pattern.Elements {
+		if _, ok := element.(*ast.DefaultPattern); !ok {
+			required = i + 1
+		}
+	}
+	length := array.Elements.Len()
+	switch {
+	case pattern.Rest == nil && (length < required || length > len(pattern.Elements)):
+		if required == len(pattern.Elements) {
+			return fmt.Sprintf("expected %d elements, got=%d", required, length), nil
+		}
+		return fmt.Sprintf("expected %d to %d elements, got=%d", required, len(pattern.Elements), length), nil
+	case length < required:
+		return fmt.Sprintf("expected at least %d elements, got=%d", required, length), nil
+	}
+
+	rest := array.Elements
+	for _, element := range pattern.Elements {
+		var item object2.Object
+		if rest.Len() > 0 {
+			item = rest.Get(0)
+			rest = rest.Rest()
+		}
+		if mismatch, err := matchPattern(element, item, env); mismatch != "" || err != nil {
+			return mismatch, err
+		}
+	}
+	if pattern.Rest != nil {
+		return matchPattern(pattern.Rest, &object2.Array{Elements: rest}, env)
+	}
+	return "", nil
+}
+
+// objectsEqual compares a literal with a value, values of different types
+// are never equal. Enum values are equal when their variants are the same and
+// their payloads are equal.
+func objectsEqual(left object2.Object, right object2.Object) bool {
+	if left.Type() != right.Type() {
+		return false
+	}
+	switch left := left.(type) {
+	case *object2.Integer, *object2.BigInteger:
+		return evalIntegerInfixExpression("==", left, right) == TRUE
+	case *object2.String:
+		return left.Value == right.(*object2.String).Value
+	case *object2.EnumValue:
+		right := right.(*object2.EnumValue)
+		if left.Variant != right.Variant {
+			return false
+		}
+		for i, value := range left.Values {
+			if !objectsEqual(value, right.Values[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return left == right
+	}
+}
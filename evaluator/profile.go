@@ -0,0 +1,175 @@
+package evaluator
+
+import (
+	"interpreter/ast"
+	"interpreter/object2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProfileFunction is a function seen by a Profile, a Monkey function or a
+// builtin. Line is where a Monkey function is defined, 0 for the others.
+type ProfileFunction struct {
+	Name string
+	Line int
+}
+
+// ProfileSample holds what happened while the functions of Stack were
+// running, innermost last, with the innermost one the function running
+type ProfileSample struct {
+	Stack []int // indexes in Functions
+	// Calls is how many times the innermost function was entered from the
+	// rest of the stack
+	Calls int64
+	// Time is the time spent in the innermost function itself
+	Time time.Duration
+	// Allocations is how many values the innermost function created:
+	// literals, results of operators, struct and enum values and the
+	// environments of its calls
+	Allocations int64
+}
+
+// Profile records the calls of applyFunction while it is the Profile of a
+// Context.
+// The program itself is the function at the bottom of every stack.
+type Profile struct {
+	Functions []ProfileFunction
+	functions map[interface{}]int // function index by body or builtin
+	stack     []int
+	current   *ProfileSample // sample of stack
+	samples   map[string]*ProfileSample
+	order     []string // sample keys in the order they were seen
+	last      time.Time
+	now       func() time.Time
+}
+
+// NewProfile starts a profile of a program run from now on
+func NewProfile() *Profile {
+	return newProfile(time.Now)
+}
+
+func newProfile(now func() time.Time) *Profile {
+	p := &Profile{
+		Functions: []ProfileFunction{{Name: "<main>"}},
+		functions: make(map[interface{}]int),
+		stack:     []int{0},
+		samples:   make(map[string]*ProfileSample),
+		now:       now,
+	}
+	p.moved()
+	p.current.Calls++
+	p.last = now()
+	return p
+}
+
+// Stop charges the time since the last call or return to the program
+func (p *Profile) Stop() {
+	p.charge()
+}
+
+// Samples returns the samples in the order their stacks were first seen
+func (p *Profile) Samples() []*ProfileSample {
+	samples := make([]*ProfileSample, len(p.order))
+	for i, key := range p.order {
+		samples[i] = p.samples[key]
+	}
+	return samples
+}
+
+// moved finds the sample of the stack after it changed
+func (p *Profile) moved() {
+	var key strings.Builder
+	for _, fn := range p.stack {
+		key.WriteString(strconv.Itoa(fn))
+		key.WriteByte(' ')
+	}
+	sample, ok := p.samples[key.String()]
+	if !ok {
+		sample = &ProfileSample{Stack: append([]int{}, p.stack...)}
+		p.samples[key.String()] = sample
+		p.order = append(p.order, key.String())
+	}
+	p.current = sample
+}
+
+// charge gives the time since the last event to the running function
+func (p *Profile) charge() {
+	now := p.now()
+	p.current.Time += now.Sub(p.last)
+	p.last = now
+}
+
+// function returns the index of fn, a Monkey function is known by its body
+// as all the closures of one literal are the same function
+func (p *Profile) function(fn object2.Object) int {
+	var key interface{} = fn
+	if f, ok := fn.(*object2.Function); ok {
+		key = f.Body
+	}
+	if index, ok := p.functions[key]; ok {
+		return index
+	}
+	info := ProfileFunction{}
+	switch fn := fn.(type) {
+	case *object2.Function:
+		info = ProfileFunction{Name: functionName(fn), Line: fn.Body.Token.Line}
+	case *object2.Builtin:
+		info = ProfileFunction{Name: builtinName(fn)}
+	}
+	p.functions[key] = len(p.Functions)
+	p.Functions = append(p.Functions, info)
+	return len(p.Functions) - 1
+}
+
+// enter and exit are called by applyFunction around a Monkey function or a
+// builtin
+func (p *Profile) enter(fn object2.Object) {
+	p.charge()
+	p.stack = append(p.stack, p.function(fn))
+	p.moved()
+	p.current.Calls++
+}
+
+func (p *Profile) exit() {
+	p.charge()
+	p.stack = p.stack[:len(p.stack)-1]
+	p.moved()
+}
+
+func (p *Profile) allocated() {
+	p.current.Allocations++
+}
+
+func builtinName(fn *object2.Builtin) string {
+	for name, builtin := range builtins {
+		if builtin == fn {
+			return name
+		}
+	}
+	return "<builtin>"
+}
+
+// allocates reports whether evaluating node created result
+func allocates(node ast.Node, result object2.Object) bool {
+	switch result.(type) {
+	case nil, *object2.Error, *object2.Boolean, *object2.Null, *object2.ReturnValue, *tailCall:
+		return false
+	}
+	switch node.(type) {
+	case *ast.StringLiteral, *ast.InterpolatedString, *ast.IntegerLiteral,
+		*ast.PrefixExpression, *ast.InfixExpression, *ast.FunctionLiteral,
+		*ast.ArrayLiteral, *ast.HashLiteral, *ast.RangeExpression, *ast.SliceExpression:
+		return true
+	}
+	return false
+}
+
+// profileAllocation counts value as created by the running function unless
+// it is an error
+func profileAllocation(ctx *Context, value object2.Object) object2.Object {
+	if ctx.Profile != nil && !isError(value) {
+		ctx.Profile.allocated()
+	}
+	return value
+}
@@ -0,0 +1,59 @@
+package evaluator
+
+import (
+	"bytes"
+	"interpreter/ast"
+	"interpreter/object2"
+)
+
+// to_string needs applyFunction, which reaches builtins through Eval, so it
+// is registered here instead of in the builtins literal
+func init() {
+	builtins["to_string"] = &object2.Builtin{
+		Fn: func(env *object2.Environment, args ...object2.Object) object2.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return toString(env, args[0])
+		},
+	}
+}
+
+func evalInterpolatedString(node *ast.InterpolatedString, env *object2.Environment) object2.Object {
+	var out bytes.Buffer
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		str := toString(env, value)
+		if isError(str) {
+			return str
+		}
+		out.WriteString(str.(*object2.String).Value)
+	}
+	return &object2.String{Value: out.String()}
+}
+
+// toString converts a value to a String. A struct instance with a to_string
+// method is converted by calling it, any other value by its Inspect form.
+func toString(env *object2.Environment, obj object2.Object) object2.Object {
+	switch obj := obj.(type) {
+	case *object2.String:
+		return obj
+	case *object2.StructInstance:
+		method, ok := obj.Struct.Methods["to_string"]
+		if !ok {
+			break
+		}
+		str := applyFunction(env, method, []object2.Object{obj}, nil)
+		if isError(str) {
+			return str
+		}
+		if str.Type() != object2.STRING_OBJ {
+			return newError("to_string must return STRING, got=%s", str.Type())
+		}
+		return str
+	}
+	return &object2.String{Value: obj.Inspect()}
+}
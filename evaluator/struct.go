@@ -0,0 +1,97 @@
+package evaluator
+
+import (
+	"interpreter/ast"
+	"interpreter/object2"
+)
+
+// evalImplStatement adds the methods to the struct type, they close over env
+// like any function
+func evalImplStatement(node *ast.ImplStatement, env *object2.Environment) object2.Object {
+	target := evalIdentifier(node.Name, env)
+	if isError(target) {
+		return target
+	}
+	structType, ok := target.(*object2.StructType)
+	if !ok {
+		return newError("impl target must be STRUCT_TYPE, got=%s", target.Type())
+	}
+	for _, method := range node.Methods {
+		structType.Methods[method.Name.Value] = Eval(method.Function, env).(*object2.Function)
+	}
+	return nil
+}
+
+// newStructInstance builds an instance from positional and keyword
+// arguments, every field must be given exactly once
+func newStructInstance(structType *object2.StructType, args []object2.Object, keywords []keywordArgument) object2.Object {
+	if len(args) > len(structType.Fields) || (len(keywords) == 0 && len(args) < len(structType.Fields)) {
+		return newError("wrong number of arguments. got=%d, want=%d", len(args), len(structType.Fields))
+	}
+	fields := make([]object2.Object, len(structType.Fields))
+	copy(fields, args)
+	for _, kw := range keywords {
+		idx := structType.FieldIndex(kw.name)
+		if idx < 0 {
+			return newError("%s has no field %s", structType.Name, kw.name)
+		}
+		if fields[idx] != nil {
+			return newError("multiple values for field: %s", kw.name)
+		}
+		fields[idx] = kw.value
+	}
+	for i, value := range fields {
+		if value == nil {
+			return newError("missing field: %s", structType.Fields[i])
+		}
+	}
+	return &object2.StructInstance{Struct: structType, Fields: fields}
+}
+
+// p.x reads a field, p.norm gives the method bound to p and Point.new gives
+// the method itself. Result.Ok gives a variant of an enum.
+func evalDotExpression(node *ast.DotExpression, env *object2.Environment) object2.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	switch left := left.(type) {
+	case *object2.StructInstance:
+		if idx := left.Struct.FieldIndex(node.Name); idx >= 0 {
+			return left.Fields[idx]
+		}
+		if method, ok := left.Struct.Methods[node.Name]; ok {
+			return &object2.Method{Receiver: left, Function: method}
+		}
+		return newError("%s has no field or method %s", left.Struct.Name, node.Name)
+	case *object2.StructType:
+		if method, ok := left.Methods[node.Name]; ok {
+			return method
+		}
+		return newError("%s has no method %s", left.Name, node.Name)
+	case *object2.EnumType:
+		if variant := left.Variant(node.Name); variant != nil {
+			return variantBinding(variant)
+		}
+		return newError("%s has no variant %s", left.Name, node.Name)
+	default:
+		return newError("field access not supported: %s", left.Type())
+	}
+}
+
+func evalFieldAssignment(target *ast.DotExpression, val object2.Object, env *object2.Environment) object2.Object {
+	left := Eval(target.Left, env)
+	if isError(left) {
+		return left
+	}
+	instance, ok := left.(*object2.StructInstance)
+	if !ok {
+		return newError("field assignment not supported: %s", left.Type())
+	}
+	idx := instance.Struct.FieldIndex(target.Name)
+	if idx < 0 {
+		return newError("%s has no field %s", instance.Struct.Name, target.Name)
+	}
+	instance.Fields[idx] = val
+	return val
+}
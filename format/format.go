@@ -0,0 +1,578 @@
+/*
+	Pretty print Monkey programs in one canonical layout.
+		- four spaces per indentation level, one statement per line
+		- parentheses only where precedence needs them
+		- call arguments and hash literals that do not fit in maxWidth
+		  columns are broken one element per line
+		- comments and single blank lines between statements are kept
+	Formatting formatted source gives the same source back.
+*/
+package format
+
+import (
+	"errors"
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"interpreter/token2"
+	"strings"
+)
+
+const (
+	indentUnit = "    "
+	maxWidth   = 80
+)
+
+// Source formats a whole program, the parser errors are returned when src
+// does not parse
+func Source(src string) (string, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", errors.New(strings.Join(p.Errors(), "\n"))
+	}
+	pr := &printer{comments: l.Comments(), lines: strings.Split(src, "\n"), commentColumns: make(map[int]int)}
+	for _, comment := range l.Comments() {
+		pr.commentColumns[comment.Line] = comment.Column
+	}
+	return pr.program(program), nil
+}
+
+// Node formats a program, statement or expression built without source, so
+// there are no comments or blank lines to keep
+func Node(node ast.Node) string {
+	pr := &printer{}
+	switch node := node.(type) {
+	case *ast.Program:
+		return pr.program(node)
+	case ast.Statement:
+		return pr.statement(node, false)
+	case ast.Expression:
+		return pr.expression(node)
+	}
+	return ""
+}
+
+type printer struct {
+	indent int
+	// width of the text before the expression being formatted on its line,
+	// an estimate since nested expressions do not change it
+	lead int
+	// comments not printed yet, in source order
+	comments []token2.Token
+	// the source lines, to find the blank lines
+	lines []string
+	// the column of the comment on each line that has one
+	commentColumns map[int]int
+}
+
+func (p *printer) program(program *ast.Program) string {
+	text := p.statements(program.Statements, -1, false)
+	if text == "" {
+		return ""
+	}
+	return text + "\n"
+}
+
+// statements lays out a list of statements at the current indentation with
+// the comments before them. The comments before line end are printed after
+// them, all remaining ones when end is -1. In a block the last expression
+// statement gives the value of the block and goes without semicolon.
+func (p *printer) statements(statements []ast.Statement, end int, block bool) string {
+	out := []string{}
+	add := func(line int, text string) {
+		if len(out) > 0 && p.blankBefore(line) {
+			out = append(out, "")
+		}
+		out = append(out, text)
+	}
+	// an if or match statement needs a semicolon only when the next
+	// statement could be read as its continuation, before its comment
+	open, openComment := -1, ""
+	for i, statement := range statements {
+		line := statementToken(statement).Line
+		for _, comment := range p.commentsBefore(line) {
+			add(comment.Line, p.indentation()+comment.Literal)
+			open = -1
+		}
+		last := block && i == len(statements)-1
+		text := p.statement(statement, last)
+		if open >= 0 && continues(text) {
+			out[open] = strings.TrimSuffix(out[open], openComment) + ";" + openComment
+		}
+		open = -1
+		// the comment after the last token of the statement, which is
+		// followed by nothing else on its line
+		limit := end
+		if i+1 < len(statements) {
+			limit = statementToken(statements[i+1]).Line
+		}
+		comment := ""
+		if len(p.comments) > 0 && p.comments[0].Line == p.lastCodeLine(line, limit) {
+			comment = " " + p.comments[0].Literal
+			p.comments = p.comments[1:]
+		}
+		add(line, p.indentation()+text+comment)
+		if _, ok := statement.(*ast.ExpressionStatement); ok && !last && !strings.HasSuffix(text, ";") {
+			open, openComment = len(out)-1, comment
+		}
+	}
+	if end != 0 {
+		for _, comment := range p.commentsBefore(end) {
+			add(comment.Line, p.indentation()+comment.Literal)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// continues tells whether a statement starting with text could be parsed as
+// part of the expression before it
+func continues(text string) bool {
+	return strings.IndexAny(text[:1], "([-+*/%<>=!&|^.") == 0
+}
+
+// takes the comments before line from the pending ones, every comment when
+// line is -1
+func (p *printer) commentsBefore(line int) []token2.Token {
+	n := 0
+	for n < len(p.comments) && (line < 0 || p.comments[n].Line < line) {
+		n++
+	}
+	comments := p.comments[:n]
+	p.comments = p.comments[n:]
+	for i := range comments {
+		comments[i].Literal = strings.TrimRight(comments[i].Literal, " \t\r")
+	}
+	return comments
+}
+
+// lastCodeLine returns the last source line from from up to before limit
+// with more than a comment on it, 0 when there is none. limit -1 is the end.
+func (p *printer) lastCodeLine(from int, limit int) int {
+	if limit < 0 || limit > len(p.lines) {
+		limit = len(p.lines) + 1
+	}
+	for line := limit - 1; line >= from && line >= 1; line-- {
+		text := p.lines[line-1]
+		if column, ok := p.commentColumns[line]; ok {
+			text = text[:column-1]
+		}
+		if strings.TrimSpace(text) != "" {
+			return line
+		}
+	}
+	return 0
+}
+
+// whether the source line before line is blank
+func (p *printer) blankBefore(line int) bool {
+	return line >= 2 && line-2 < len(p.lines) && strings.TrimSpace(p.lines[line-2]) == ""
+}
+
+func (p *printer) indentation() string {
+	return strings.Repeat(indentUnit, p.indent)
+}
+
+func statementToken(statement ast.Statement) token2.Token {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		return statement.Token
+	case *ast.ReturnStatement:
+		return statement.Token
+	case *ast.ExpressionStatement:
+		return statement.Token
+	case *ast.BlockStatement:
+		return statement.Token
+	case *ast.FunctionDeclaration:
+		return statement.Token
+	case *ast.StructStatement:
+		return statement.Token
+	case *ast.ImplStatement:
+		return statement.Token
+	case *ast.EnumStatement:
+		return statement.Token
+	}
+	return token2.Token{}
+}
+
+// statement formats one statement without indenting its first line
+func (p *printer) statement(statement ast.Statement, last bool) string {
+	defer func(lead int) { p.lead = lead }(p.lead)
+	p.lead = 0
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		var target string
+		if statement.Pattern != nil {
+			target = p.pattern(statement.Pattern)
+		} else {
+			target = statement.Name.Value
+		}
+		if statement.Type != nil {
+			target += ": " + statement.Type.String()
+		}
+		head := statement.Token.Literal + " " + target + " = "
+		p.lead = len(head)
+		return head + p.expression(statement.Value) + ";"
+	case *ast.ReturnStatement:
+		p.lead = len("return ")
+		return "return " + p.expression(statement.ReturnValue) + ";"
+	case *ast.ExpressionStatement:
+		text := p.expression(statement.Expression)
+		switch statement.Expression.(type) {
+		case *ast.IfExpression, *ast.MatchExpression:
+			// the semicolon is added when the next statement needs it
+			return text
+		}
+		if last {
+			return text
+		}
+		return text + ";"
+	case *ast.BlockStatement:
+		return p.block(statement)
+	case *ast.FunctionDeclaration:
+		return "fn " + statement.Name.Value + p.function(statement.Function)
+	case *ast.StructStatement:
+		return "struct " + statement.Name.Value + " " + braced(identifiers(statement.Fields))
+	case *ast.EnumStatement:
+		variants := []string{}
+		for _, variant := range statement.Variants {
+			text := variant.Name.Value
+			if len(variant.Fields) > 0 {
+				text += "(" + strings.Join(identifiers(variant.Fields), ", ") + ")"
+			}
+			variants = append(variants, text)
+		}
+		return "enum " + statement.Name.Value + " " + braced(variants)
+	case *ast.ImplStatement:
+		if len(statement.Methods) == 0 {
+			return "impl " + statement.Name.Value + " {}"
+		}
+		methods := []ast.Statement{}
+		for _, method := range statement.Methods {
+			methods = append(methods, method)
+		}
+		p.indent++
+		body := p.statements(methods, 0, false)
+		p.indent--
+		return "impl " + statement.Name.Value + " {\n" + body + "\n" + p.indentation() + "}"
+	}
+	return ""
+}
+
+func identifiers(idents []*ast.Identifier) []string {
+	names := []string{}
+	for _, ident := range idents {
+		names = append(names, ident.Value)
+	}
+	return names
+}
+
+// { a, b } on one line, {} when empty
+func braced(items []string) string {
+	if len(items) == 0 {
+		return "{}"
+	}
+	return "{ " + strings.Join(items, ", ") + " }"
+}
+
+func (p *printer) block(block *ast.BlockStatement) string {
+	if len(block.Statements) == 0 && (len(p.comments) == 0 || p.comments[0].Line >= block.End.Line) {
+		return "{}"
+	}
+	p.indent++
+	body := p.statements(block.Statements, block.End.Line, true)
+	p.indent--
+	return "{\n" + body + "\n" + p.indentation() + "}"
+}
+
+// function formats the parameters and the body of fn
+func (p *printer) function(fn *ast.FunctionLiteral) string {
+	params := []string{}
+	for i, param := range fn.Parameters {
+		text := param.Value
+		if i < len(fn.ParameterTypes) && fn.ParameterTypes[i] != nil {
+			text += ": " + fn.ParameterTypes[i].String()
+		}
+		if i < len(fn.Defaults) && fn.Defaults[i] != nil {
+			text += " = " + p.expression(fn.Defaults[i])
+		}
+		params = append(params, text)
+	}
+	if fn.Rest != nil {
+		params = append(params, "..."+fn.Rest.Value)
+	}
+	head := "(" + strings.Join(params, ", ") + ") "
+	if fn.ReturnType != nil {
+		head += "-> " + fn.ReturnType.String() + " "
+	}
+	return head + p.block(fn.Body)
+}
+
+// precedence returns how tightly exp holds together, an operand that binds
+// more loosely than its operator needs parentheses
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(exp.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.RangeExpression:
+		return parser.RANGE
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression, *ast.SliceExpression, *ast.DotExpression:
+		return parser.INDEX
+	default:
+		return parser.INDEX + 1
+	}
+}
+
+// operand formats exp, in parentheses when it binds more loosely than min
+func (p *printer) operand(exp ast.Expression, min int) string {
+	text := p.expression(exp)
+	if precedence(exp) < min {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+func (p *printer) expression(exp ast.Expression) string {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return exp.Value
+	case *ast.IntegerLiteral:
+		return exp.Token.Literal
+	case *ast.Boolean:
+		return exp.Token.Literal
+	case *ast.StringLiteral:
+		return `"` + exp.Value + `"`
+	case *ast.InterpolatedString:
+		var out strings.Builder
+		out.WriteString(`"`)
+		for _, part := range exp.Parts {
+			if literal, ok := part.(*ast.StringLiteral); ok {
+				out.WriteString(literal.Value)
+			} else {
+				out.WriteString("${" + p.expression(part) + "}")
+			}
+		}
+		out.WriteString(`"`)
+		return out.String()
+	case *ast.PrefixExpression:
+		return exp.Operator + p.operand(exp.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		// ** is right associative, every other operator left associative
+		prec := parser.Precedence(exp.Token.Type)
+		left, right := prec, prec+1
+		if exp.Token.Type == token2.POWER {
+			left, right = prec+1, prec
+		}
+		return p.operand(exp.Left, left) + " " + exp.Operator + " " + p.operand(exp.Right, right)
+	case *ast.RangeExpression:
+		operator := "..<"
+		if exp.Inclusive {
+			operator = ".."
+		}
+		return p.operand(exp.Start, parser.RANGE) + operator + p.operand(exp.End, parser.RANGE+1)
+	case *ast.AssignExpression:
+		return p.operand(exp.Target, parser.ASSIGN+1) + " = " + p.operand(exp.Value, parser.ASSIGN)
+	case *ast.IfExpression:
+		text := "if (" + p.expression(exp.Condition) + ") " + p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			text += " else " + p.block(exp.Alternative)
+		}
+		return text
+	case *ast.FunctionLiteral:
+		return "fn" + p.function(exp)
+	case *ast.CallExpression:
+		function := p.operand(exp.Function, parser.CALL)
+		return function + p.list(len(function), "(", ")", exp.Arguments, p.argument)
+	case *ast.ArrayLiteral:
+		elements := []string{}
+		for _, element := range exp.Elements {
+			elements = append(elements, p.expression(element))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *ast.HashLiteral:
+		return p.list(0, "{", "}", exp.Keys, func(key ast.Expression) string {
+			return p.expression(key) + ": " + p.expression(exp.Pairs[key])
+		})
+	case *ast.IndexExpression:
+		return p.operand(exp.Left, parser.CALL) + "[" + p.expression(exp.Index) + "]"
+	case *ast.SliceExpression:
+		parts := []string{"", ""}
+		if exp.Start != nil {
+			parts[0] = p.expression(exp.Start)
+		}
+		if exp.End != nil {
+			parts[1] = p.expression(exp.End)
+		}
+		if exp.Step != nil {
+			parts = append(parts, p.expression(exp.Step))
+		}
+		return p.operand(exp.Left, parser.CALL) + "[" + strings.Join(parts, ":") + "]"
+	case *ast.DotExpression:
+		return p.operand(exp.Left, parser.CALL) + "." + exp.Name
+	case *ast.MatchExpression:
+		return p.match(exp)
+	}
+	return ""
+}
+
+func (p *printer) argument(arg ast.Expression) string {
+	switch arg := arg.(type) {
+	case *ast.KeywordArgument:
+		return arg.Name + ": " + p.expression(arg.Value)
+	case *ast.SpreadExpression:
+		return "..." + p.expression(arg.Value)
+	default:
+		return p.expression(arg)
+	}
+}
+
+// list formats the items between open and close on one line, or one item per
+// line with a trailing comma when the line would be longer than maxWidth.
+// before is the width of the text in front of open.
+func (p *printer) list(before int, open string, close string, items []ast.Expression, format func(ast.Expression) string) string {
+	pending := p.comments
+	texts := []string{}
+	for _, item := range items {
+		texts = append(texts, format(item))
+	}
+	flat := open + strings.Join(texts, ", ") + close
+	width := len(p.indentation()) + p.lead + before + len(flat)
+	if len(items) == 0 || strings.Contains(flat, "\n") || width <= maxWidth {
+		return flat
+	}
+	// format again one level deeper, every item on a line of its own
+	p.comments = pending
+	defer func(lead int) { p.lead = lead }(p.lead)
+	p.lead = 0
+	p.indent++
+	var out strings.Builder
+	out.WriteString(open + "\n")
+	for _, item := range items {
+		out.WriteString(p.indentation() + format(item) + ",\n")
+	}
+	p.indent--
+	out.WriteString(p.indentation() + close)
+	return out.String()
+}
+
+func (p *printer) match(exp *ast.MatchExpression) string {
+	head := "match (" + p.expression(exp.Value) + ") "
+	if len(exp.Arms) == 0 {
+		return head + "{}"
+	}
+	p.indent++
+	arms := []string{}
+	for _, arm := range exp.Arms {
+		for _, comment := range p.commentsBefore(patternToken(arm.Pattern).Line) {
+			arms = append(arms, p.indentation()+comment.Literal)
+		}
+		text := p.pattern(arm.Pattern)
+		if arm.Guard != nil {
+			text += " if " + p.expression(arm.Guard)
+		}
+		text += " => " + p.armBody(arm.Body)
+		arms = append(arms, p.indentation()+text+",")
+	}
+	p.indent--
+	return head + "{\n" + strings.Join(arms, "\n") + "\n" + p.indentation() + "}"
+}
+
+// a body written as an expression is kept as one, a hash literal needs
+// parentheses to not be read as a block
+func (p *printer) armBody(body *ast.BlockStatement) string {
+	if body.Token.Type == token2.LBRACE {
+		return p.block(body)
+	}
+	exp := body.Statements[0].(*ast.ExpressionStatement).Expression
+	if _, ok := exp.(*ast.HashLiteral); ok {
+		return "(" + p.expression(exp) + ")"
+	}
+	return p.expression(exp)
+}
+
+func patternToken(pattern ast.Pattern) token2.Token {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return pattern.Token
+	case *ast.WildcardPattern:
+		return pattern.Token
+	case *ast.LiteralPattern:
+		return pattern.Token
+	case *ast.ArrayPattern:
+		return pattern.Token
+	case *ast.HashPattern:
+		return pattern.Token
+	case *ast.VariantPattern:
+		return pattern.Token
+	case *ast.DefaultPattern:
+		return patternToken(pattern.Pattern)
+	}
+	return token2.Token{}
+}
+
+func (p *printer) pattern(pattern ast.Pattern) string {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return pattern.Value
+	case *ast.WildcardPattern:
+		return "_"
+	case *ast.LiteralPattern:
+		return p.expression(pattern.Value)
+	case *ast.DefaultPattern:
+		return p.pattern(pattern.Pattern) + " = " + p.expression(pattern.Default)
+	case *ast.ArrayPattern:
+		elements := p.patterns(pattern.Elements)
+		switch rest := pattern.Rest.(type) {
+		case nil:
+		case *ast.WildcardPattern:
+			elements = append(elements, "...")
+		default:
+			elements = append(elements, "..."+p.pattern(rest))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *ast.HashPattern:
+		pairs := []string{}
+		for i, key := range pattern.Keys {
+			pairs = append(pairs, p.hashPatternPair(key, pattern.Values[i]))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *ast.VariantPattern:
+		if pattern.Elements == nil {
+			return pattern.Name.Value
+		}
+		return pattern.Name.Value + "(" + strings.Join(p.patterns(pattern.Elements), ", ") + ")"
+	}
+	return ""
+}
+
+func (p *printer) patterns(patterns []ast.Pattern) []string {
+	texts := []string{}
+	for _, pattern := range patterns {
+		texts = append(texts, p.pattern(pattern))
+	}
+	return texts
+}
+
+// a key written as a bare name stays bare, and so does its value when it
+// binds the same name: {name, age: years}
+func (p *printer) hashPatternPair(key ast.Expression, value ast.Pattern) string {
+	literal, ok := key.(*ast.StringLiteral)
+	if !ok || literal.Token.Type != token2.IDENT {
+		return p.expression(key) + ": " + p.pattern(value)
+	}
+	binding := value
+	if withDefault, ok := value.(*ast.DefaultPattern); ok {
+		binding = withDefault.Pattern
+	}
+	if ident, ok := binding.(*ast.Identifier); ok && ident.Value == literal.Value {
+		return p.pattern(value)
+	}
+	return literal.Value + ": " + p.pattern(value)
+}
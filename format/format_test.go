@@ -0,0 +1,144 @@
+package format
+
+import (
+	goast "go/ast"
+	goparser "go/parser"
+	"go/token"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"strconv"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"let x = ((1 + 2)) * 3;", "let x = (1 + 2) * 3;\n"},
+		{"a - (b - c); (a - b) - c", "a - (b - c);\na - b - c;\n"},
+		{"a ** (b ** c); (a ** b) ** c", "a ** b ** c;\n(a ** b) ** c;\n"},
+		{"-(a ** b); (-a) ** b; -(a + b)", "-a ** b;\n(-a) ** b;\n-(a + b);\n"},
+		{"(a + b)(1); f(x)[0].y; (a + b).c", "(a + b)(1);\nf(x)[0].y;\n(a + b).c;\n"},
+		{"x = y = 1; (x = 1) + 2", "x = y = 1;\n(x = 1) + 2;\n"},
+		{"1..10; 1..<n; (a..b)..c; a..(b..c)", "1..10;\n1..<n;\na..b..c;\na..(b..c);\n"},
+		{"a[1:2]; a[:2]; a[::2]; a[:]", "a[1:2];\na[:2];\na[::2];\na[:];\n"},
+		{"f(1, ...xs, y: 2)", "f(1, ...xs, y: 2);\n"},
+		{`"a ${x + 1} b"`, "\"a ${x + 1} b\";\n"},
+		{"let add = fn(a,b){a+b};", "let add = fn(a, b) {\n    a + b\n};\n"},
+		{"fn f(x, y = 1, ...r) { return x; }", "fn f(x, y = 1, ...r) {\n    return x;\n}\n"},
+		{"let f = fn() {};", "let f = fn() {};\n"},
+		{"let n:int=1; fn f(a:[int],b:{string:bool}={})->fn(int)->int{a}",
+			"let n: int = 1;\nfn f(a: [int], b: {string: bool} = {}) -> fn(int) -> int {\n    a\n}\n"},
+		{
+			"if (x) { 1 } else { if (y) { 2 } else { 3 } }",
+			"if (x) {\n    1\n} else {\n    if (y) {\n        2\n    } else {\n        3\n    }\n}\n",
+		},
+		// a statement that could continue an if keeps the semicolon
+		{"if (x) { 1 }; -1; if (y) { 2 } z", "if (x) {\n    1\n};\n-1;\nif (y) {\n    2\n}\nz;\n"},
+		{
+			`match (x) { 0 => "zero", [h, ...] => { h } {name, age: a = 1} if a > 1 => ({"k": 1}), Ok(v) => v, _ => null }`,
+			"match (x) {\n    0 => \"zero\",\n    [h, ...] => {\n        h\n    },\n    {name, age: a = 1} if a > 1 => ({\"k\": 1}),\n    Ok(v) => v,\n    _ => null,\n}\n",
+		},
+		{`let {"k": v, name: n} = h;`, "let {\"k\": v, name: n} = h;\n"},
+		{"struct P{x,y} enum R{Ok(v),None} impl P{fn zero(){P(0,0)}}",
+			"struct P { x, y }\nenum R { Ok(v), None }\nimpl P {\n    fn zero() {\n        P(0, 0)\n    }\n}\n"},
+		// long argument and hash lists are broken one item per line
+		{
+			`puts(add(1, 2), "some long string argument here", "another long string argument", x);`,
+			"puts(\n    add(1, 2),\n    \"some long string argument here\",\n    \"another long string argument\",\n    x,\n);\n",
+		},
+		{
+			`let hash = {"alpha": 1, "beta": 2, "gamma": 3, "delta": 4, "epsilon": 5, "zeta": 6};`,
+			"let hash = {\n    \"alpha\": 1,\n    \"beta\": 2,\n    \"gamma\": 3,\n    \"delta\": 4,\n    \"epsilon\": 5,\n    \"zeta\": 6,\n};\n",
+		},
+		// comments and single blank lines are kept
+		{
+			"// head\nlet x = 1; // trailing\n\n\n\n// about f\nfn f() {\n  // inside\n  x // last\n  // end\n}\nlet g = fn() {\n  // only\n};\n// tail",
+			"// head\nlet x = 1; // trailing\n\n// about f\nfn f() {\n    // inside\n    x // last\n    // end\n}\nlet g = fn() {\n    // only\n};\n// tail\n",
+		},
+		{"match (x) {\n  // zero\n  0 => 1,\n}", "match (x) {\n    // zero\n    0 => 1,\n}\n"},
+		// a trailing comment stays after the last token of its line
+		{"let g = fn(x) { x }; // note", "let g = fn(x) {\n    x\n}; // note\n"},
+		{"if (x) { 1 } // b", "if (x) {\n    1\n} // b\n"},
+		{"if (x) { 1 }; // b\n-1", "if (x) {\n    1\n}; // b\n-1;\n"},
+		{"let a = 1; let b = 2; // c", "let a = 1;\nlet b = 2; // c\n"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		out, err := Source(tt.input)
+		if err != nil {
+			t.Errorf("input %q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if out != tt.expected {
+			t.Errorf("input %q:\nexpected=%q\ngot=     %q", tt.input, tt.expected, out)
+		}
+		if again, _ := Source(out); again != out {
+			t.Errorf("input %q: formatting is not idempotent.\nfirst=%q\nsecond=%q", tt.input, out, again)
+		}
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	_, err := Source("let x 1;")
+	if err == nil || err.Error() != "expected next token to be =, got INT instead" {
+		t.Errorf("expected the parser error, got=%v", err)
+	}
+}
+
+// every input of the parser tests that parses must keep its meaning when
+// formatted, and formatting the result again must not change it
+func TestRoundTripParserInputs(t *testing.T) {
+	inputs := parserTestInputs(t)
+	checked := 0
+	for _, input := range inputs {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 || len(program.Statements) == 0 {
+			continue
+		}
+		checked++
+		out, err := Source(input)
+		if err != nil {
+			t.Errorf("input %q: unexpected error %v", input, err)
+			continue
+		}
+		p = parser.New(lexer.New(out))
+		formatted := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("input %q: formatted %q does not parse: %v", input, out, p.Errors())
+			continue
+		}
+		if formatted.String() != program.String() {
+			t.Errorf("input %q: formatted %q changed the program.\nexpected=%q\ngot=%q",
+				input, out, program.String(), formatted.String())
+		}
+		again, _ := Source(out)
+		if again != out {
+			t.Errorf("input %q: formatting is not idempotent.\nfirst=%q\nsecond=%q", input, out, again)
+		}
+	}
+	if checked < 100 {
+		t.Errorf("expected at least 100 parser test inputs, got=%d", checked)
+	}
+}
+
+// the string literals of the parser tests
+func parserTestInputs(t *testing.T) []string {
+	file, err := goparser.ParseFile(token.NewFileSet(), "../parser/parser_test.go", nil, 0)
+	if err != nil {
+		t.Fatalf("cannot read the parser tests: %v", err)
+	}
+	inputs := []string{}
+	goast.Inspect(file, func(node goast.Node) bool {
+		if lit, ok := node.(*goast.BasicLit); ok && lit.Kind == token.STRING {
+			if s, err := strconv.Unquote(lit.Value); err == nil {
+				inputs = append(inputs, s)
+			}
+		}
+		return true
+	})
+	return inputs
+}
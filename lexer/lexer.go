@@ -1,7 +1,9 @@
 package lexer
 
 import (
+	"fmt"
 	token2 "interpreter/token2"
+	"unicode/utf8"
 )
 
 type Lexer struct {
@@ -9,11 +11,22 @@ type Lexer struct {
 	position     int  // the current position of input string(pointed to current char)
 	readPosition int  // the next position of current position(pointed to the next char)
 	ch           byte // the reading character
+	line         int  // the line of ch, from 1
+	column       int  // the column of ch, from 1
+	// open braces inside each string interpolation being read, the
+	// innermost last. The } closing an interpolation resumes its string.
+	braces []int
+	// comments are not tokens, they are kept here for tools like the
+	// formatter
+	comments []token2.Token
+	// illegal characters and unterminated strings, the tokens are still
+	// given so the parser reports where it stops
+	errors []error
 }
 
 // To create a lexer
 func New(input string) *Lexer {
-	lexer := &Lexer{input: input}
+	lexer := &Lexer{input: input, line: 1}
 	// start to read char
 	lexer.readChar()
 	return lexer
@@ -21,6 +34,15 @@ func New(input string) *Lexer {
 
 // To read char
 func (l *Lexer) readChar() {
+	// past the end already, stay there
+	if l.readPosition > len(l.input) {
+		return
+	}
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -32,8 +54,21 @@ func (l *Lexer) readChar() {
 
 // Get next token2
 func (l *Lexer) NextToken() token2.Token {
+	l.skipTrivia()
+	line, column := l.line, l.column
+	token := l.readToken()
+	token.Line = line
+	token.Column = column
+	return token
+}
+
+// Comments returns the comments read so far, in source order
+func (l *Lexer) Comments() []token2.Token {
+	return l.comments
+}
+
+func (l *Lexer) readToken() token2.Token {
 	var token token2.Token
-	l.skipWhitespace()
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -41,6 +76,10 @@ func (l *Lexer) NextToken() token2.Token {
 			token.Literal = "=="
 			l.readChar()
 			break
+		} else if l.peekChar() == '>' {
+			token.Type = token2.ARROW
+			token.Literal = "=>"
+			l.readChar()
 		} else {
 			token = newToken(token2.ASSIGN, l.ch)
 		}
@@ -49,14 +88,36 @@ func (l *Lexer) NextToken() token2.Token {
 		token = newToken(token2.PLUS, l.ch)
 		break
 	case '-':
-		token = newToken(token2.MINUS, l.ch)
+		if l.peekChar() == '>' {
+			token.Type = token2.RARROW
+			token.Literal = "->"
+			l.readChar()
+		} else {
+			token = newToken(token2.MINUS, l.ch)
+		}
 		break
 	case '*':
-		token = newToken(token2.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			token.Type = token2.POWER
+			token.Literal = "**"
+			l.readChar()
+		} else {
+			token = newToken(token2.ASTERISK, l.ch)
+		}
 		break
 	case '/':
 		token = newToken(token2.SLASH, l.ch)
 		break
+	case '%':
+		token = newToken(token2.PERCENT, l.ch)
+	case '&':
+		token = newToken(token2.BIT_AND, l.ch)
+	case '|':
+		token = newToken(token2.BIT_OR, l.ch)
+	case '^':
+		token = newToken(token2.BIT_XOR, l.ch)
+	case '~':
+		token = newToken(token2.BIT_NOT, l.ch)
 	case '!':
 		if l.peekChar() == '=' {
 			token.Type = token2.NOT_EQ
@@ -68,10 +129,22 @@ func (l *Lexer) NextToken() token2.Token {
 		}
 		break
 	case '<':
-		token = newToken(token2.LT, l.ch)
+		if l.peekChar() == '<' {
+			token.Type = token2.LSHIFT
+			token.Literal = "<<"
+			l.readChar()
+		} else {
+			token = newToken(token2.LT, l.ch)
+		}
 		break
 	case '>':
-		token = newToken(token2.GT, l.ch)
+		if l.peekChar() == '>' {
+			token.Type = token2.RSHIFT
+			token.Literal = ">>"
+			l.readChar()
+		} else {
+			token = newToken(token2.GT, l.ch)
+		}
 		break
 	case ',':
 		token = newToken(token2.COMMA, l.ch)
@@ -86,15 +159,24 @@ func (l *Lexer) NextToken() token2.Token {
 		token = newToken(token2.RPAREN, l.ch)
 		break
 	case '{':
+		if len(l.braces) > 0 {
+			l.braces[len(l.braces)-1]++
+		}
 		token = newToken(token2.LBRACE, l.ch)
 		break
 	case '}':
+		if n := len(l.braces); n > 0 && l.braces[n-1] == 0 {
+			l.braces = l.braces[:n-1]
+			token = l.readStringSegment(token2.INTERP_END, token2.INTERP_MID)
+			break
+		} else if n > 0 {
+			l.braces[n-1]--
+		}
 		token = newToken(token2.RBRACE, l.ch)
 		break
 		// identify string type token
 	case '"':
-		token.Literal = l.readString()
-		token.Type = token2.STRING
+		token = l.readStringSegment(token2.STRING, token2.INTERP_START)
 	case '[':
 		token = newToken(token2.LBRACKET, l.ch)
 		break
@@ -104,6 +186,25 @@ func (l *Lexer) NextToken() token2.Token {
 	// COLON
 	case ':':
 		token = newToken(token2.COLON, l.ch)
+	// field access ., range operator .. and ..<, ... for rest patterns
+	case '.':
+		if l.peekChar() == '.' {
+			l.readChar()
+			if l.peekChar() == '.' {
+				l.readChar()
+				token.Type = token2.ELLIPSIS
+				token.Literal = "..."
+			} else if l.peekChar() == '<' {
+				l.readChar()
+				token.Type = token2.DOTDOTLT
+				token.Literal = "..<"
+			} else {
+				token.Type = token2.DOTDOT
+				token.Literal = ".."
+			}
+		} else {
+			token = newToken(token2.DOT, l.ch)
+		}
 	default:
 		if isLetter(l.ch) {
 			// It has already called function readChar()
@@ -114,8 +215,17 @@ func (l *Lexer) NextToken() token2.Token {
 			token.Literal = l.readNumber()
 			token.Type = token2.INT
 			return token
-		} else {
+		} else if l.atEnd() {
 			token = newToken(token2.EOF, l.ch)
+		} else {
+			// the whole character, not one byte of it
+			_, size := utf8.DecodeRuneInString(l.input[l.position:])
+			token.Type = token2.ILLEGAL
+			token.Literal = l.input[l.position : l.position+size]
+			l.errorAt(l.line, l.column, fmt.Sprintf("illegal character %q", token.Literal))
+			for i := 1; i < size; i++ {
+				l.readChar()
+			}
 		}
 	}
 	// read next char
@@ -140,10 +250,22 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-// handle the number
+// handle the number, with an optional 0x, 0o or 0b prefix and _ between digits
 func (l *Lexer) readNumber() string {
 	position := l.position
-	for isDigit(l.ch) {
+	isNumberChar := isDigit
+	if l.ch == '0' {
+		switch l.peekChar() {
+		case 'x', 'X':
+			isNumberChar = isHexDigit
+			l.readChar()
+			l.readChar()
+		case 'o', 'O', 'b', 'B':
+			l.readChar()
+			l.readChar()
+		}
+	}
+	for isNumberChar(l.ch) || l.ch == '_' {
 		l.readChar()
 	}
 	return l.input[position:l.position]
@@ -153,6 +275,10 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
 // notion : if the identifier start with '_' is also correct
 func isLetter(ch byte) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
@@ -165,6 +291,31 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+// skip white space and comments, keeping the comments
+func (l *Lexer) skipTrivia() {
+	l.skipWhitespace()
+	for l.ch == '/' && l.peekChar() == '/' {
+		comment := token2.Token{Type: token2.COMMENT, Line: l.line, Column: l.column}
+		position := l.position
+		for l.ch != '\n' && !l.atEnd() {
+			l.readChar()
+		}
+		comment.Literal = l.input[position:l.position]
+		l.comments = append(l.comments, comment)
+		l.skipWhitespace()
+	}
+}
+
+// atEnd reports whether the whole input has been read, a 0 byte before the
+// end is a character like any other
+func (l *Lexer) atEnd() bool {
+	return l.position >= len(l.input)
+}
+
+func (l *Lexer) errorAt(line, column int, msg string) {
+	l.errors = append(l.errors, fmt.Errorf("%d:%d: %s", line, column, msg))
+}
+
 // return peek char
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
@@ -174,13 +325,39 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
-func (l *Lexer) readString() string {
+// readStringSegment reads the text of a string up to its closing quote, giving
+// a token of type end, or up to the next ${, giving a token of type open
+func (l *Lexer) readStringSegment(end token2.TokenType, open token2.TokenType) token2.Token {
 	position := l.position + 1
+	line, column := l.line, l.column
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		if l.atEnd() {
+			l.errorAt(line, column, "unterminated string")
+		}
+		if l.ch == '"' || l.atEnd() {
+			return token2.Token{Type: end, Literal: l.input[position:l.position]}
+		}
+		if l.ch == '$' && l.peekChar() == '{' {
+			literal := l.input[position:l.position]
+			l.readChar()
+			l.braces = append(l.braces, 0)
+			return token2.Token{Type: open, Literal: literal}
+		}
+	}
+}
+
+// Tokenize returns every token of input up to and including the EOF token,
+// and the errors met on the way: illegal characters, which are ILLEGAL
+// tokens, and an unterminated string
+func Tokenize(input string) ([]token2.Token, []error) {
+	l := New(input)
+	tokens := []token2.Token{}
+	for {
+		token := l.NextToken()
+		tokens = append(tokens, token)
+		if token.Type == token2.EOF {
+			return tokens, l.errors
 		}
 	}
-	return l.input[position:l.position]
 }
@@ -1,8 +1,11 @@
 package lexer
 
 import (
+	"fmt"
 	"interpreter/token2"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 // test next token whether read correctly
@@ -127,3 +130,353 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestRangeTokens(t *testing.T) {
+	input := `1..10 1..<n a[1:2]`
+	tests := []struct {
+		expectedType    token2.TokenType
+		expectedLiteral string
+	}{
+		{token2.INT, "1"},
+		{token2.DOTDOT, ".."},
+		{token2.INT, "10"},
+		{token2.INT, "1"},
+		{token2.DOTDOTLT, "..<"},
+		{token2.IDENT, "n"},
+		{token2.IDENT, "a"},
+		{token2.LBRACKET, "["},
+		{token2.INT, "1"},
+		{token2.COLON, ":"},
+		{token2.INT, "2"},
+		{token2.RBRACKET, "]"},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		token := l.NextToken()
+		if token.Type != tt.expectedType || token.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q(%q), got=%q(%q)",
+				i, tt.expectedType, tt.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	input := `0x1F 0o17 0b101 1_000 007 12abc 1..2`
+	tests := []struct {
+		expectedType    token2.TokenType
+		expectedLiteral string
+	}{
+		{token2.INT, "0x1F"},
+		{token2.INT, "0o17"},
+		{token2.INT, "0b101"},
+		{token2.INT, "1_000"},
+		{token2.INT, "007"},
+		{token2.INT, "12"},
+		{token2.IDENT, "abc"},
+		{token2.INT, "1"},
+		{token2.DOTDOT, ".."},
+		{token2.INT, "2"},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		token := l.NextToken()
+		if token.Type != tt.expectedType || token.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q(%q), got=%q(%q)",
+				i, tt.expectedType, tt.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestOperatorTokens(t *testing.T) {
+	input := `a % b ** c & d | e ^ ~f << 2 >> 1 < g > h * i - j -> k`
+	tests := []struct {
+		expectedType    token2.TokenType
+		expectedLiteral string
+	}{
+		{token2.IDENT, "a"},
+		{token2.PERCENT, "%"},
+		{token2.IDENT, "b"},
+		{token2.POWER, "**"},
+		{token2.IDENT, "c"},
+		{token2.BIT_AND, "&"},
+		{token2.IDENT, "d"},
+		{token2.BIT_OR, "|"},
+		{token2.IDENT, "e"},
+		{token2.BIT_XOR, "^"},
+		{token2.BIT_NOT, "~"},
+		{token2.IDENT, "f"},
+		{token2.LSHIFT, "<<"},
+		{token2.INT, "2"},
+		{token2.RSHIFT, ">>"},
+		{token2.INT, "1"},
+		{token2.LT, "<"},
+		{token2.IDENT, "g"},
+		{token2.GT, ">"},
+		{token2.IDENT, "h"},
+		{token2.ASTERISK, "*"},
+		{token2.IDENT, "i"},
+		{token2.MINUS, "-"},
+		{token2.IDENT, "j"},
+		{token2.RARROW, "->"},
+		{token2.IDENT, "k"},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		token := l.NextToken()
+		if token.Type != tt.expectedType || token.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q(%q), got=%q(%q)",
+				i, tt.expectedType, tt.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestMatchTokens(t *testing.T) {
+	input := `match (x) { [h, ...t] => h, _ => 0 }`
+	tests := []struct {
+		expectedType    token2.TokenType
+		expectedLiteral string
+	}{
+		{token2.MATCH, "match"},
+		{token2.LPAREN, "("},
+		{token2.IDENT, "x"},
+		{token2.RPAREN, ")"},
+		{token2.LBRACE, "{"},
+		{token2.LBRACKET, "["},
+		{token2.IDENT, "h"},
+		{token2.COMMA, ","},
+		{token2.ELLIPSIS, "..."},
+		{token2.IDENT, "t"},
+		{token2.RBRACKET, "]"},
+		{token2.ARROW, "=>"},
+		{token2.IDENT, "h"},
+		{token2.COMMA, ","},
+		{token2.IDENT, "_"},
+		{token2.ARROW, "=>"},
+		{token2.INT, "0"},
+		{token2.RBRACE, "}"},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		token := l.NextToken()
+		if token.Type != tt.expectedType || token.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q(%q), got=%q(%q)",
+				i, tt.expectedType, tt.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestStructTokens(t *testing.T) {
+	input := `struct P { x } impl P { } p.x..y enum`
+	tests := []struct {
+		expectedType    token2.TokenType
+		expectedLiteral string
+	}{
+		{token2.STRUCT, "struct"},
+		{token2.IDENT, "P"},
+		{token2.LBRACE, "{"},
+		{token2.IDENT, "x"},
+		{token2.RBRACE, "}"},
+		{token2.IMPL, "impl"},
+		{token2.IDENT, "P"},
+		{token2.LBRACE, "{"},
+		{token2.RBRACE, "}"},
+		{token2.IDENT, "p"},
+		{token2.DOT, "."},
+		{token2.IDENT, "x"},
+		{token2.DOTDOT, ".."},
+		{token2.IDENT, "y"},
+		{token2.ENUM, "enum"},
+		{token2.EOF, "\x00"},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		token := l.NextToken()
+		if token.Type != tt.expectedType || token.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q(%q), got=%q(%q)",
+				i, tt.expectedType, tt.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestInterpolationTokens(t *testing.T) {
+	input := `"a ${x} b ${ {1: "${y}"}[1] } c" "${z}"`
+	tests := []struct {
+		expectedType    token2.TokenType
+		expectedLiteral string
+	}{
+		{token2.INTERP_START, "a "},
+		{token2.IDENT, "x"},
+		{token2.INTERP_MID, " b "},
+		{token2.LBRACE, "{"},
+		{token2.INT, "1"},
+		{token2.COLON, ":"},
+		{token2.INTERP_START, ""},
+		{token2.IDENT, "y"},
+		{token2.INTERP_END, ""},
+		{token2.RBRACE, "}"},
+		{token2.LBRACKET, "["},
+		{token2.INT, "1"},
+		{token2.RBRACKET, "]"},
+		{token2.INTERP_END, " c"},
+		{token2.INTERP_START, ""},
+		{token2.IDENT, "z"},
+		{token2.INTERP_END, ""},
+		{token2.EOF, "\x00"},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		token := l.NextToken()
+		if token.Type != tt.expectedType || token.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q(%q), got=%q(%q)",
+				i, tt.expectedType, tt.expectedLiteral, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 1; // one\n\tx + \"s ${y}\"\n// two\n"
+	tests := []struct {
+		expectedType   token2.TokenType
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token2.LET, 1, 1},
+		{token2.IDENT, 1, 5},
+		{token2.ASSIGN, 1, 7},
+		{token2.INT, 1, 9},
+		{token2.SEMICOLON, 1, 10},
+		{token2.IDENT, 2, 2},
+		{token2.PLUS, 2, 4},
+		{token2.INTERP_START, 2, 6},
+		{token2.IDENT, 2, 11},
+		{token2.INTERP_END, 2, 12},
+		{token2.EOF, 4, 1},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		token := l.NextToken()
+		if token.Type != tt.expectedType || token.Line != tt.expectedLine || token.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - wrong token. expected=%q at %d:%d, got=%q at %d:%d",
+				i, tt.expectedType, tt.expectedLine, tt.expectedColumn, token.Type, token.Line, token.Column)
+		}
+	}
+	comments := l.Comments()
+	if len(comments) != 2 {
+		t.Fatalf("expected 2 comments, got=%d", len(comments))
+	}
+	if comments[0].Literal != "// one" || comments[0].Line != 1 || comments[0].Column != 12 {
+		t.Errorf("wrong first comment. got=%q at %d:%d", comments[0].Literal, comments[0].Line, comments[0].Column)
+	}
+	if comments[1].Literal != "// two" || comments[1].Line != 3 || comments[1].Column != 1 {
+		t.Errorf("wrong second comment. got=%q at %d:%d", comments[1].Literal, comments[1].Line, comments[1].Column)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens, errs := Tokenize("x @ é\x00 \"open ${y}")
+	expected := []token2.Token{
+		{Type: token2.IDENT, Literal: "x", Line: 1, Column: 1},
+		{Type: token2.ILLEGAL, Literal: "@", Line: 1, Column: 3},
+		{Type: token2.ILLEGAL, Literal: "é", Line: 1, Column: 5},
+		{Type: token2.ILLEGAL, Literal: "\x00", Line: 1, Column: 7},
+		{Type: token2.INTERP_START, Literal: "open ", Line: 1, Column: 9},
+		{Type: token2.IDENT, Literal: "y", Line: 1, Column: 17},
+		{Type: token2.INTERP_END, Literal: "", Line: 1, Column: 18},
+		{Type: token2.EOF, Literal: "\x00", Line: 1, Column: 19},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d: %v", len(expected), len(tokens), tokens)
+	}
+	for i, token := range tokens {
+		if token != expected[i] {
+			t.Errorf("tokens[%d] wrong. expected=%+v, got=%+v", i, expected[i], token)
+		}
+	}
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	want := []string{`1:3: illegal character "@"`, `1:5: illegal character "é"`, `1:7: illegal character "\x00"`, "1:18: unterminated string"}
+	if strings.Join(messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wrong errors.\nexpected=%q\ngot=%q", want, messages)
+	}
+	if _, errs := Tokenize(`let s = "a ${b} c"; // done`); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+// offset returns where line and column, counted in bytes from 1, are in input
+func offset(input string, line, column int) int {
+	at := 0
+	for ; line > 1; line-- {
+		at += strings.IndexByte(input[at:], '\n') + 1
+	}
+	return at + column - 1
+}
+
+// Tokenize ends every input with a single EOF and every other token reads at
+// least one byte. The ILLEGAL tokens are the bad characters of the input:
+// with them blanked out the input gives the same tokens without them, and no
+// error but an unterminated string.
+func FuzzTokenize(f *testing.F) {
+	for _, seed := range []string{
+		"", "let x = fn(a, ...b) { a ** 2 }; x(1)", `"a ${b + "${c}"} d"`, `"open`,
+		"}}} ${ \"", "0x 0b1_ 1..<2 ...", "// comment", "@é\x00\xff", "match (x) { [a] => a }",
+		"x @ é\x00 \"open ${y}", "a\n\\b #c\n\t~?$",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		tokens, errs := Tokenize(input)
+		if len(tokens) == 0 || len(tokens) > len(input)+1 || tokens[len(tokens)-1].Type != token2.EOF {
+			t.Fatalf("%q: %d tokens, not ending in EOF", input, len(tokens))
+		}
+		blanked := []byte(input)
+		illegal := map[string]bool{}
+		for _, token := range tokens[:len(tokens)-1] {
+			if token.Type == token2.EOF {
+				t.Fatalf("%q: EOF before the end", input)
+			}
+			if token.Line < 1 || token.Column < 1 {
+				t.Fatalf("%q: token %q at %d:%d", input, token.Type, token.Line, token.Column)
+			}
+			if token.Type != token2.ILLEGAL {
+				continue
+			}
+			at := offset(input, token.Line, token.Column)
+			if token.Literal == "" || !strings.HasPrefix(input[at:], token.Literal) {
+				t.Fatalf("%q: ILLEGAL %q is not at %d:%d", input, token.Literal, token.Line, token.Column)
+			}
+			if _, size := utf8.DecodeRuneInString(token.Literal); size != len(token.Literal) {
+				t.Fatalf("%q: ILLEGAL %q is more than one character", input, token.Literal)
+			}
+			copy(blanked[at:], strings.Repeat(" ", len(token.Literal)))
+			illegal[fmt.Sprintf("%d:%d: illegal character %q", token.Line, token.Column, token.Literal)] = true
+		}
+		for _, err := range errs {
+			if !illegal[err.Error()] && !strings.HasSuffix(err.Error(), ": unterminated string") {
+				t.Fatalf("%q: error %q without an ILLEGAL token", input, err)
+			}
+			delete(illegal, err.Error())
+		}
+		if len(illegal) > 0 {
+			t.Fatalf("%q: ILLEGAL tokens without an error: %v", input, illegal)
+		}
+
+		again, errs := Tokenize(string(blanked))
+		for _, err := range errs {
+			if !strings.HasSuffix(err.Error(), ": unterminated string") {
+				t.Fatalf("%q blanked to %q: %s", input, blanked, err)
+			}
+		}
+		kept := []token2.Token{}
+		for _, token := range tokens {
+			if token.Type != token2.ILLEGAL {
+				kept = append(kept, token)
+			}
+		}
+		if fmt.Sprint(again) != fmt.Sprint(kept) {
+			t.Fatalf("%q blanked to %q gives other tokens.\nwant=%v\ngot=%v", input, blanked, kept, again)
+		}
+	})
+}
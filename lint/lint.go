@@ -0,0 +1,204 @@
+/*
+	Find common mistakes in Monkey programs without running them.
+		- the program is parsed and resolved, so every identifier is known
+		  with the declaration it refers to
+		- each rule walks the program and reports what it finds, Config
+		  chooses the rules that run
+		- a comment "// lint:ignore rule, ..." silences the rules named on its
+		  own line when it ends the line, or on the next line when it stands
+		  alone; without names it silences every rule. "// lint:file-ignore"
+		  does the same for the whole file.
+*/
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"interpreter/resolver"
+	"sort"
+	"strings"
+)
+
+// Diagnostic is one mistake found in a file
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", d.File, d.Line, d.Column, d.Message, d.Rule)
+}
+
+// Rule is a check of the linter
+type Rule struct {
+	Name string
+	Doc  string
+	run  func(c *checker)
+}
+
+// Rules are all the rules, in the order they run
+var Rules = []Rule{
+	{"unused-let", "a let binding inside a function that is never used", checkUnusedLet},
+	{"shadowed-builtin", "a name declared that hides a builtin function", checkShadowedBuiltin},
+	{"unreachable", "a statement after a return in the same block", checkUnreachable},
+	{"not-callable", "a call of a literal that is not a function", checkNotCallable},
+	{"arg-count", "a call with the wrong number of arguments for a known function or builtin", checkArgCount},
+	{"duplicate-key", "a key given twice in a hash literal", checkDuplicateKey},
+	{"constant-condition", "an if condition made only of literals", checkConstantCondition},
+}
+
+// Config chooses the rules that run: the rules of Enable, every rule when
+// Enable is empty, minus the rules of Disable
+type Config struct {
+	Enable  []string
+	Disable []string
+}
+
+// Validate reports a rule named in c that does not exist
+func (c Config) Validate() error {
+	for _, name := range append(append([]string{}, c.Enable...), c.Disable...) {
+		if lookupRule(name) == nil {
+			return fmt.Errorf("unknown rule: %s", name)
+		}
+	}
+	return nil
+}
+
+func (c Config) enabled(name string) bool {
+	for _, disabled := range c.Disable {
+		if disabled == name {
+			return false
+		}
+	}
+	if len(c.Enable) == 0 {
+		return true
+	}
+	for _, enabled := range c.Enable {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupRule(name string) *Rule {
+	for i := range Rules {
+		if Rules[i].Name == name {
+			return &Rules[i]
+		}
+	}
+	return nil
+}
+
+// Source lints src, read from file, and returns what the rules of config
+// found in source order. Parser and resolver errors are returned as an
+// error, no rule runs then.
+func Source(file string, src string, config Config) ([]Diagnostic, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+	r := resolver.New(evaluator.NewGlobalScope())
+	r.Resolve(program)
+	if len(r.Errors()) > 0 {
+		return nil, errors.New(strings.Join(r.Errors(), "\n"))
+	}
+
+	c := newChecker(program, r.Definitions())
+	for _, rule := range Rules {
+		if config.enabled(rule.Name) {
+			c.rule = rule.Name
+			rule.run(c)
+		}
+	}
+	ignores := newIgnores(l, strings.Split(src, "\n"))
+	diagnostics := []Diagnostic{}
+	for _, d := range c.diagnostics {
+		if !ignores.ignored(d) {
+			d.File = file
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diagnostics, nil
+}
+
+// ignores are the rules silenced by lint comments, by line. An empty set
+// silences every rule.
+type ignores struct {
+	lines map[int]map[string]bool
+	file  map[string]bool // nil when the file is not ignored
+}
+
+func newIgnores(l *lexer.Lexer, lines []string) *ignores {
+	ig := &ignores{lines: make(map[int]map[string]bool)}
+	for _, comment := range l.Comments() {
+		text := strings.TrimSpace(strings.TrimPrefix(comment.Literal, "//"))
+		var directive string
+		switch {
+		case strings.HasPrefix(text, "lint:ignore"):
+			directive = "lint:ignore"
+		case strings.HasPrefix(text, "lint:file-ignore"):
+			directive = "lint:file-ignore"
+		default:
+			continue
+		}
+		rules := make(map[string]bool)
+		for _, name := range strings.Split(strings.TrimPrefix(text, directive), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				rules[name] = true
+			}
+		}
+		if directive == "lint:file-ignore" {
+			ig.file = merge(ig.file, rules)
+			continue
+		}
+		line := comment.Line
+		// a comment alone on its line is about the next one
+		if strings.TrimSpace(lines[line-1][:comment.Column-1]) == "" {
+			line++
+		}
+		ig.lines[line] = merge(ig.lines[line], rules)
+	}
+	return ig
+}
+
+// merge adds the rules of b to a, where an empty set stands for all rules
+func merge(a, b map[string]bool) map[string]bool {
+	if a == nil {
+		return b
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return map[string]bool{}
+	}
+	for name := range b {
+		a[name] = true
+	}
+	return a
+}
+
+func (ig *ignores) ignored(d Diagnostic) bool {
+	for _, rules := range []map[string]bool{ig.file, ig.lines[d.Line]} {
+		if rules != nil && (len(rules) == 0 || rules[d.Rule]) {
+			return true
+		}
+	}
+	return false
+}
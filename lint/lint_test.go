@@ -0,0 +1,102 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+)
+
+func lint(t *testing.T, src string, config Config) []string {
+	diagnostics, err := Source("a.mk", src, config)
+	if err != nil {
+		t.Fatalf("%q: %s", src, err)
+	}
+	got := []string{}
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+	return got
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule string
+		src  string
+		want []string
+	}{
+		{"unused-let", "let g = 1; fn f(a) { let x = 1; let [y, _z] = [a, 2]; let used = 3; used }",
+			[]string{"a.mk:1:26: x declared and not used (unused-let)", "a.mk:1:38: y declared and not used (unused-let)"}},
+		{"unused-let", "fn f() { let g = fn() { g() }; let h = 1; fn() { h } }", []string{}},
+		{"shadowed-builtin", "let len = 1; fn f(first) { first } match (1) { puts => puts }",
+			[]string{
+				"a.mk:1:5: len hides the builtin function len (shadowed-builtin)",
+				"a.mk:1:19: first hides the builtin function first (shadowed-builtin)",
+				"a.mk:1:48: puts hides the builtin function puts (shadowed-builtin)",
+			}},
+		{"unreachable", "fn f() { return 1; puts(2); puts(3) }\nlet g = fn() { if (true) { return 1; 2 } };",
+			[]string{"a.mk:1:20: unreachable code after return (unreachable)", "a.mk:2:38: unreachable code after return (unreachable)"}},
+		// an index may give a function
+		{"not-callable", `1(); "s"(2); [1][0](); {"a": 1}(); fn() {}();`,
+			[]string{"a.mk:1:1: INTEGER literal is not a function (not-callable)", "a.mk:1:6: STRING literal is not a function (not-callable)", "a.mk:1:24: HASH literal is not a function (not-callable)"}},
+		{"arg-count", "fn f(a, b = 2) { a } let g = fn(a, ...rest) { a };\nf(); f(1); f(1, 2, 3); f(b: 1); g(); g(1, 2, 3); f(...[1, 2, 3]);",
+			[]string{"a.mk:2:2: f takes at least 1 argument, got 0 (arg-count)", "a.mk:2:13: f takes at most 2 arguments, got 3 (arg-count)", "a.mk:2:34: g takes at least 1 argument, got 0 (arg-count)"}},
+		{"arg-count", "fn f(a, b) { a } let g = fn(a) { a };\nf(1); f(1, 2); f(1, 2, 3); g(); g(1, 2);",
+			[]string{
+				"a.mk:2:2: f takes 2 arguments, got 1 (arg-count)",
+				"a.mk:2:17: f takes 2 arguments, got 3 (arg-count)",
+				"a.mk:2:29: g takes 1 argument, got 0 (arg-count)",
+				"a.mk:2:34: g takes 1 argument, got 2 (arg-count)",
+			}},
+		{"arg-count", "len(); len(1, 2); puts(); assert_eq(1); push([], 1); fn(x) { x }(1, 2);",
+			[]string{
+				"a.mk:1:4: len takes 1 argument, got 0 (arg-count)",
+				"a.mk:1:11: len takes 1 argument, got 2 (arg-count)",
+				"a.mk:1:36: assert_eq takes at least 2 arguments, got 1 (arg-count)",
+				"a.mk:1:65: function takes 1 argument, got 2 (arg-count)",
+			}},
+		// a reassigned binding may hold any function
+		{"arg-count", "let f = fn(a) { a }; f = fn() { 1 }; f();", []string{}},
+		{"duplicate-key", `{"a": 1, "b": 2, "a": 3, 1: 1, 1: 2, true: 1, "1": 1}`,
+			[]string{`a.mk:1:18: duplicate key "a" in hash literal (duplicate-key)`, "a.mk:1:32: duplicate key 1 in hash literal (duplicate-key)"}},
+		{"constant-condition", "let x = 1; if (true) { 1 }; if (1 < 2) { 1 }; if (x < 2) { 1 }; if (!false) { 1 }",
+			[]string{"a.mk:1:12: if condition true is constant (constant-condition)", "a.mk:1:29: if condition (1 < 2) is constant (constant-condition)", "a.mk:1:65: if condition (!false) is constant (constant-condition)"}},
+	}
+	for _, tt := range tests {
+		got := lint(t, tt.src, Config{Enable: []string{tt.rule}})
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s %q wrong.\ngot=\n%s\nwant=\n%s", tt.rule, tt.src, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	src := "let len = 1; if (true) { 1 }"
+	if got := lint(t, src, Config{}); len(got) != 2 {
+		t.Errorf("all rules wrong. got=%v", got)
+	}
+	if got := lint(t, src, Config{Disable: []string{"shadowed-builtin"}}); len(got) != 1 || !strings.HasSuffix(got[0], "(constant-condition)") {
+		t.Errorf("disabled rule wrong. got=%v", got)
+	}
+	if _, err := Source("a.mk", src, Config{Enable: []string{"nope"}}); err == nil || err.Error() != "unknown rule: nope" {
+		t.Errorf("unknown rule error wrong. got=%v", err)
+	}
+	if _, err := Source("a.mk", "x", Config{}); err == nil || err.Error() != "identifier not found: x" {
+		t.Errorf("resolver error wrong. got=%v", err)
+	}
+}
+
+func TestIgnoreComments(t *testing.T) {
+	src := `let len = 1; // lint:ignore shadowed-builtin
+// lint:ignore
+let first = if (true) { 1 };
+let last = 1; // lint:ignore constant-condition
+if (true) { 1 } // lint:ignore unused-let, constant-condition
+`
+	got := lint(t, src, Config{})
+	want := []string{"a.mk:4:5: last hides the builtin function last (shadowed-builtin)"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ignored wrong.\ngot=\n%s\nwant=\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if got := lint(t, "// lint:file-ignore shadowed-builtin\nlet len = 1; if (true) { 1 }", Config{}); len(got) != 1 {
+		t.Errorf("file ignore wrong. got=%v", got)
+	}
+}
@@ -0,0 +1,352 @@
+package lint
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/token2"
+	"strconv"
+)
+
+// checker holds what the rules share about one resolved program
+type checker struct {
+	program *ast.Program
+	// the declaration of every identifier, a declaration maps to itself
+	definitions map[*ast.Identifier]*ast.Identifier
+	// declarations that are referenced somewhere
+	used map[*ast.Identifier]bool
+	// declarations that are the target of an assignment
+	assigned map[*ast.Identifier]bool
+	// the function literal a declaration is bound to, when it is one
+	functions map[*ast.Identifier]*ast.FunctionLiteral
+	builtins  map[string]bool
+
+	rule        string // the rule running
+	diagnostics []Diagnostic
+}
+
+func newChecker(program *ast.Program, definitions map[*ast.Identifier]*ast.Identifier) *checker {
+	c := &checker{
+		program:     program,
+		definitions: definitions,
+		used:        make(map[*ast.Identifier]bool),
+		assigned:    make(map[*ast.Identifier]bool),
+		functions:   make(map[*ast.Identifier]*ast.FunctionLiteral),
+		builtins:    make(map[string]bool),
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		c.builtins[name] = true
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			if def, ok := definitions[node]; ok && def != node {
+				c.used[def] = true
+			}
+		case *ast.AssignExpression:
+			if target, ok := node.Target.(*ast.Identifier); ok {
+				c.assigned[definitions[target]] = true
+			}
+		case *ast.LetStatement:
+			if fn, ok := node.Value.(*ast.FunctionLiteral); ok && node.Name != nil {
+				c.functions[node.Name] = fn
+			}
+		case *ast.FunctionDeclaration:
+			c.functions[node.Name] = node.Function
+		}
+		return true
+	})
+	return c
+}
+
+func (c *checker) report(token token2.Token, format string, a ...interface{}) {
+	c.diagnostics = append(c.diagnostics, Diagnostic{
+		Line:    token.Line,
+		Column:  token.Column,
+		Rule:    c.rule,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// isDeclaration reports whether ident declares a name
+func (c *checker) isDeclaration(ident *ast.Identifier) bool {
+	return c.definitions[ident] == ident
+}
+
+// inFunctions calls f for every node inside a function body, with the
+// top-level statements left out
+func inFunctions(program *ast.Program, f func(ast.Node)) {
+	ast.Inspect(program, func(node ast.Node) bool {
+		fn, ok := node.(*ast.FunctionLiteral)
+		if !ok {
+			return true
+		}
+		ast.Inspect(fn.Body, func(node ast.Node) bool {
+			f(node)
+			return true
+		})
+		return false
+	})
+}
+
+// Globals may be used by the REPL or by tests, only the bindings of
+// functions can be known to be unused. A name starting with _ is unused on
+// purpose.
+func checkUnusedLet(c *checker) {
+	inFunctions(c.program, func(node ast.Node) {
+		let, ok := node.(*ast.LetStatement)
+		if !ok {
+			return
+		}
+		var target ast.Node = let.Name
+		if let.Pattern != nil {
+			target = let.Pattern
+		}
+		ast.Inspect(target, func(node ast.Node) bool {
+			ident, ok := node.(*ast.Identifier)
+			if ok && c.isDeclaration(ident) && !c.used[ident] && ident.Value[0] != '_' {
+				c.report(ident.Token, "%s declared and not used", ident.Value)
+			}
+			return true
+		})
+	})
+}
+
+func checkShadowedBuiltin(c *checker) {
+	ast.Inspect(c.program, func(node ast.Node) bool {
+		ident, ok := node.(*ast.Identifier)
+		if ok && c.isDeclaration(ident) && c.builtins[ident.Value] {
+			c.report(ident.Token, "%s hides the builtin function %s", ident.Value, ident.Value)
+		}
+		return true
+	})
+}
+
+func checkUnreachable(c *checker) {
+	check := func(statements []ast.Statement) {
+		for i, statement := range statements {
+			if _, ok := statement.(*ast.ReturnStatement); ok && i+1 < len(statements) {
+				c.report(statementToken(statements[i+1]), "unreachable code after return")
+				return
+			}
+		}
+	}
+	ast.Inspect(c.program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			check(node.Statements)
+		case *ast.BlockStatement:
+			check(node.Statements)
+		}
+		return true
+	})
+}
+
+func statementToken(statement ast.Statement) token2.Token {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		return statement.Token
+	case *ast.ReturnStatement:
+		return statement.Token
+	case *ast.ExpressionStatement:
+		return statement.Token
+	case *ast.BlockStatement:
+		return statement.Token
+	case *ast.FunctionDeclaration:
+		return statement.Token
+	case *ast.StructStatement:
+		return statement.Token
+	case *ast.ImplStatement:
+		return statement.Token
+	case *ast.EnumStatement:
+		return statement.Token
+	}
+	return token2.Token{}
+}
+
+// literalToken returns the token of a literal that is not a function, with
+// the kind of value it makes
+func literalToken(expression ast.Expression) (token2.Token, string, bool) {
+	switch expression := expression.(type) {
+	case *ast.IntegerLiteral:
+		return expression.Token, "INTEGER", true
+	case *ast.StringLiteral:
+		return expression.Token, "STRING", true
+	case *ast.InterpolatedString:
+		return expression.Token, "STRING", true
+	case *ast.Boolean:
+		return expression.Token, "BOOLEAN", true
+	case *ast.ArrayLiteral:
+		return expression.Token, "ARRAY", true
+	case *ast.HashLiteral:
+		return expression.Token, "HASH", true
+	}
+	return token2.Token{}, "", false
+}
+
+func checkNotCallable(c *checker) {
+	ast.Inspect(c.program, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpression); ok {
+			if token, kind, ok := literalToken(call.Function); ok {
+				c.report(token, "%s literal is not a function", kind)
+			}
+		}
+		return true
+	})
+}
+
+// the builtins and how many arguments they take, -1 for any number
+var builtinArity = map[string][2]int{
+	"len":          {1, 1},
+	"first":        {1, 1},
+	"last":         {1, 1},
+	"rest":         {1, 1},
+	"push":         {2, 2},
+	"put":          {3, 3},
+	"delete":       {2, 2},
+	"type":         {1, 1},
+	"puts":         {0, -1},
+	"to_string":    {1, 1},
+	"test":         {2, 2},
+	"assert":       {1, 2},
+	"assert_eq":    {2, 3},
+	"assert_error": {1, 2},
+}
+
+func checkArgCount(c *checker) {
+	ast.Inspect(c.program, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		positional, keywords := 0, 0
+		for _, argument := range call.Arguments {
+			switch argument.(type) {
+			case *ast.SpreadExpression:
+				// unknown until it runs
+				return true
+			case *ast.KeywordArgument:
+				keywords++
+			default:
+				positional++
+			}
+		}
+		switch fn := call.Function.(type) {
+		case *ast.FunctionLiteral:
+			c.checkCall(call, fn, "function", positional, keywords)
+		case *ast.Identifier:
+			def, ok := c.definitions[fn]
+			if !ok && fn.Slot < 0 {
+				if arity, ok := builtinArity[fn.Value]; ok && keywords == 0 {
+					c.checkBuiltinCall(call, fn.Value, arity, positional)
+				}
+				return true
+			}
+			if literal, ok := c.functions[def]; ok && !c.assigned[def] {
+				c.checkCall(call, literal, fn.Value, positional, keywords)
+			}
+		}
+		return true
+	})
+}
+
+// checkCall checks a call of fn the way the evaluator binds its arguments
+func (c *checker) checkCall(call *ast.CallExpression, fn *ast.FunctionLiteral, name string, positional, keywords int) {
+	required := 0
+	for i := range fn.Parameters {
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			required++
+		}
+	}
+	fixed := required == len(fn.Parameters) && fn.Rest == nil
+	switch {
+	case fixed && positional > required, fixed && keywords == 0 && positional < required:
+		c.report(call.Token, "%s takes %s, got %d", name, arguments(required), positional)
+	case positional > len(fn.Parameters) && fn.Rest == nil:
+		c.report(call.Token, "%s takes at most %s, got %d", name, arguments(len(fn.Parameters)), positional)
+	case keywords == 0 && positional < required:
+		c.report(call.Token, "%s takes at least %s, got %d", name, arguments(required), positional)
+	}
+}
+
+func (c *checker) checkBuiltinCall(call *ast.CallExpression, name string, arity [2]int, got int) {
+	min, max := arity[0], arity[1]
+	switch {
+	case min == max && got != min:
+		c.report(call.Token, "%s takes %s, got %d", name, arguments(min), got)
+	case got < min:
+		c.report(call.Token, "%s takes at least %s, got %d", name, arguments(min), got)
+	case max >= 0 && got > max:
+		c.report(call.Token, "%s takes at most %s, got %d", name, arguments(max), got)
+	}
+}
+
+// arguments is n followed by argument or arguments
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// constantKey returns a key for a literal hash key, equal keys give equal
+// strings
+func constantKey(expression ast.Expression) (string, bool) {
+	switch expression := expression.(type) {
+	case *ast.IntegerLiteral:
+		return "int " + expression.String(), true
+	case *ast.StringLiteral:
+		return "string " + expression.Value, true
+	case *ast.Boolean:
+		return fmt.Sprintf("bool %t", expression.Value), true
+	}
+	return "", false
+}
+
+func checkDuplicateKey(c *checker) {
+	ast.Inspect(c.program, func(node ast.Node) bool {
+		hash, ok := node.(*ast.HashLiteral)
+		if !ok {
+			return true
+		}
+		seen := make(map[string]bool)
+		for _, key := range hash.Keys {
+			constant, ok := constantKey(key)
+			if !ok {
+				continue
+			}
+			if seen[constant] {
+				token, _, _ := literalToken(key)
+				display := key.String()
+				if str, ok := key.(*ast.StringLiteral); ok {
+					display = strconv.Quote(str.Value)
+				}
+				c.report(token, "duplicate key %s in hash literal", display)
+			}
+			seen[constant] = true
+		}
+		return true
+	})
+}
+
+// isConstant reports whether expression is made of literals only
+func isConstant(expression ast.Expression) bool {
+	switch expression := expression.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	case *ast.PrefixExpression:
+		return isConstant(expression.Right)
+	case *ast.InfixExpression:
+		return isConstant(expression.Left) && isConstant(expression.Right)
+	}
+	return false
+}
+
+func checkConstantCondition(c *checker) {
+	ast.Inspect(c.program, func(node ast.Node) bool {
+		if ie, ok := node.(*ast.IfExpression); ok && isConstant(ie.Condition) {
+			c.report(ie.Token, "if condition %s is constant", ie.Condition.String())
+		}
+		return true
+	})
+}
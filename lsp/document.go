@@ -0,0 +1,483 @@
+package lsp
+
+import (
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"interpreter/resolver"
+	"interpreter/token2"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// document is one open file and what is known about it. The analysis is
+// only there when the text parses, program is nil otherwise.
+type document struct {
+	uri     string
+	version int
+	text    string
+	lines   []string
+
+	diagnostics []Diagnostic
+	program     *ast.Program
+	// every identifier in source order
+	idents []*ast.Identifier
+	// the declaration of each resolved identifier, see resolver.Definitions
+	defs  map[*ast.Identifier]*ast.Identifier
+	decls []*declaration
+	info  map[*ast.Identifier]*declaration
+	// the last version that parsed, completion falls back to it while the
+	// text is being edited
+	previous *document
+}
+
+// declaration is a name bound by let, const, fn, struct, enum, a parameter
+// or a pattern, visible inside scope
+type declaration struct {
+	ident   *ast.Identifier
+	kind    int // CompletionItemKind
+	detail  string
+	scope   span
+	hoisted bool // visible in all of scope, not only after the declaration
+}
+
+// pos is a place in the text as the lexer counts it, Line and Column are one
+// based and Column counts bytes
+type pos struct {
+	line, col int
+}
+
+func (p pos) before(q pos) bool {
+	return p.line < q.line || p.line == q.line && p.col < q.col
+}
+
+type span struct {
+	from, to pos
+}
+
+func (s span) contains(p pos) bool {
+	return !p.before(s.from) && !s.to.before(p)
+}
+
+var wholeFile = span{from: pos{0, 0}, to: pos{math.MaxInt32, 0}}
+
+func startOf(token token2.Token) pos {
+	return pos{token.Line, token.Column}
+}
+
+func endOf(token token2.Token) pos {
+	length := len(token.Literal)
+	if token.Type == token2.STRING {
+		length += 2 // the quotes
+	}
+	return pos{token.Line, token.Column + length}
+}
+
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri, version: version, text: text, lines: strings.Split(text, "\n")}
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		for i, msg := range p.Errors() {
+			d.diagnose(p.ErrorTokens()[i], SeverityError, "parser", msg)
+		}
+		return d
+	}
+	r := resolver.New(evaluator.NewGlobalScope())
+	r.Resolve(program)
+	for i, msg := range r.Errors() {
+		d.diagnose(r.ErrorTokens()[i], SeverityError, "resolver", msg)
+	}
+	for i, msg := range r.Warnings() {
+		d.diagnose(r.WarningTokens()[i], SeverityWarning, "resolver", msg)
+	}
+	d.program = program
+	d.defs = r.Definitions()
+	d.index()
+	return d
+}
+
+func (d *document) diagnose(token token2.Token, severity int, source string, msg string) {
+	d.diagnostics = append(d.diagnostics, Diagnostic{
+		Range:    d.rangeOf(token),
+		Severity: severity,
+		Source:   source,
+		Message:  msg,
+	})
+}
+
+// index collects the identifiers and declarations of the program, with the
+// part of the program each declaration is visible in
+func (d *document) index() {
+	d.info = make(map[*ast.Identifier]*declaration)
+	// the blocks around the node being visited, nodes are visited in source
+	// order so a block is done once a node starts after its end
+	blocks := []span{wholeFile}
+	current := func(at pos) span {
+		for len(blocks) > 1 && blocks[len(blocks)-1].to.before(at) {
+			blocks = blocks[:len(blocks)-1]
+		}
+		return blocks[len(blocks)-1]
+	}
+	lets := make(map[*ast.Identifier]bool)
+	ast.Inspect(d.program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.BlockStatement:
+			if node.End.Line > 0 {
+				current(startOf(node.Token))
+				blocks = append(blocks, span{startOf(node.Token), endOf(node.End)})
+			}
+		case *ast.LetStatement:
+			scope := current(startOf(node.Token))
+			if node.Pattern != nil {
+				ast.Inspect(node.Pattern, func(node ast.Node) bool {
+					if ident, ok := node.(*ast.Identifier); ok {
+						lets[ident] = true
+					}
+					return true
+				})
+				break
+			}
+			keyword := node.Token.Literal
+			if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+				d.declare(node.Name, CompletionFunction, keyword+" "+node.Name.Value+" = "+signature("", fn), scope, false)
+			} else if node.Token.Type == token2.CONST {
+				d.declare(node.Name, CompletionConstant, keyword+" "+node.Name.Value, scope, false)
+			} else {
+				d.declare(node.Name, CompletionVariable, keyword+" "+node.Name.Value, scope, false)
+			}
+		case *ast.FunctionDeclaration:
+			scope := current(startOf(node.Token))
+			d.declare(node.Name, CompletionFunction, signature(node.Name.Value, node.Function), scope, true)
+		case *ast.FunctionLiteral:
+			if node.Body == nil {
+				break
+			}
+			body := span{startOf(node.Body.Token), endOf(node.Body.End)}
+			detail := "parameter of " + signature(node.Name, node)
+			for _, param := range node.Parameters {
+				d.declare(param, CompletionVariable, detail, body, true)
+			}
+			if node.Rest != nil {
+				d.declare(node.Rest, CompletionVariable, detail, body, true)
+			}
+		case *ast.StructStatement:
+			d.declare(node.Name, CompletionStruct, node.String(), current(startOf(node.Token)), false)
+		case *ast.EnumStatement:
+			scope := current(startOf(node.Token))
+			d.declare(node.Name, CompletionEnum, node.String(), scope, false)
+			for _, variant := range node.Variants {
+				d.declare(variant.Name, CompletionEnumMember, variant.String()+" of enum "+node.Name.Value, scope, false)
+			}
+		case *ast.Identifier:
+			d.idents = append(d.idents, node)
+			if lets[node] {
+				d.declare(node, CompletionVariable, "let "+node.Value, current(startOf(node.Token)), false)
+			} else {
+				d.declare(node, CompletionVariable, node.Value+" (pattern binding)", current(startOf(node.Token)), false)
+			}
+		}
+		return true
+	})
+}
+
+// declare records ident when the resolver took it for a declaration, the
+// first description given wins
+func (d *document) declare(ident *ast.Identifier, kind int, detail string, scope span, hoisted bool) {
+	if ident == nil || d.defs[ident] != ident || d.info[ident] != nil {
+		return
+	}
+	decl := &declaration{ident: ident, kind: kind, detail: detail, scope: scope, hoisted: hoisted}
+	d.decls = append(d.decls, decl)
+	d.info[ident] = decl
+}
+
+// signature renders the head of a function, fn name(x: int, y = 1, ...rest) -> int
+func signature(name string, fn *ast.FunctionLiteral) string {
+	params := ast.ParameterList(fn.Parameters, fn.ParameterTypes, fn.Defaults, fn.Rest)
+	head := "fn"
+	if name != "" {
+		head += " " + name
+	}
+	head += "(" + strings.Join(params, ", ") + ")"
+	if fn.ReturnType != nil {
+		head += " -> " + fn.ReturnType.String()
+	}
+	return head
+}
+
+// identAt returns the identifier under p, or the one p is right behind
+func (d *document) identAt(p pos) *ast.Identifier {
+	var behind *ast.Identifier
+	for _, ident := range d.idents {
+		from, to := startOf(ident.Token), endOf(ident.Token)
+		if p.before(from) || to.before(p) {
+			continue
+		}
+		if p == to {
+			behind = ident
+			continue
+		}
+		return ident
+	}
+	return behind
+}
+
+// definition returns the declaration of the identifier under p
+func (d *document) definition(p pos) *ast.Identifier {
+	ident := d.identAt(p)
+	if ident == nil {
+		return nil
+	}
+	return d.defs[ident]
+}
+
+// references returns every identifier bound to the same declaration as the
+// one under p, in source order
+func (d *document) references(p pos, includeDeclaration bool) []*ast.Identifier {
+	decl := d.definition(p)
+	if decl == nil {
+		return nil
+	}
+	refs := []*ast.Identifier{}
+	for _, ident := range d.idents {
+		if d.defs[ident] != decl || ident == decl && !includeDeclaration {
+			continue
+		}
+		refs = append(refs, ident)
+	}
+	return refs
+}
+
+// hover describes the identifier under p
+func (d *document) hover(p pos) (string, *ast.Identifier) {
+	ident := d.identAt(p)
+	if ident == nil {
+		return "", nil
+	}
+	if decl, ok := d.defs[ident]; ok {
+		if info := d.info[decl]; info != nil {
+			return info.detail, ident
+		}
+		return ident.Value, ident
+	}
+	if isBuiltin(ident.Value) {
+		return "builtin " + ident.Value, ident
+	}
+	return "", nil
+}
+
+// completion lists the names visible at p, inner declarations hide outer
+// ones with the same name, builtins come last
+func (d *document) completion(p pos) []CompletionItem {
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	typing := d.identAt(p)
+	for i := len(d.decls) - 1; i >= 0; i-- {
+		decl := d.decls[i]
+		name := decl.ident.Value
+		if seen[name] || decl.ident == typing || !decl.scope.contains(p) {
+			continue
+		}
+		if !decl.hoisted && p.before(endOf(decl.ident.Token)) {
+			continue
+		}
+		seen[name] = true
+		items = append(items, CompletionItem{Label: name, Kind: decl.kind, Detail: decl.detail})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	for _, name := range evaluator.BuiltinNames() {
+		if !seen[name] {
+			items = append(items, CompletionItem{Label: name, Kind: CompletionFunction, Detail: "builtin"})
+		}
+	}
+	return items
+}
+
+func isBuiltin(name string) bool {
+	for _, builtin := range evaluator.BuiltinNames() {
+		if builtin == name {
+			return true
+		}
+	}
+	return false
+}
+
+// symbols outlines the top level statements of the program
+func (d *document) symbols() []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, statement := range d.program.Statements {
+		full := d.rangeOver(statement)
+		symbol := func(ident *ast.Identifier, kind int, detail string) DocumentSymbol {
+			return DocumentSymbol{
+				Name:           ident.Value,
+				Detail:         detail,
+				Kind:           kind,
+				Range:          full,
+				SelectionRange: d.rangeOf(ident.Token),
+			}
+		}
+		switch statement := statement.(type) {
+		case *ast.LetStatement:
+			if statement.Pattern != nil {
+				ast.Inspect(statement.Pattern, func(node ast.Node) bool {
+					if ident, ok := node.(*ast.Identifier); ok && d.defs[ident] == ident {
+						symbols = append(symbols, symbol(ident, SymbolVariable, d.detail(ident)))
+					}
+					return true
+				})
+				break
+			}
+			kind := SymbolVariable
+			if _, ok := statement.Value.(*ast.FunctionLiteral); ok {
+				kind = SymbolFunction
+			} else if statement.Token.Type == token2.CONST {
+				kind = SymbolConstant
+			}
+			symbols = append(symbols, symbol(statement.Name, kind, d.detail(statement.Name)))
+		case *ast.FunctionDeclaration:
+			symbols = append(symbols, symbol(statement.Name, SymbolFunction, d.detail(statement.Name)))
+		case *ast.StructStatement:
+			s := symbol(statement.Name, SymbolStruct, "")
+			for _, field := range statement.Fields {
+				s.Children = append(s.Children, DocumentSymbol{
+					Name:           field.Value,
+					Kind:           SymbolField,
+					Range:          d.rangeOf(field.Token),
+					SelectionRange: d.rangeOf(field.Token),
+				})
+			}
+			symbols = append(symbols, s)
+		case *ast.EnumStatement:
+			s := symbol(statement.Name, SymbolEnum, "")
+			for _, variant := range statement.Variants {
+				s.Children = append(s.Children, DocumentSymbol{
+					Name:           variant.Name.Value,
+					Detail:         variant.String(),
+					Kind:           SymbolEnumMember,
+					Range:          d.rangeOf(variant.Name.Token),
+					SelectionRange: d.rangeOf(variant.Name.Token),
+				})
+			}
+			symbols = append(symbols, s)
+		case *ast.ImplStatement:
+			s := symbol(statement.Name, SymbolClass, "impl")
+			s.Name = "impl " + statement.Name.Value
+			for _, method := range statement.Methods {
+				s.Children = append(s.Children, DocumentSymbol{
+					Name:           method.Name.Value,
+					Detail:         signature(method.Name.Value, method.Function),
+					Kind:           SymbolMethod,
+					Range:          d.rangeOver(method),
+					SelectionRange: d.rangeOf(method.Name.Token),
+				})
+			}
+			symbols = append(symbols, s)
+		}
+	}
+	return symbols
+}
+
+func (d *document) detail(ident *ast.Identifier) string {
+	if info := d.info[ident]; info != nil {
+		return info.detail
+	}
+	return ""
+}
+
+// position converts a lexer position to a protocol one
+func (d *document) position(p pos) Position {
+	line := p.line - 1
+	if line < 0 {
+		return Position{}
+	}
+	if line >= len(d.lines) {
+		last := len(d.lines) - 1
+		return Position{Line: last, Character: utf16Len(d.lines[last])}
+	}
+	text := d.lines[line]
+	col := p.col - 1
+	if col > len(text) {
+		col = len(text)
+	}
+	if col < 0 {
+		col = 0
+	}
+	return Position{Line: line, Character: utf16Len(text[:col])}
+}
+
+// pos converts a protocol position to a lexer one
+func (d *document) pos(p Position) pos {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return pos{p.Line + 1, 1}
+	}
+	text := d.lines[p.Line]
+	units := 0
+	col := 0
+	for col < len(text) && units < p.Character {
+		r, size := utf8.DecodeRuneInString(text[col:])
+		units += len(utf16.Encode([]rune{r}))
+		col += size
+	}
+	return pos{p.Line + 1, col + 1}
+}
+
+func (d *document) rangeOf(token token2.Token) Range {
+	return Range{Start: d.position(startOf(token)), End: d.position(endOf(token))}
+}
+
+// rangeOver spans node from its first token to the end of the last token
+// found in it, a closing bracket or parenthesis may be left out
+func (d *document) rangeOver(node ast.Node) Range {
+	from, to := pos{math.MaxInt32, 0}, pos{0, 0}
+	ast.Inspect(node, func(node ast.Node) bool {
+		v := reflect.ValueOf(node)
+		if v.Kind() != reflect.Ptr || v.IsNil() {
+			return true
+		}
+		tokens := []token2.Token{}
+		if field := v.Elem().FieldByName("Token"); field.IsValid() {
+			if token, ok := field.Interface().(token2.Token); ok {
+				tokens = append(tokens, token)
+			}
+		}
+		if block, ok := node.(*ast.BlockStatement); ok {
+			tokens = append(tokens, block.End)
+		}
+		for _, token := range tokens {
+			if token.Line == 0 {
+				continue
+			}
+			if startOf(token).before(from) {
+				from = startOf(token)
+			}
+			if to.before(endOf(token)) {
+				to = endOf(token)
+			}
+		}
+		return true
+	})
+	if to.line == 0 {
+		return Range{}
+	}
+	return Range{Start: d.position(from), End: d.position(to)}
+}
+
+// end is the position just past the last character of the text
+func (d *document) end() Position {
+	last := len(d.lines) - 1
+	return Position{Line: last, Character: utf16Len(d.lines[last])}
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return n
+}
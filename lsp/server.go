@@ -0,0 +1,311 @@
+/*
+	Language server for Monkey, spoken over JSON-RPC on a pair of streams.
+		- diagnostics from the parser, or from the resolver once the text
+		  parses, published whenever a document is opened or changed
+		- go to definition and find references of every bound name
+		- hover with the signature of functions
+		- completion of the names in scope and of the builtins
+		- document symbols and whole document formatting
+	Documents are synced in full on every change.
+*/
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"interpreter/format"
+	"io"
+)
+
+// ErrExitWithoutShutdown is returned by Run when the client asks the server
+// to exit before shutting it down
+var ErrExitWithoutShutdown = errors.New("exit without shutdown")
+
+type Server struct {
+	conn        *conn
+	docs        map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{conn: newConn(in, out), docs: make(map[string]*document)}
+}
+
+// handler answers a request, or handles a notification when the result is
+// ignored
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":                  (*Server).initialize,
+	"initialized":                 nothing,
+	"shutdown":                    (*Server).shutdownRequest,
+	"textDocument/didOpen":        (*Server).didOpen,
+	"textDocument/didChange":      (*Server).didChange,
+	"textDocument/didClose":       (*Server).didClose,
+	"textDocument/didSave":        nothing,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/references":     (*Server).references,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/completion":     (*Server).completion,
+	"textDocument/documentSymbol": (*Server).documentSymbol,
+	"textDocument/formatting":     (*Server).formatting,
+}
+
+func nothing(s *Server, params json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+// Run serves messages until the client sends exit or closes the input
+func (s *Server) Run() error {
+	for {
+		msg, err := s.conn.read()
+		if rpcErr, ok := err.(*responseError); ok {
+			if err := s.conn.write(&errorResponse{JSONRPC: "2.0", Error: rpcErr}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+		if msg.Method == "" {
+			// a response, the server sends no requests
+			continue
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) error {
+	result, err := s.dispatch(msg)
+	if msg.ID == nil {
+		// notifications are never answered, not even with an error
+		return nil
+	}
+	if err != nil {
+		rpcErr, ok := err.(*responseError)
+		if !ok {
+			rpcErr = &responseError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return s.conn.write(&errorResponse{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr})
+	}
+	return s.conn.write(&response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+}
+
+func (s *Server) dispatch(msg *message) (interface{}, error) {
+	h, ok := handlers[msg.Method]
+	switch {
+	case !ok:
+		return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	case s.shutdown:
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shut down"}
+	case !s.initialized && msg.Method != "initialize":
+		return nil, &responseError{Code: codeNotInitialized, Message: "server not initialized"}
+	}
+	return h(s, msg.Params)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return s.conn.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	s.initialized = true
+	return &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:           syncFull,
+			DefinitionProvider:         true,
+			ReferencesProvider:         true,
+			HoverProvider:              true,
+			CompletionProvider:         &CompletionOptions{},
+			DocumentSymbolProvider:     true,
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: ServerInfo{Name: "monkey"},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(params json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p DidOpenTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	return nil, s.open(newDocument(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text))
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, error) {
+	var p DidChangeTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	text := p.ContentChanges[len(p.ContentChanges)-1].Text
+	return nil, s.open(newDocument(p.TextDocument.URI, p.TextDocument.Version, text))
+}
+
+// open replaces the document and publishes its diagnostics
+func (s *Server) open(d *document) error {
+	if old := s.docs[d.uri]; d.program == nil && old != nil {
+		if old.program != nil {
+			d.previous = old
+		} else {
+			d.previous = old.previous
+		}
+	}
+	s.docs[d.uri] = d
+	diagnostics := d.diagnostics
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	return s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         d.uri,
+		Version:     d.version,
+		Diagnostics: diagnostics,
+	})
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, error) {
+	var p DidCloseTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	return nil, s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// document returns the analysed document at uri, nil while it does not parse
+func (s *Server) document(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, errors.New("unknown document: " + uri)
+	}
+	if d.program == nil {
+		return nil, nil
+	}
+	return d, nil
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if d == nil {
+		return nil, err
+	}
+	decl := d.definition(d.pos(p.Position))
+	if decl == nil {
+		return nil, nil
+	}
+	return &Location{URI: d.uri, Range: d.rangeOf(decl.Token)}, nil
+}
+
+func (s *Server) references(params json.RawMessage) (interface{}, error) {
+	var p ReferenceParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if d == nil {
+		return nil, err
+	}
+	locations := []Location{}
+	for _, ref := range d.references(d.pos(p.Position), p.Context.IncludeDeclaration) {
+		locations = append(locations, Location{URI: d.uri, Range: d.rangeOf(ref.Token)})
+	}
+	return locations, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if d == nil {
+		return nil, err
+	}
+	text, ident := d.hover(d.pos(p.Position))
+	if ident == nil {
+		return nil, nil
+	}
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```monkey\n" + text + "\n```"},
+		Range:    d.rangeOf(ident.Token),
+	}, nil
+}
+
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, errors.New("unknown document: " + p.TextDocument.URI)
+	}
+	at := d.pos(p.Position)
+	if d.program == nil {
+		if d.previous == nil {
+			return (&document{}).completion(at), nil
+		}
+		d = d.previous
+	}
+	return d.completion(at), nil
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p DocumentSymbolParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if d == nil {
+		return nil, err
+	}
+	return d.symbols(), nil
+}
+
+// formatting replaces the whole document with its formatted text, a document
+// that does not parse is left alone
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	var p DocumentFormattingParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if d == nil {
+		return nil, err
+	}
+	out, err := format.Source(d.text)
+	if err != nil || out == d.text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{
+		Range:   Range{Start: Position{}, End: d.end()},
+		NewText: out,
+	}}, nil
+}
@@ -0,0 +1,477 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client drives a server over pipes the way an editor would
+type client struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *textproto.Reader
+	nextID int
+	done   chan error
+	// notifications received while waiting for responses
+	notifications []*message
+}
+
+func newClient(t *testing.T) *client {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	c := &client{
+		t:    t,
+		in:   clientOut,
+		out:  textproto.NewReader(bufio.NewReader(clientIn)),
+		done: make(chan error, 1),
+	}
+	go func() {
+		c.done <- NewServer(serverIn, serverOut).Run()
+		serverOut.Close()
+	}()
+	c.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, nil)
+	c.notify("initialized", map[string]interface{}{})
+	return c
+}
+
+func (c *client) send(v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (c *client) receive() *message {
+	header, err := c.out.ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatalf("reading header: %s", err)
+	}
+	length, _ := strconv.Atoi(header.Get("Content-Length"))
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.out.R, body); err != nil {
+		c.t.Fatalf("reading body: %s", err)
+	}
+	msg := &message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		c.t.Fatalf("invalid message %s: %s", body, err)
+	}
+	return msg
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// request sends a request and decodes the result of its response into result,
+// the response error is returned
+func (c *client) request(method string, params interface{}, result interface{}) *responseError {
+	c.nextID++
+	id := c.nextID
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	for {
+		msg := c.receive()
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(*msg.ID) != strconv.Itoa(id) {
+			c.t.Fatalf("response to request %d, want=%d", id, c.nextID)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("invalid result %s for %s: %s", msg.Result, method, err)
+			}
+		}
+		return nil
+	}
+}
+
+// diagnostics waits for the next diagnostics published for uri
+func (c *client) diagnostics(uri string) []Diagnostic {
+	for {
+		var msg *message
+		if len(c.notifications) > 0 {
+			msg, c.notifications = c.notifications[0], c.notifications[1:]
+		} else {
+			msg = c.receive()
+		}
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params PublishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.t.Fatal(err)
+		}
+		if params.URI == uri {
+			return params.Diagnostics
+		}
+	}
+}
+
+func (c *client) open(uri string, text string) []Diagnostic {
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": TextDocumentItem{URI: uri, LanguageID: "monkey", Version: 1, Text: text},
+	})
+	return c.diagnostics(uri)
+}
+
+func (c *client) change(uri string, version int, text string) []Diagnostic {
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   VersionedTextDocumentIdentifier{URI: uri, Version: version},
+		"contentChanges": []map[string]string{{"text": text}},
+	})
+	return c.diagnostics(uri)
+}
+
+func (c *client) close() error {
+	if err := c.request("shutdown", nil, nil); err != nil {
+		c.t.Fatalf("shutdown failed: %s", err)
+	}
+	c.notify("exit", nil)
+	select {
+	case err := <-c.done:
+		return err
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("server did not exit")
+	}
+	return nil
+}
+
+func at(uri string, line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+const uri = "file:///test.mk"
+
+const source = `let add = fn(x, y) { x + y };
+fn twice(f, v) {
+    let once = f(v);
+    f(once)
+}
+let total = twice(fn(n) { add(n, 1) }, 1);
+puts(total);
+`
+
+func TestInitialize(t *testing.T) {
+	c := newClient(t)
+	var result InitializeResult
+	if err := c.request("initialize", map[string]interface{}{}, &result); err != nil {
+		t.Fatalf("initialize failed: %s", err)
+	}
+	caps := result.Capabilities
+	if caps.TextDocumentSync != syncFull || !caps.DefinitionProvider || !caps.ReferencesProvider ||
+		!caps.HoverProvider || caps.CompletionProvider == nil || !caps.DocumentSymbolProvider ||
+		!caps.DocumentFormattingProvider {
+		t.Errorf("capabilities wrong. got=%+v", caps)
+	}
+	if err := c.close(); err != nil {
+		t.Errorf("server failed: %s", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	diagnostics := c.open(uri, "let x = 1;\nlet y = ;\n")
+	if len(diagnostics) == 0 {
+		t.Fatalf("no diagnostics for a parse error")
+	}
+	d := diagnostics[0]
+	if d.Severity != SeverityError || d.Source != "parser" || d.Range.Start.Line != 1 {
+		t.Errorf("parse error diagnostic wrong. got=%+v", d)
+	}
+
+	diagnostics = c.change(uri, 2, "let x = 1;\nputs(y);\n")
+	if len(diagnostics) != 1 {
+		t.Fatalf("wrong number of diagnostics. got=%+v", diagnostics)
+	}
+	want := Diagnostic{
+		Range:    Range{Start: Position{1, 5}, End: Position{1, 6}},
+		Severity: SeverityError,
+		Source:   "resolver",
+		Message:  "identifier not found: y",
+	}
+	if diagnostics[0] != want {
+		t.Errorf("resolver diagnostic wrong. got=%+v, want=%+v", diagnostics[0], want)
+	}
+
+	diagnostics = c.change(uri, 3, "enum R { A, B }\nmatch (A) { A => 1 }\n")
+	if len(diagnostics) != 1 || diagnostics[0].Severity != SeverityWarning ||
+		diagnostics[0].Message != "non-exhaustive match over R: missing B" {
+		t.Errorf("warning wrong. got=%+v", diagnostics)
+	}
+
+	if diagnostics := c.change(uri, 4, source); len(diagnostics) != 0 {
+		t.Errorf("diagnostics for valid source: %+v", diagnostics)
+	}
+
+	c.notify("textDocument/didClose", map[string]interface{}{"textDocument": TextDocumentIdentifier{URI: uri}})
+	if diagnostics := c.diagnostics(uri); len(diagnostics) != 0 {
+		t.Errorf("diagnostics not cleared on close: %+v", diagnostics)
+	}
+	c.close()
+}
+
+func TestDefinition(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, source)
+	tests := []struct {
+		line, character int
+		want            *Range
+	}{
+		// add in add(n, 1) goes to the let
+		{5, 27, &Range{Start: Position{0, 4}, End: Position{0, 7}}},
+		// x in x + y goes to the parameter
+		{0, 21, &Range{Start: Position{0, 13}, End: Position{0, 14}}},
+		// right behind once in f(once)
+		{3, 10, &Range{Start: Position{2, 8}, End: Position{2, 12}}},
+		// twice is hoisted
+		{5, 12, &Range{Start: Position{1, 3}, End: Position{1, 8}}},
+		// a declaration is its own definition
+		{2, 9, &Range{Start: Position{2, 8}, End: Position{2, 12}}},
+		// builtins and keywords have none
+		{6, 1, nil},
+		{0, 1, nil},
+	}
+	for _, tt := range tests {
+		var location *Location
+		if err := c.request("textDocument/definition", at(uri, tt.line, tt.character), &location); err != nil {
+			t.Fatalf("definition failed: %s", err)
+		}
+		switch {
+		case tt.want == nil && location != nil:
+			t.Errorf("definition at %d:%d should be null, got=%+v", tt.line, tt.character, location)
+		case tt.want != nil && location == nil:
+			t.Errorf("no definition at %d:%d", tt.line, tt.character)
+		case tt.want != nil && (location.URI != uri || location.Range != *tt.want):
+			t.Errorf("definition at %d:%d wrong. got=%+v, want=%+v", tt.line, tt.character, location.Range, *tt.want)
+		}
+	}
+	c.close()
+}
+
+func TestReferences(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, source)
+	params := ReferenceParams{TextDocumentPositionParams: at(uri, 1, 10)}
+	params.Context.IncludeDeclaration = true
+	var locations []Location
+	if err := c.request("textDocument/references", params, &locations); err != nil {
+		t.Fatalf("references failed: %s", err)
+	}
+	// f in fn twice(f, v) and its two uses
+	want := []Position{{1, 9}, {2, 15}, {3, 4}}
+	if len(locations) != len(want) {
+		t.Fatalf("wrong number of references. got=%+v", locations)
+	}
+	for i, location := range locations {
+		if location.Range.Start != want[i] {
+			t.Errorf("reference %d wrong. got=%+v, want=%+v", i, location.Range.Start, want[i])
+		}
+	}
+
+	params.Context.IncludeDeclaration = false
+	if err := c.request("textDocument/references", params, &locations); err != nil {
+		t.Fatalf("references failed: %s", err)
+	}
+	if len(locations) != 2 {
+		t.Errorf("declaration not left out. got=%+v", locations)
+	}
+	c.close()
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, source)
+	tests := []struct {
+		line, character int
+		want            string
+	}{
+		{5, 27, "let add = fn(x, y)"},
+		{5, 12, "fn twice(f, v)"},
+		{0, 21, "parameter of fn(x, y)"},
+		{2, 9, "let once"},
+		{6, 1, "builtin puts"},
+		{0, 1, ""},
+	}
+	for _, tt := range tests {
+		var hover *Hover
+		if err := c.request("textDocument/hover", at(uri, tt.line, tt.character), &hover); err != nil {
+			t.Fatalf("hover failed: %s", err)
+		}
+		if tt.want == "" {
+			if hover != nil {
+				t.Errorf("hover at %d:%d should be null, got=%+v", tt.line, tt.character, hover)
+			}
+			continue
+		}
+		if hover == nil {
+			t.Errorf("no hover at %d:%d", tt.line, tt.character)
+			continue
+		}
+		want := "```monkey\n" + tt.want + "\n```"
+		if hover.Contents.Value != want {
+			t.Errorf("hover at %d:%d wrong. got=%q, want=%q", tt.line, tt.character, hover.Contents.Value, want)
+		}
+	}
+	c.close()
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, source)
+	labels := func(line, character int) map[string]bool {
+		var items []CompletionItem
+		if err := c.request("textDocument/completion", at(uri, line, character), &items); err != nil {
+			t.Fatalf("completion failed: %s", err)
+		}
+		names := map[string]bool{}
+		for _, item := range items {
+			names[item.Label] = true
+		}
+		return names
+	}
+
+	// inside twice, after once
+	names := labels(3, 4)
+	for _, name := range []string{"add", "twice", "f", "v", "once", "len", "puts"} {
+		if !names[name] {
+			t.Errorf("%s not offered inside twice", name)
+		}
+	}
+	for _, name := range []string{"x", "n", "total"} {
+		if names[name] {
+			t.Errorf("%s offered inside twice", name)
+		}
+	}
+
+	// at the top level after total
+	names = labels(6, 0)
+	if !names["total"] || names["once"] || names["f"] {
+		t.Errorf("top level names wrong. got=%v", names)
+	}
+
+	// while the text does not parse the last version that did is used
+	c.change(uri, 2, source+"let broken = ")
+	names = labels(7, 13)
+	if !names["total"] || !names["add"] {
+		t.Errorf("completion lost while editing. got=%v", names)
+	}
+	c.close()
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, source+"const limit = 10;\nstruct Point { x, y }\nenum Shape { Dot, Line(a, b) }\nimpl Point { fn norm(self) { self.x } }\n")
+	var symbols []DocumentSymbol
+	params := DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}
+	if err := c.request("textDocument/documentSymbol", params, &symbols); err != nil {
+		t.Fatalf("documentSymbol failed: %s", err)
+	}
+	want := []struct {
+		name     string
+		kind     int
+		children []string
+	}{
+		{"add", SymbolFunction, nil},
+		{"twice", SymbolFunction, nil},
+		{"total", SymbolVariable, nil},
+		{"limit", SymbolConstant, nil},
+		{"Point", SymbolStruct, []string{"x", "y"}},
+		{"Shape", SymbolEnum, []string{"Dot", "Line"}},
+		{"impl Point", SymbolClass, []string{"norm"}},
+	}
+	if len(symbols) != len(want) {
+		t.Fatalf("wrong number of symbols. got=%+v", symbols)
+	}
+	for i, symbol := range symbols {
+		children := []string{}
+		for _, child := range symbol.Children {
+			children = append(children, child.Name)
+		}
+		if symbol.Name != want[i].name || symbol.Kind != want[i].kind ||
+			strings.Join(children, " ") != strings.Join(want[i].children, " ") {
+			t.Errorf("symbol %d wrong. got=%s %d %v", i, symbol.Name, symbol.Kind, children)
+		}
+	}
+	// twice spans its whole body
+	if r := symbols[1].Range; r.Start != (Position{1, 0}) || r.End != (Position{4, 1}) {
+		t.Errorf("range of twice wrong. got=%+v", r)
+	}
+	c.close()
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, "let x=1;\nif(x>0){puts(x)}\n")
+	var edits []TextEdit
+	params := DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}
+	if err := c.request("textDocument/formatting", params, &edits); err != nil {
+		t.Fatalf("formatting failed: %s", err)
+	}
+	if len(edits) != 1 {
+		t.Fatalf("wrong number of edits. got=%+v", edits)
+	}
+	want := TextEdit{
+		Range:   Range{Start: Position{0, 0}, End: Position{2, 0}},
+		NewText: "let x = 1;\nif (x > 0) {\n    puts(x)\n}\n",
+	}
+	if edits[0] != want {
+		t.Errorf("edit wrong. got=%+v, want=%+v", edits[0], want)
+	}
+
+	c.change(uri, 2, want.NewText)
+	if err := c.request("textDocument/formatting", params, &edits); err != nil {
+		t.Fatalf("formatting failed: %s", err)
+	}
+	if len(edits) != 0 {
+		t.Errorf("formatted text edited. got=%+v", edits)
+	}
+	c.close()
+}
+
+func TestProtocolErrors(t *testing.T) {
+	c := newClient(t)
+	err := c.request("textDocument/unknown", map[string]interface{}{}, nil)
+	if err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("unknown method answered with %+v", err)
+	}
+	err = c.request("textDocument/hover", at("file:///missing.mk", 0, 0), nil)
+	if err == nil || err.Code != codeInvalidParams {
+		t.Errorf("unknown document answered with %+v", err)
+	}
+
+	// an editor that goes away without shutting down
+	c.notify("exit", nil)
+	if err := <-c.done; err != ErrExitWithoutShutdown {
+		t.Errorf("exit without shutdown gave %v", err)
+	}
+}
+
+func TestUTF16Positions(t *testing.T) {
+	c := newClient(t)
+	// é is two bytes and one UTF-16 unit, 😀 four bytes and two units
+	c.open(uri, "let s = \"é😀\"; let v = s;\n")
+	var location *Location
+	if err := c.request("textDocument/definition", at(uri, 0, 23), &location); err != nil {
+		t.Fatalf("definition failed: %s", err)
+	}
+	want := Range{Start: Position{0, 4}, End: Position{0, 5}}
+	if location == nil || location.Range != want {
+		t.Errorf("definition wrong. got=%+v, want=%+v", location, want)
+	}
+	c.close()
+}
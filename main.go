@@ -7,7 +7,28 @@ import (
 	user2 "os/user"
 )
 
+// subcommands, `monkey <name> args...`. Without a subcommand the REPL starts.
+var commands = map[string]func(args []string) int{
+	"check":  cmdCheck,
+	"debug":  cmdDebug,
+	"fmt":    cmdFmt,
+	"lint":   cmdLint,
+	"lsp":    cmdLsp,
+	"parse":  cmdParse,
+	"run":    cmdRun,
+	"test":   cmdTest,
+	"tokens": cmdTokens,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		command, ok := commands[os.Args[1]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "monkey: unknown command %q\n", os.Args[1])
+			os.Exit(2)
+		}
+		os.Exit(command(os.Args[2:]))
+	}
 	user, err := user2.Current()
 	if err != nil {
 		panic(err)
@@ -0,0 +1,107 @@
+package object2
+
+import (
+	"bytes"
+	"hash/fnv"
+	"strings"
+)
+
+// EnumType is the value bound by `enum Result { Ok(value), Err(msg) }`
+type EnumType struct {
+	Name     string
+	Variants []*Variant
+}
+
+func (et *EnumType) Type() ObjectType {
+	return ENUM_TYPE_OBJ
+}
+
+func (et *EnumType) Inspect() string {
+	variants := []string{}
+	for _, variant := range et.Variants {
+		variants = append(variants, variant.signature())
+	}
+	return "enum " + et.Name + " { " + strings.Join(variants, ", ") + " }"
+}
+
+// Variant returns the variant called name, or nil
+func (et *EnumType) Variant(name string) *Variant {
+	for _, variant := range et.Variants {
+		if variant.Name == name {
+			return variant
+		}
+	}
+	return nil
+}
+
+// Variant is one variant of an enum. A variant with fields is bound to its
+// Variant and calling it builds an EnumValue, a variant without fields is
+// bound to its EnumValue directly.
+type Variant struct {
+	Enum   *EnumType
+	Name   string
+	Fields []string
+}
+
+func (v *Variant) Type() ObjectType {
+	return VARIANT_OBJ
+}
+
+func (v *Variant) Inspect() string {
+	return v.Enum.Name + "." + v.Name
+}
+
+func (v *Variant) signature() string {
+	if len(v.Fields) == 0 {
+		return v.Name
+	}
+	return v.Name + "(" + strings.Join(v.Fields, ", ") + ")"
+}
+
+// EnumValue is a value of an enum, its Values are the payload in the order
+// of the fields of its variant. Values are immutable and compared by
+// content, Ok(1) == Ok(1).
+type EnumValue struct {
+	Variant *Variant
+	Values  []Object
+}
+
+func (ev *EnumValue) Type() ObjectType {
+	return ENUM_OBJ
+}
+
+func (ev *EnumValue) Inspect() string {
+	if len(ev.Variant.Fields) == 0 {
+		return ev.Variant.Name
+	}
+	var out bytes.Buffer
+	values := []string{}
+	for _, value := range ev.Values {
+		values = append(values, value.Inspect())
+	}
+	out.WriteString(ev.Variant.Name)
+	out.WriteString("(")
+	out.WriteString(strings.Join(values, ", "))
+	out.WriteString(")")
+	return out.String()
+}
+
+// HashKey mixes the variant with the keys of the payload, a payload value
+// that is not hashable itself is hashed by its Inspect form
+func (ev *EnumValue) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(ev.Variant.Enum.Name + "." + ev.Variant.Name))
+	for _, value := range ev.Values {
+		h.Write([]byte{0})
+		if hashable, ok := value.(Hashable); ok {
+			key := hashable.HashKey()
+			h.Write([]byte(key.Type))
+			for i := 0; i < 8; i++ {
+				h.Write([]byte{byte(key.Value >> (8 * i))})
+			}
+		} else {
+			h.Write([]byte(value.Inspect()))
+		}
+	}
+	return HashKey{Type: ev.Type(), Value: h.Sum64()}
+}
@@ -0,0 +1,228 @@
+package object2
+
+import "math/bits"
+
+// HashMap is a persistent hash array mapped trie (HAMT) from HashKey to
+// HashPair, used to store the pairs of a Hash. Each level of the trie
+// consumes 5 bits of the key's hash, and a node only allocates slots for the
+// children that exist, tracked by a 32 bit bitmap. Set and Delete return a
+// new map that shares every untouched node with the old one, so an update
+// costs O(log32 n) instead of copying the whole hash.
+//
+// The zero value is an empty map ready to use.
+type HashMap struct {
+	root  *hamtNode
+	count int
+}
+
+const (
+	hamtBits = 5
+	hamtMask = 1<<hamtBits - 1
+)
+
+type hamtNode struct {
+	bitmap  uint32
+	entries []hamtEntry
+	// once all 64 hash bits are used up, colliding keys are kept in a list
+	collisions []*hamtLeaf
+}
+
+// an entry either holds a leaf or points to a sub trie. Leaves are shared
+// by pointer so copying a node on update stays cheap.
+type hamtEntry struct {
+	leaf *hamtLeaf
+	sub  *hamtNode
+}
+
+type hamtLeaf struct {
+	key  HashKey
+	pair HashPair
+}
+
+// Len returns the number of pairs in the map
+func (m HashMap) Len() int {
+	return m.count
+}
+
+// Get looks up the pair stored under key
+func (m HashMap) Get(key HashKey) (HashPair, bool) {
+	hash := hashOfKey(key)
+	node := m.root
+	for shift := uint(0); node != nil; shift += hamtBits {
+		if shift >= 64 {
+			for _, leaf := range node.collisions {
+				if leaf.key == key {
+					return leaf.pair, true
+				}
+			}
+			return HashPair{}, false
+		}
+		bit := uint32(1) << ((hash >> shift) & hamtMask)
+		if node.bitmap&bit == 0 {
+			return HashPair{}, false
+		}
+		e := node.entries[bits.OnesCount32(node.bitmap&(bit-1))]
+		if e.sub == nil {
+			if e.leaf.key == key {
+				return e.leaf.pair, true
+			}
+			return HashPair{}, false
+		}
+		node = e.sub
+	}
+	return HashPair{}, false
+}
+
+// Set returns a new map where key is bound to pair
+func (m HashMap) Set(key HashKey, pair HashPair) HashMap {
+	root := m.root
+	if root == nil {
+		root = &hamtNode{}
+	}
+	newRoot, added := root.set(key, hashOfKey(key), 0, pair)
+	m.root = newRoot
+	if added {
+		m.count++
+	}
+	return m
+}
+
+// Delete returns a new map without key
+func (m HashMap) Delete(key HashKey) HashMap {
+	if m.root == nil {
+		return m
+	}
+	newRoot, removed := m.root.delete(key, hashOfKey(key), 0)
+	if removed {
+		m.root = newRoot
+		m.count--
+	}
+	return m
+}
+
+// Each calls fn for every pair in the map, the order follows the hashes of
+// the keys and is stable for the same set of keys
+func (m HashMap) Each(fn func(key HashKey, pair HashPair)) {
+	if m.root != nil {
+		m.root.each(fn)
+	}
+}
+
+func (n *hamtNode) set(key HashKey, hash uint64, shift uint, pair HashPair) (*hamtNode, bool) {
+	if shift >= 64 {
+		node := &hamtNode{collisions: append([]*hamtLeaf(nil), n.collisions...)}
+		for i, leaf := range node.collisions {
+			if leaf.key == key {
+				node.collisions[i] = &hamtLeaf{key: key, pair: pair}
+				return node, false
+			}
+		}
+		node.collisions = append(node.collisions, &hamtLeaf{key: key, pair: pair})
+		return node, true
+	}
+
+	bit := uint32(1) << ((hash >> shift) & hamtMask)
+	idx := bits.OnesCount32(n.bitmap & (bit - 1))
+	if n.bitmap&bit == 0 {
+		node := &hamtNode{bitmap: n.bitmap | bit, entries: make([]hamtEntry, len(n.entries)+1)}
+		copy(node.entries, n.entries[:idx])
+		node.entries[idx] = hamtEntry{leaf: &hamtLeaf{key: key, pair: pair}}
+		copy(node.entries[idx+1:], n.entries[idx:])
+		return node, true
+	}
+
+	node := &hamtNode{bitmap: n.bitmap, entries: append([]hamtEntry(nil), n.entries...)}
+	e := n.entries[idx]
+	switch {
+	case e.sub != nil:
+		sub, added := e.sub.set(key, hash, shift+hamtBits, pair)
+		node.entries[idx] = hamtEntry{sub: sub}
+		return node, added
+	case e.leaf.key == key:
+		node.entries[idx] = hamtEntry{leaf: &hamtLeaf{key: key, pair: pair}}
+		return node, false
+	default:
+		// two keys share this slot, push both one level down
+		sub, _ := (&hamtNode{}).set(e.leaf.key, hashOfKey(e.leaf.key), shift+hamtBits, e.leaf.pair)
+		sub, _ = sub.set(key, hash, shift+hamtBits, pair)
+		node.entries[idx] = hamtEntry{sub: sub}
+		return node, true
+	}
+}
+
+// delete returns nil instead of an empty node so parents can drop the slot
+func (n *hamtNode) delete(key HashKey, hash uint64, shift uint) (*hamtNode, bool) {
+	if shift >= 64 {
+		for i, leaf := range n.collisions {
+			if leaf.key == key {
+				if len(n.collisions) == 1 {
+					return nil, true
+				}
+				node := &hamtNode{collisions: make([]*hamtLeaf, 0, len(n.collisions)-1)}
+				node.collisions = append(node.collisions, n.collisions[:i]...)
+				node.collisions = append(node.collisions, n.collisions[i+1:]...)
+				return node, true
+			}
+		}
+		return n, false
+	}
+
+	bit := uint32(1) << ((hash >> shift) & hamtMask)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	idx := bits.OnesCount32(n.bitmap & (bit - 1))
+	e := n.entries[idx]
+	if e.sub != nil {
+		sub, removed := e.sub.delete(key, hash, shift+hamtBits)
+		if !removed {
+			return n, false
+		}
+		if sub != nil {
+			node := &hamtNode{bitmap: n.bitmap, entries: append([]hamtEntry(nil), n.entries...)}
+			node.entries[idx] = hamtEntry{sub: sub}
+			return node, true
+		}
+	} else if e.leaf.key != key {
+		return n, false
+	}
+
+	if len(n.entries) == 1 {
+		return nil, true
+	}
+	node := &hamtNode{bitmap: n.bitmap &^ bit, entries: make([]hamtEntry, 0, len(n.entries)-1)}
+	node.entries = append(node.entries, n.entries[:idx]...)
+	node.entries = append(node.entries, n.entries[idx+1:]...)
+	return node, true
+}
+
+func (n *hamtNode) each(fn func(key HashKey, pair HashPair)) {
+	for _, e := range n.entries {
+		if e.sub != nil {
+			e.sub.each(fn)
+		} else {
+			fn(e.leaf.key, e.leaf.pair)
+		}
+	}
+	for _, leaf := range n.collisions {
+		fn(leaf.key, leaf.pair)
+	}
+}
+
+// mix the object type into the key's value and scramble the bits so that
+// sequential integers spread over the whole trie
+func hashOfKey(key HashKey) uint64 {
+	// inline FNV-1a of the type name, this runs on every lookup
+	x := uint64(14695981039346656037)
+	for i := 0; i < len(key.Type); i++ {
+		x ^= uint64(key.Type[i])
+		x *= 1099511628211
+	}
+	x ^= key.Value
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,100 @@
+package object2
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestHashMapSetGetDelete(t *testing.T) {
+	model := map[HashKey]HashPair{}
+	var m HashMap
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		key := &Integer{Value: int64(r.Intn(2000))}
+		hashKey := key.HashKey()
+		if r.Intn(4) == 0 {
+			delete(model, hashKey)
+			m = m.Delete(hashKey)
+		} else {
+			pair := HashPair{Key: key, Value: &Integer{Value: int64(i)}}
+			model[hashKey] = pair
+			m = m.Set(hashKey, pair)
+		}
+		if m.Len() != len(model) {
+			t.Fatalf("step %d: wrong length. want=%d, got=%d", i, len(model), m.Len())
+		}
+	}
+	for key, want := range model {
+		got, ok := m.Get(key)
+		if !ok || got != want {
+			t.Fatalf("m.Get(%v) wrong. got=%v (%t)", key, got, ok)
+		}
+	}
+	seen := 0
+	m.Each(func(key HashKey, pair HashPair) {
+		if model[key] != pair {
+			t.Errorf("Each visited unknown pair %v", key)
+		}
+		seen++
+	})
+	if seen != len(model) {
+		t.Errorf("Each visited %d pairs, want %d", seen, len(model))
+	}
+}
+
+func TestHashMapIsPersistent(t *testing.T) {
+	one := &String{Value: "one"}
+	var empty HashMap
+	m1 := empty.Set(one.HashKey(), HashPair{Key: one, Value: &Integer{Value: 1}})
+	m2 := m1.Set(one.HashKey(), HashPair{Key: one, Value: &Integer{Value: 2}})
+	m3 := m2.Delete(one.HashKey())
+
+	if empty.Len() != 0 || m1.Len() != 1 || m2.Len() != 1 || m3.Len() != 0 {
+		t.Fatalf("wrong lengths: %d %d %d %d", empty.Len(), m1.Len(), m2.Len(), m3.Len())
+	}
+	if pair, _ := m1.Get(one.HashKey()); pair.Value.Inspect() != "1" {
+		t.Errorf("m1 was modified. got=%s", pair.Value.Inspect())
+	}
+	if pair, _ := m2.Get(one.HashKey()); pair.Value.Inspect() != "2" {
+		t.Errorf("m2 has wrong value. got=%s", pair.Value.Inspect())
+	}
+	if _, ok := m3.Get(one.HashKey()); ok {
+		t.Errorf("m3 still contains the deleted key")
+	}
+}
+
+func TestHashMapKeysOfDifferentTypes(t *testing.T) {
+	var m HashMap
+	m = m.Set((&Integer{Value: 1}).HashKey(), HashPair{Key: &Integer{Value: 1}, Value: &String{Value: "int"}})
+	yes := &Boolean{Value: true}
+	m = m.Set(yes.HashKey(), HashPair{Key: yes, Value: &String{Value: "bool"}})
+	if m.Len() != 2 {
+		t.Fatalf("integer 1 and true must be different keys. got len=%d", m.Len())
+	}
+}
+
+func BenchmarkHashMapSet(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		var m HashMap
+		for j := 0; j < 10000; j++ {
+			key := &Integer{Value: int64(j)}
+			m = m.Set(key.HashKey(), HashPair{Key: key, Value: key})
+		}
+	}
+}
+
+// the copy-on-update strategy a Go map backed hash would need for value semantics
+func BenchmarkMapCopySet(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		m := map[HashKey]HashPair{}
+		for j := 0; j < 2000; j++ {
+			key := &Integer{Value: int64(j)}
+			next := make(map[HashKey]HashPair, len(m)+1)
+			for k, v := range m {
+				next[k] = v
+			}
+			next[key.HashKey()] = HashPair{Key: key, Value: key}
+			m = next
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package object2
+
+import (
+	"hash/fnv"
+	"math/big"
+)
+
+// BigInteger holds an integer that does not fit in int64. Integers are
+// promoted to BigInteger when arithmetic overflows and demoted back to
+// Integer as soon as they fit again, so both report INTEGER as their type and
+// any value has exactly one representation. Use IntegerFromBig to build one.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Type() ObjectType {
+	return INTEGER_OBJ
+}
+
+func (bi *BigInteger) Inspect() string {
+	return bi.Value.String()
+}
+
+// bigIntegerKey is the type of the hash keys of BigIntegers. The hash of one
+// could equal an int64, a key type of its own keeps it apart from Integer keys.
+const bigIntegerKey ObjectType = "BIG_INTEGER"
+
+// the hash depends only on the value
+func (bi *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	if bi.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(bi.Value.Bytes())
+	return HashKey{Type: bigIntegerKey, Value: h.Sum64()}
+}
+
+// IntegerFromBig returns v as an Integer when it fits in int64, otherwise as a
+// BigInteger. v must not be modified afterwards.
+func IntegerFromBig(v *big.Int) Object {
+	if v.IsInt64() {
+		return &Integer{Value: v.Int64()}
+	}
+	return &BigInteger{Value: v}
+}
+
+// BigValue returns a fresh big.Int holding the value of an Integer or a
+// BigInteger, the caller may modify it
+func BigValue(obj Object) *big.Int {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value)
+	case *BigInteger:
+		return new(big.Int).Set(obj.Value)
+	default:
+		return nil
+	}
+}
@@ -7,6 +7,7 @@
 //  Error:
 //  Null:
 //  Environment:
+//  Range:
 
 package object2
 
@@ -31,6 +32,13 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	RANGE_OBJ        = "RANGE"
+	STRUCT_OBJ       = "STRUCT"
+	STRUCT_TYPE_OBJ  = "STRUCT_TYPE"
+	METHOD_OBJ       = "METHOD"
+	ENUM_OBJ         = "ENUM"
+	ENUM_TYPE_OBJ    = "ENUM_TYPE"
+	VARIANT_OBJ      = "VARIANT"
 )
 
 type Object interface {
@@ -89,43 +97,104 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Error stops the evaluation. Stack lists the functions the error left on
+// its way out, innermost first. Functions left through a tail call are gone
+// from the stack and do not show up.
 type Error struct {
 	Message string
+	Stack   []string
 }
 
+// at most this many frames are shown by Inspect
+const maxInspectedFrames = 16
+
 func (e *Error) Type() ObjectType {
 	return ERROR_OBJ
 }
 func (e *Error) Inspect() string {
-	return "ERROR: " + e.Message
+	var out bytes.Buffer
+	out.WriteString("ERROR: " + e.Message)
+	for i, frame := range e.Stack {
+		if i == maxInspectedFrames {
+			out.WriteString(fmt.Sprintf("\n\t... %d more", len(e.Stack)-i))
+			break
+		}
+		out.WriteString("\n\tat " + frame)
+	}
+	return out.String()
 }
 
+// Environment stores the values of one function call (or of the globals) in
+// slots assigned by the resolver. names keeps the name bound to every slot
+// for debugging.
 type Environment struct {
-	store map[string]Object
+	store []Object
+	names []string
 	outer *Environment
+	// Context is kept by the evaluator for the whole evaluation, see
+	// evaluator.Context. Enclosed environments share the one of their outer
+	// environment.
+	Context interface{}
 }
 
 func NewEnvironment() *Environment {
-	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{outer: nil}
 }
 
-func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
-	// if current environment do not have this identifier then find it from upper environment
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+// Get walks depth environments out and reads slot there
+func (e *Environment) Get(depth int, slot int) (Object, bool) {
+	env := e
+	for ; depth > 0 && env != nil; depth-- {
+		env = env.outer
 	}
-	return obj, ok
+	if env == nil || slot < 0 || slot >= len(env.store) || env.store[slot] == nil {
+		return nil, false
+	}
+	return env.store[slot], true
 }
 
-func (e *Environment) Set(name string, val Object) Object {
-	e.store[name] = val
+// Set binds val to slot, the environment grows as needed so REPL lines can
+// keep adding globals
+func (e *Environment) Set(slot int, name string, val Object) Object {
+	for slot >= len(e.store) {
+		e.store = append(e.store, nil)
+		e.names = append(e.names, "")
+	}
+	e.store[slot] = val
+	e.names[slot] = name
 	return val
 }
 
+// Assign stores val in the slot that already holds a binding depth
+// environments out, it reports false when the binding is not initialized
+func (e *Environment) Assign(depth int, slot int, val Object) bool {
+	env := e
+	for ; depth > 0 && env != nil; depth-- {
+		env = env.outer
+	}
+	if env == nil || slot < 0 || slot >= len(env.store) || env.store[slot] == nil {
+		return false
+	}
+	env.store[slot] = val
+	return true
+}
+
+// Names returns the name bound to every slot, for debugging. A slot that
+// holds no value yet has an empty name.
+func (e *Environment) Names() []string {
+	return e.names
+}
+
+// Outer returns the enclosing environment, nil for the globals
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
 type Function struct {
+	Name       string // empty for an anonymous function
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // parallel to Parameters, nil when there is no default
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -134,11 +203,11 @@ func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	var params []string
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
+	params := ast.ParameterList(f.Parameters, nil, f.Defaults, f.Rest)
 	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
@@ -147,9 +216,15 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
-func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
+func NewEnclosedEnvironment(outer *Environment, size int) *Environment {
+	env := &Environment{
+		store: make([]Object, 0, size),
+		names: make([]string, 0, size),
+		outer: outer,
+	}
+	if outer != nil {
+		env.Context = outer.Context
+	}
 	return env
 }
 
@@ -164,8 +239,8 @@ func (s *String) Inspect() string {
 	return s.Value
 }
 
-// define Built in funciton
-type BuiltinFunction func(args ...Object) Object
+// define Built in funciton, env is the environment of the call
+type BuiltinFunction func(env *Environment, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltinFunction
@@ -178,8 +253,10 @@ func (b *Builtin) Inspect() string {
 	return "builtin function"
 }
 
+// Array elements are kept in a persistent vector, so push and rest share
+// structure with the original array instead of copying it
 type Array struct {
-	Elements []Object
+	Elements Vector
 }
 
 func (a *Array) Type() ObjectType {
@@ -189,8 +266,8 @@ func (a *Array) Type() ObjectType {
 func (e *Array) Inspect() string {
 	var out bytes.Buffer
 	list := []string{}
-	for _, i := range e.Elements {
-		list = append(list, i.Inspect())
+	for i := 0; i < e.Elements.Len(); i++ {
+		list = append(list, e.Elements.Get(i).Inspect())
 	}
 	out.WriteString("[")
 	out.WriteString(strings.Join(list, ", "))
@@ -233,8 +310,9 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash pairs are kept in a persistent HAMT, see HashMap
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs HashMap
 }
 
 func (h *Hash) Type() ObjectType {
@@ -245,11 +323,48 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	h.Pairs.Each(func(_ HashKey, pair HashPair) {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
-	}
+	})
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
 	out.WriteString("}")
 	return out.String()
 }
+
+// Range is a lazy sequence of integers from Start up to (but excluding) Stop,
+// moving Step at a time. Elements are computed on demand instead of stored.
+type Range struct {
+	Start int64
+	Stop  int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType {
+	return RANGE_OBJ
+}
+
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("%d..<%d", r.Start, r.Stop)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+
+// Len returns how many integers the range produces. The distance between
+// Start and Stop may not fit in int64, it is taken as a uint64.
+func (r *Range) Len() int64 {
+	switch {
+	case r.Step > 0 && r.Start < r.Stop:
+		return int64((uint64(r.Stop-r.Start)-1)/uint64(r.Step) + 1)
+	case r.Step < 0 && r.Start > r.Stop:
+		return int64((uint64(r.Start-r.Stop)-1)/uint64(-r.Step) + 1)
+	default:
+		return 0
+	}
+}
+
+// At returns the i-th integer of the range, i must be in [0, Len())
+func (r *Range) At(i int64) int64 {
+	return r.Start + i*r.Step
+}
@@ -1,6 +1,9 @@
 package object2
 
-import "testing"
+import (
+	"math/big"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello world"}
@@ -20,3 +23,33 @@ func TestStringHashKey(t *testing.T) {
 	}
 
 }
+
+func TestIntegerFromBig(t *testing.T) {
+	small := IntegerFromBig(big.NewInt(42))
+	if _, ok := small.(*Integer); !ok {
+		t.Errorf("IntegerFromBig(42) should be *Integer. got=%T", small)
+	}
+	huge, _ := new(big.Int).SetString("99999999999999999999", 10)
+	obj := IntegerFromBig(huge)
+	bi, ok := obj.(*BigInteger)
+	if !ok {
+		t.Fatalf("IntegerFromBig(huge) should be *BigInteger. got=%T", obj)
+	}
+	if bi.Type() != INTEGER_OBJ || bi.Inspect() != "99999999999999999999" {
+		t.Errorf("wrong BigInteger: %s %s", bi.Type(), bi.Inspect())
+	}
+
+	other, _ := new(big.Int).SetString("99999999999999999999", 10)
+	if bi.HashKey() != (&BigInteger{Value: other}).HashKey() {
+		t.Errorf("equal big integers have different hash keys")
+	}
+	negative := new(big.Int).Neg(other)
+	if bi.HashKey() == (&BigInteger{Value: negative}).HashKey() {
+		t.Errorf("x and -x have the same hash key")
+	}
+	// the fnv hash of 2^64 is 5952119183343170476
+	power, _ := new(big.Int).SetString("18446744073709551616", 10)
+	if (&BigInteger{Value: power}).HashKey() == (&Integer{Value: 5952119183343170476}).HashKey() {
+		t.Errorf("a big integer has the hash key of an integer")
+	}
+}
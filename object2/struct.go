@@ -0,0 +1,72 @@
+package object2
+
+import (
+	"bytes"
+	"strings"
+)
+
+// StructType is the value bound by `struct Point { x, y }`. Calling it builds
+// a StructInstance, methods are added by `impl Point { ... }`.
+type StructType struct {
+	Name    string
+	Fields  []string
+	Methods map[string]*Function
+}
+
+func (st *StructType) Type() ObjectType {
+	return STRUCT_TYPE_OBJ
+}
+
+func (st *StructType) Inspect() string {
+	return "struct " + st.Name + " { " + strings.Join(st.Fields, ", ") + " }"
+}
+
+// FieldIndex returns the position of field name, or -1
+func (st *StructType) FieldIndex(name string) int {
+	for i, field := range st.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// StructInstance holds the field values of one struct, in the order of the
+// fields of its type. Fields can be assigned, so instances are shared by
+// reference like in most languages.
+type StructInstance struct {
+	Struct *StructType
+	Fields []Object
+}
+
+func (si *StructInstance) Type() ObjectType {
+	return STRUCT_OBJ
+}
+
+func (si *StructInstance) Inspect() string {
+	var out bytes.Buffer
+	fields := []string{}
+	for i, name := range si.Struct.Fields {
+		fields = append(fields, name+": "+si.Fields[i].Inspect())
+	}
+	out.WriteString(si.Struct.Name)
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// Method is a method looked up on an instance, p.norm. Calling it passes
+// Receiver as the first argument, self.
+type Method struct {
+	Receiver *StructInstance
+	Function *Function
+}
+
+func (m *Method) Type() ObjectType {
+	return METHOD_OBJ
+}
+
+func (m *Method) Inspect() string {
+	return "method " + m.Receiver.Struct.Name + "." + m.Function.Name
+}
@@ -0,0 +1,170 @@
+package object2
+
+// Vector is a persistent (immutable) vector used to store array elements.
+// Elements live in a 32-way trie, and the last, partially filled, block of
+// up to 32 elements is kept in a separate tail so Push only copies the tail
+// in the common case. Every update returns a new Vector that shares all
+// untouched nodes with the old one, so Push and Rest are O(log32 n) instead
+// of copying the whole array.
+//
+// The zero value is an empty vector ready to use.
+type Vector struct {
+	count  int // number of elements stored, including the ones dropped by Rest
+	offset int // elements before offset were dropped by Rest
+	shift  uint
+	root   *vectorNode
+	tail   []Object
+}
+
+const (
+	vectorBits  = 5
+	vectorWidth = 1 << vectorBits
+	vectorMask  = vectorWidth - 1
+)
+
+// a trie node is either a branch (children) or a leaf (values)
+type vectorNode struct {
+	children []*vectorNode
+	values   []Object
+}
+
+// NewVector builds a vector holding a copy of elements
+func NewVector(elements []Object) Vector {
+	count := len(elements)
+	v := Vector{count: count, shift: vectorBits, root: &vectorNode{}}
+	tailOff := v.tailOffset()
+	v.tail = append([]Object(nil), elements[tailOff:]...)
+	if tailOff == 0 {
+		return v
+	}
+
+	// cut the elements into full leaves and build the trie bottom up
+	var nodes []*vectorNode
+	for i := 0; i < tailOff; i += vectorWidth {
+		values := make([]Object, vectorWidth)
+		copy(values, elements[i:i+vectorWidth])
+		nodes = append(nodes, &vectorNode{values: values})
+	}
+	for len(nodes) > vectorWidth {
+		var parents []*vectorNode
+		for i := 0; i < len(nodes); i += vectorWidth {
+			end := i + vectorWidth
+			if end > len(nodes) {
+				end = len(nodes)
+			}
+			parents = append(parents, &vectorNode{children: append([]*vectorNode(nil), nodes[i:end]...)})
+		}
+		nodes = parents
+		v.shift += vectorBits
+	}
+	v.root = &vectorNode{children: nodes}
+	return v
+}
+
+// Len returns the number of elements in the vector
+func (v Vector) Len() int {
+	return v.count - v.offset
+}
+
+// Get returns the i-th element, i must be in [0, Len())
+func (v Vector) Get(i int) Object {
+	i += v.offset
+	if i >= v.tailOffset() {
+		return v.tail[i-v.tailOffset()]
+	}
+	node := v.root
+	for level := v.shift; level > 0; level -= vectorBits {
+		node = node.children[(i>>level)&vectorMask]
+	}
+	return node.values[i&vectorMask]
+}
+
+// Push returns a new vector with obj appended
+func (v Vector) Push(obj Object) Vector {
+	if v.root == nil {
+		v.root = &vectorNode{}
+		v.shift = vectorBits
+	}
+	// room left in the tail, copy it so the old vector is untouched
+	if v.count-v.tailOffset() < vectorWidth {
+		tail := make([]Object, len(v.tail)+1, vectorWidth)
+		copy(tail, v.tail)
+		tail[len(v.tail)] = obj
+		v.tail = tail
+		v.count++
+		return v
+	}
+
+	// the tail is full: move it into the trie and start a new one
+	tailNode := &vectorNode{values: v.tail}
+	if (v.count >> vectorBits) > (1 << v.shift) {
+		// the root overflowed, add a level on top
+		v.root = &vectorNode{children: []*vectorNode{v.root, newVectorPath(v.shift, tailNode)}}
+		v.shift += vectorBits
+	} else {
+		v.root = v.pushTail(v.shift, v.root, tailNode)
+	}
+	v.tail = make([]Object, 1, vectorWidth)
+	v.tail[0] = obj
+	v.count++
+	return v
+}
+
+// Rest returns a new vector without the first element, it shares the
+// whole trie with v
+func (v Vector) Rest() Vector {
+	if v.Len() == 0 {
+		return v
+	}
+	v.offset++
+	if v.offset == v.count {
+		return Vector{}
+	}
+	return v
+}
+
+// Slice copies the elements into a new Go slice
+func (v Vector) Slice() []Object {
+	out := make([]Object, v.Len())
+	for i := range out {
+		out[i] = v.Get(i)
+	}
+	return out
+}
+
+// index of the first element stored in the tail
+func (v Vector) tailOffset() int {
+	if v.count < vectorWidth {
+		return 0
+	}
+	return ((v.count - 1) >> vectorBits) << vectorBits
+}
+
+func (v Vector) pushTail(level uint, parent *vectorNode, tailNode *vectorNode) *vectorNode {
+	subIdx := ((v.count - 1) >> level) & vectorMask
+	node := &vectorNode{children: make([]*vectorNode, len(parent.children), len(parent.children)+1)}
+	copy(node.children, parent.children)
+
+	var child *vectorNode
+	if level == vectorBits {
+		child = tailNode
+	} else if subIdx < len(parent.children) {
+		child = v.pushTail(level-vectorBits, parent.children[subIdx], tailNode)
+	} else {
+		child = newVectorPath(level-vectorBits, tailNode)
+	}
+	if subIdx < len(node.children) {
+		node.children[subIdx] = child
+	} else {
+		node.children = append(node.children, child)
+	}
+	return node
+}
+
+// wrap node in branches until it sits at the given level
+func newVectorPath(level uint, node *vectorNode) *vectorNode {
+	if level == 0 {
+		return node
+	}
+	return &vectorNode{children: []*vectorNode{newVectorPath(level-vectorBits, node)}}
+}
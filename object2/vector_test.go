@@ -0,0 +1,114 @@
+package object2
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestVectorPushAndGet(t *testing.T) {
+	var v Vector
+	versions := []Vector{v}
+	for i := 0; i < 5000; i++ {
+		v = v.Push(&Integer{Value: int64(i)})
+		versions = append(versions, v)
+	}
+	// every older version must still see exactly its own elements
+	for n, version := range versions {
+		if version.Len() != n {
+			t.Fatalf("version %d has wrong length. got=%d", n, version.Len())
+		}
+		if n > 0 && version.Get(n-1).(*Integer).Value != int64(n-1) {
+			t.Fatalf("version %d has wrong last element. got=%s", n, version.Get(n-1).Inspect())
+		}
+	}
+	for i := 0; i < v.Len(); i++ {
+		if v.Get(i).(*Integer).Value != int64(i) {
+			t.Fatalf("v.Get(%d) wrong. got=%s", i, v.Get(i).Inspect())
+		}
+	}
+}
+
+func TestNewVector(t *testing.T) {
+	for _, n := range []int{0, 1, 31, 32, 33, 64, 1024, 1056, 1057, 40000} {
+		elements := make([]Object, n)
+		for i := range elements {
+			elements[i] = &Integer{Value: int64(i)}
+		}
+		v := NewVector(elements)
+		if v.Len() != n {
+			t.Fatalf("NewVector(%d) has wrong length. got=%d", n, v.Len())
+		}
+		for i := 0; i < n; i++ {
+			if v.Get(i) != elements[i] {
+				t.Fatalf("NewVector(%d).Get(%d) wrong", n, i)
+			}
+		}
+		// pushing onto a bulk built vector keeps the trie layout valid
+		v = v.Push(&Integer{Value: int64(n)})
+		if v.Get(n).(*Integer).Value != int64(n) {
+			t.Fatalf("push after NewVector(%d) wrong", n)
+		}
+	}
+}
+
+func TestVectorRest(t *testing.T) {
+	model := []Object{}
+	var v Vector
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 3000; i++ {
+		if r.Intn(3) == 0 && len(model) > 0 {
+			model = model[1:]
+			v = v.Rest()
+		} else {
+			obj := &Integer{Value: int64(i)}
+			model = append(model, obj)
+			v = v.Push(obj)
+		}
+		if v.Len() != len(model) {
+			t.Fatalf("step %d: wrong length. want=%d, got=%d", i, len(model), v.Len())
+		}
+	}
+	for i, obj := range model {
+		if v.Get(i) != obj {
+			t.Fatalf("v.Get(%d) wrong", i)
+		}
+	}
+	if got := v.Slice(); len(got) != len(model) {
+		t.Fatalf("v.Slice() has wrong length. got=%d", len(got))
+	}
+}
+
+func BenchmarkVectorPush(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		var v Vector
+		for j := 0; j < 10000; j++ {
+			v = v.Push(&Integer{Value: int64(j)})
+		}
+	}
+}
+
+// the copy-on-push strategy arrays used before they were backed by Vector
+func BenchmarkSliceCopyPush(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		var elements []Object
+		for j := 0; j < 10000; j++ {
+			newElements := make([]Object, len(elements)+1)
+			copy(newElements, elements)
+			newElements[len(elements)] = &Integer{Value: int64(j)}
+			elements = newElements
+		}
+	}
+}
+
+func BenchmarkVectorRest(b *testing.B) {
+	elements := make([]Object, 10000)
+	for i := range elements {
+		elements[i] = &Integer{Value: int64(i)}
+	}
+	v := NewVector(elements)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for w := v; w.Len() > 0; w = w.Rest() {
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package optimize
+
+import (
+	"interpreter/ast"
+	"interpreter/token2"
+)
+
+// Branches drops the branches of an if whose condition is a literal that
+// are never taken. An if used as a value becomes the expression of its
+// branch when the branch is just that. A statement if becomes the
+// statements of its branch when the branch declares nothing, since a block
+// keeps its declarations to itself, else an if (true) of the branch. A
+// branch that declares a name is never dropped: the name stays bound in its
+// function, reading it gives an error when it runs rather than when the
+// program is resolved.
+func Branches(program *ast.Program) bool {
+	changed := false
+	rewrite(program, func(e ast.Expression) ast.Expression {
+		ie, ok := e.(*ast.IfExpression)
+		if !ok {
+			return e
+		}
+		branch, ok := taken(ie)
+		if !ok || branch == nil || len(branch.Statements) != 1 || dropsDeclarations(ie, branch) {
+			return e
+		}
+		if statement, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+			changed = true
+			return statement.Expression
+		}
+		return e
+	})
+	statementLists(program, func(statements []ast.Statement) []ast.Statement {
+		list := []ast.Statement{}
+		for i, statement := range statements {
+			last := i == len(statements)-1
+			replaced, ok := dropBranches(statement, last)
+			if !ok {
+				list = append(list, statement)
+				continue
+			}
+			changed = true
+			list = append(list, replaced...)
+		}
+		return list
+	})
+	return changed
+}
+
+// dropBranches returns the statements an if statement is replaced by, ok is
+// false when it stays. The last statement gives the value of its block, it
+// is replaced only by statements that give the same value.
+func dropBranches(statement ast.Statement, last bool) ([]ast.Statement, bool) {
+	es, ok := statement.(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+	ie, ok := es.Expression.(*ast.IfExpression)
+	if !ok {
+		return nil, false
+	}
+	branch, ok := taken(ie)
+	switch {
+	case !ok || dropsDeclarations(ie, branch):
+		return nil, false
+	case branch == nil:
+		// the value null is needed at the end
+		return nil, !last
+	case !declares(branch) && (!last || givesValue(branch)):
+		return branch.Statements, true
+	case ie.Alternative == nil:
+		if b, ok := ie.Condition.(*ast.Boolean); ok && b.Value {
+			return nil, false
+		}
+	}
+	es.Expression = &ast.IfExpression{
+		Token:       ie.Token,
+		Condition:   &ast.Boolean{Token: token2.Token{Type: token2.TRUE, Literal: "true", Line: ie.Token.Line, Column: ie.Token.Column}, Value: true},
+		Consequence: branch,
+	}
+	return []ast.Statement{es}, true
+}
+
+// taken returns the branch of ie that runs, nil for none, ok is false when
+// the condition is not a literal. Like the evaluator, only false is false.
+func taken(ie *ast.IfExpression) (*ast.BlockStatement, bool) {
+	switch condition := ie.Condition.(type) {
+	case *ast.Boolean:
+		if !condition.Value {
+			return ie.Alternative, true
+		}
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+	default:
+		return nil, false
+	}
+	return ie.Consequence, true
+}
+
+func declares(block *ast.BlockStatement) bool {
+	for _, statement := range block.Statements {
+		switch statement.(type) {
+		case *ast.LetStatement, *ast.FunctionDeclaration, *ast.StructStatement, *ast.EnumStatement, *ast.ImplStatement:
+			return true
+		}
+	}
+	return false
+}
+
+// dropsDeclarations reports whether a branch of ie other than the taken one
+// declares a name, in any block of it
+func dropsDeclarations(ie *ast.IfExpression, taken *ast.BlockStatement) bool {
+	found := false
+	for _, branch := range []*ast.BlockStatement{ie.Consequence, ie.Alternative} {
+		if branch == nil || branch == taken {
+			continue
+		}
+		ast.Inspect(branch, func(node ast.Node) bool {
+			switch node.(type) {
+			case *ast.FunctionLiteral:
+				// its names are its own
+				return false
+			case *ast.LetStatement, *ast.FunctionDeclaration, *ast.StructStatement, *ast.EnumStatement:
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// givesValue reports whether the value of block is that of its last
+// statement when it is spliced into another block
+func givesValue(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	switch block.Statements[len(block.Statements)-1].(type) {
+	case *ast.ExpressionStatement, *ast.ReturnStatement:
+		return true
+	}
+	return false
+}
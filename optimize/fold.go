@@ -0,0 +1,96 @@
+package optimize
+
+import (
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/object2"
+	"interpreter/token2"
+	"math/big"
+)
+
+// Fold replaces the prefix and infix expressions of integer, string and
+// boolean literals by the literal of their value, computed by the evaluator
+// so the result is the one the program would get. An expression that fails
+// is kept, and so is a power or a shift by more than 64 whose value may be
+// too big to compute for a branch that never runs.
+func Fold(program *ast.Program) bool {
+	changed := false
+	rewrite(program, func(e ast.Expression) ast.Expression {
+		if folded := fold(e); folded != nil {
+			changed = true
+			return folded
+		}
+		return e
+	})
+	return changed
+}
+
+func fold(e ast.Expression) ast.Expression {
+	var at token2.Token
+	switch e := e.(type) {
+	case *ast.PrefixExpression:
+		if !isLiteral(e.Right) {
+			return nil
+		}
+		at = e.Token
+	case *ast.InfixExpression:
+		if !isLiteral(e.Left) || !isLiteral(e.Right) {
+			return nil
+		}
+		if (e.Operator == "**" || e.Operator == "<<") && !isSmall(e.Right) {
+			return nil
+		}
+		at = literalToken(e.Left)
+	default:
+		return nil
+	}
+	return literal(evaluator.Eval(e, object2.NewEnvironment()), at)
+}
+
+// isLiteral reports whether e is an integer, string or boolean literal
+func isLiteral(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	}
+	return false
+}
+
+func isSmall(e ast.Expression) bool {
+	integer, ok := e.(*ast.IntegerLiteral)
+	return ok && integer.Big == nil && integer.Value <= 64
+}
+
+func literalToken(e ast.Expression) token2.Token {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return e.Token
+	case *ast.StringLiteral:
+		return e.Token
+	case *ast.Boolean:
+		return e.Token
+	}
+	return token2.Token{}
+}
+
+// literal returns the literal of value at the position of at, nil when the
+// value has none
+func literal(value object2.Object, at token2.Token) ast.Expression {
+	token := func(t token2.TokenType, literal string) token2.Token {
+		return token2.Token{Type: t, Literal: literal, Line: at.Line, Column: at.Column}
+	}
+	switch value := value.(type) {
+	case *object2.Integer:
+		return &ast.IntegerLiteral{Token: token(token2.INT, value.Inspect()), Value: value.Value}
+	case *object2.BigInteger:
+		return &ast.IntegerLiteral{Token: token(token2.INT, value.Inspect()), Big: new(big.Int).Set(value.Value)}
+	case *object2.String:
+		return &ast.StringLiteral{Token: token(token2.STRING, value.Value), Value: value.Value}
+	case *object2.Boolean:
+		if value.Value {
+			return &ast.Boolean{Token: token(token2.TRUE, "true"), Value: true}
+		}
+		return &ast.Boolean{Token: token(token2.FALSE, "false"), Value: false}
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package optimize
+
+import (
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/resolver"
+)
+
+// the most nodes the body of an inlined function has
+const maxInlineSize = 16
+
+// builtins that only look at their arguments, the others print or call back
+// into the program, which could see the arguments read in another order
+var pureBuiltins = map[string]bool{
+	"len": true, "first": true, "last": true, "rest": true,
+	"push": true, "put": true, "delete": true, "type": true,
+}
+
+// Inline replaces the calls of small functions by their body, with the
+// arguments in place of the parameters. A function is inlined when it is
+// bound once in its scope, by a let or a declaration directly in a program
+// or function body that is never assigned, has no default or rest
+// parameter, and its body is one expression of its parameters, literals,
+// operators, indexes and calls of builtins that cannot run the program, so
+// it cannot be recursive and the order its parameters are read in does not
+// matter. Only calls with a literal or a name bound before the call for each
+// parameter are inlined, a name whose parameter is never read is kept. A let
+// bound function is inlined after the let, outside of function declarations,
+// which may run before it.
+func Inline(program *ast.Program) bool {
+	r := resolver.New(evaluator.NewGlobalScope())
+	r.Resolve(program)
+	if len(r.Errors()) > 0 {
+		return false
+	}
+	in := newInliner(program, r.Definitions())
+	changed := false
+	rewrite(program, func(e ast.Expression) ast.Expression {
+		if inlined := in.call(e); inlined != nil {
+			changed = true
+			return inlined
+		}
+		return e
+	})
+	return changed
+}
+
+// candidate is a function that may be inlined
+type candidate struct {
+	fn   *ast.FunctionLiteral
+	body ast.Expression
+}
+
+// scoped is a name in the function, nil for the program, that binds it
+type scoped struct {
+	scope *ast.FunctionLiteral
+	name  string
+}
+
+type inliner struct {
+	// every reference to the declaration of a name declared more than once
+	// in a scope is mapped to the last one, the resolver gives them all the
+	// same slot, so only the names bound once are looked up here
+	definitions map[*ast.Identifier]*ast.Identifier
+	candidates  map[*ast.Identifier]*candidate
+	// the innermost function declaration around a node
+	enclosing map[ast.Node]*ast.FunctionDeclaration
+	// names declared somewhere, a builtin of that name may be hidden
+	declared map[string]bool
+	// the declarations of each name in each scope
+	bindings map[scoped]int
+	scopes   map[*ast.Identifier]scoped
+	// declarations directly in a program or function body, or parameters,
+	// with the let of the let bound ones
+	unconditional map[*ast.Identifier]bool
+	lets          map[*ast.Identifier]*ast.LetStatement
+}
+
+func newInliner(program *ast.Program, definitions map[*ast.Identifier]*ast.Identifier) *inliner {
+	in := &inliner{
+		definitions:   definitions,
+		candidates:    make(map[*ast.Identifier]*candidate),
+		enclosing:     make(map[ast.Node]*ast.FunctionDeclaration),
+		declared:      make(map[string]bool),
+		bindings:      make(map[scoped]int),
+		scopes:        make(map[*ast.Identifier]scoped),
+		unconditional: make(map[*ast.Identifier]bool),
+		lets:          make(map[*ast.Identifier]*ast.LetStatement),
+	}
+	in.scan(program, nil, nil)
+	assigned := make(map[*ast.Identifier]bool)
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node, ok := node.(*ast.AssignExpression); ok {
+			if target, ok := node.Target.(*ast.Identifier); ok {
+				assigned[definitions[target]] = true
+			}
+		}
+		return true
+	})
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			if fn, ok := node.Value.(*ast.FunctionLiteral); ok && node.Name != nil && !assigned[node.Name] {
+				in.add(node.Name, fn)
+			}
+		case *ast.FunctionDeclaration:
+			if !assigned[node.Name] {
+				in.add(node.Name, node.Function)
+			}
+		}
+		return true
+	})
+	return in
+}
+
+// scan records the declarations under root, which is in the function scope
+// and the function declaration decl
+func (in *inliner) scan(root ast.Node, scope *ast.FunctionLiteral, decl *ast.FunctionDeclaration) {
+	var body []ast.Statement
+	switch root := root.(type) {
+	case *ast.Program:
+		body = root.Statements
+	case *ast.FunctionLiteral:
+		body = root.Body.Statements
+		for _, param := range root.Parameters {
+			in.unconditional[param] = true
+		}
+		if root.Rest != nil {
+			in.unconditional[root.Rest] = true
+		}
+	}
+	for _, statement := range body {
+		switch statement := statement.(type) {
+		case *ast.LetStatement:
+			if statement.Name != nil {
+				in.unconditional[statement.Name] = true
+				in.lets[statement.Name] = statement
+			}
+		case *ast.FunctionDeclaration:
+			in.unconditional[statement.Name] = true
+		}
+	}
+	// the parameters of a function root are its own
+	ast.Inspect(root, func(node ast.Node) bool {
+		if node == root {
+			return true
+		}
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			in.scan(node, node, decl)
+			return false
+		case *ast.FunctionDeclaration:
+			in.bind(node.Name, scope)
+			in.scan(node.Function, node.Function, node)
+			return false
+		case *ast.Identifier:
+			in.bind(node, scope)
+		}
+		in.enclosing[node] = decl
+		return true
+	})
+}
+
+// bind counts ident when it declares a name
+func (in *inliner) bind(ident *ast.Identifier, scope *ast.FunctionLiteral) {
+	if in.definitions[ident] != ident || in.scopes[ident] != (scoped{}) {
+		return
+	}
+	in.declared[ident.Value] = true
+	key := scoped{scope, ident.Value}
+	in.scopes[ident] = key
+	in.bindings[key]++
+}
+
+// single reports whether decl is the only binding of its name in its scope
+// and is bound whenever its scope runs
+func (in *inliner) single(decl *ast.Identifier) bool {
+	key, ok := in.scopes[decl]
+	return ok && in.bindings[key] == 1 && in.unconditional[decl]
+}
+
+// bound reports whether ident refers to a binding that is set where call
+// runs, so reading it cannot fail
+func (in *inliner) bound(ident *ast.Identifier, call *ast.CallExpression) bool {
+	if in.isPureBuiltin(ident) {
+		return true
+	}
+	decl, ok := in.definitions[ident]
+	if !ok || !in.single(decl) {
+		return false
+	}
+	let := in.lets[decl]
+	if let == nil {
+		// a parameter or a hoisted function declaration
+		return true
+	}
+	before := call.Token.Line < let.Token.Line ||
+		(call.Token.Line == let.Token.Line && call.Token.Column < let.Token.Column)
+	return !before && in.enclosing[call] == in.enclosing[let]
+}
+
+func (in *inliner) add(name *ast.Identifier, fn *ast.FunctionLiteral) {
+	if !in.single(name) || fn.Rest != nil || len(fn.Body.Statements) != 1 {
+		return
+	}
+	for _, value := range fn.Defaults {
+		if value != nil {
+			return
+		}
+	}
+	statement, ok := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return
+	}
+	params := make(map[*ast.Identifier]bool)
+	for _, param := range fn.Parameters {
+		params[param] = true
+	}
+	size, simple := 0, true
+	ast.Inspect(statement.Expression, func(node ast.Node) bool {
+		size++
+		switch node := node.(type) {
+		case *ast.Identifier:
+			if !params[in.definitions[node]] && !in.isPureBuiltin(node) {
+				simple = false
+			}
+		case *ast.CallExpression:
+			if ident, ok := node.Function.(*ast.Identifier); !ok || !in.isPureBuiltin(ident) {
+				simple = false
+			}
+		case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean, *ast.PrefixExpression,
+			*ast.InfixExpression, *ast.IndexExpression, *ast.ArrayLiteral, *ast.HashLiteral:
+		default:
+			simple = false
+		}
+		return simple
+	})
+	if simple && size <= maxInlineSize {
+		in.candidates[name] = &candidate{fn: fn, body: statement.Expression}
+	}
+}
+
+func (in *inliner) isPureBuiltin(ident *ast.Identifier) bool {
+	_, resolved := in.definitions[ident]
+	return !resolved && ident.Slot < 0 && pureBuiltins[ident.Value] && !in.declared[ident.Value]
+}
+
+// call returns what e is inlined to, nil when it is not a call to inline
+func (in *inliner) call(e ast.Expression) ast.Expression {
+	call, ok := e.(*ast.CallExpression)
+	if !ok {
+		return nil
+	}
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	c := in.candidates[in.definitions[ident]]
+	if c == nil || len(call.Arguments) != len(c.fn.Parameters) || !in.bound(ident, call) {
+		return nil
+	}
+	used := make(map[*ast.Identifier]bool)
+	ast.Inspect(c.body, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			used[in.definitions[ident]] = true
+		}
+		return true
+	})
+	arguments := make(map[*ast.Identifier]ast.Expression)
+	for i, argument := range call.Arguments {
+		param := c.fn.Parameters[i]
+		switch argument := argument.(type) {
+		case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		case *ast.Identifier:
+			// the name is read even when the body does not need it
+			if !used[param] || !in.bound(argument, call) {
+				return nil
+			}
+		default:
+			return nil
+		}
+		arguments[param] = argument
+	}
+	return in.copy(c.body, arguments)
+}
+
+// copy returns a copy of e, a body of a candidate, with the parameters
+// replaced by copies of their arguments
+func (in *inliner) copy(e ast.Expression, arguments map[*ast.Identifier]ast.Expression) ast.Expression {
+	list := func(expressions []ast.Expression) []ast.Expression {
+		copied := []ast.Expression{}
+		for _, el := range expressions {
+			copied = append(copied, in.copy(el, arguments))
+		}
+		return copied
+	}
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		copied := *e
+		return &copied
+	case *ast.StringLiteral:
+		copied := *e
+		return &copied
+	case *ast.Boolean:
+		copied := *e
+		return &copied
+	case *ast.Identifier:
+		if argument, ok := arguments[in.definitions[e]]; ok {
+			return in.copy(argument, nil)
+		}
+		return &ast.Identifier{Token: e.Token, Value: e.Value}
+	case *ast.PrefixExpression:
+		return &ast.PrefixExpression{Token: e.Token, Operator: e.Operator, Right: in.copy(e.Right, arguments)}
+	case *ast.InfixExpression:
+		return &ast.InfixExpression{Token: e.Token, Operator: e.Operator, Left: in.copy(e.Left, arguments), Right: in.copy(e.Right, arguments)}
+	case *ast.IndexExpression:
+		return &ast.IndexExpression{Token: e.Token, Left: in.copy(e.Left, arguments), Index: in.copy(e.Index, arguments)}
+	case *ast.CallExpression:
+		return &ast.CallExpression{Token: e.Token, Function: in.copy(e.Function, arguments), Arguments: list(e.Arguments)}
+	case *ast.ArrayLiteral:
+		return &ast.ArrayLiteral{Token: e.Token, Elements: list(e.Elements)}
+	case *ast.HashLiteral:
+		copied := &ast.HashLiteral{Token: e.Token, Keys: list(e.Keys), Pairs: make(map[ast.Expression]ast.Expression)}
+		for i, key := range e.Keys {
+			copied.Pairs[copied.Keys[i]] = in.copy(e.Pairs[key], arguments)
+		}
+		return copied
+	}
+	return e
+}
@@ -0,0 +1,59 @@
+/*
+	Rewrite a parsed Monkey program into one that does less work when it
+	runs and gives the same results.
+		- each Pass rewrites the program in place, Optimize runs passes until
+		  none of them finds anything more to do, so the passes feed each
+		  other: inlining gives constants to fold, folding gives conditions
+		  to branch on
+		- a rewrite never turns an error into a value or a value into an
+		  error: an expression that fails is left to fail when it runs
+		- the program is optimized before it is resolved, the passes resolve
+		  a copy of the scopes themselves when they need to know what a
+		  name refers to
+		- inlined calls leave no frame in the stack of an error, coverage,
+		  profiles and the debugger see the optimized program
+*/
+package optimize
+
+import "interpreter/ast"
+
+// Pass is one rewriting of a program
+type Pass struct {
+	Name string
+	Doc  string
+	// Run rewrites program and reports whether it changed anything
+	Run func(program *ast.Program) bool
+}
+
+// Passes are all the passes, in the order they run
+var Passes = []Pass{
+	{"inline", "calls of small functions of their parameters are replaced by the body", Inline},
+	{"fold", "operators on integer, string and boolean literals are computed", Fold},
+	{"branches", "the branch of an if with a literal condition that is never taken is dropped", Branches},
+}
+
+// a program where the passes keep finding work would be optimized forever
+const maxRounds = 10
+
+// Optimize runs passes over program, all of Passes when none is given, until
+// none of them changes it. It reports whether the program changed, it must
+// be resolved before it runs.
+func Optimize(program *ast.Program, passes ...Pass) bool {
+	if len(passes) == 0 {
+		passes = Passes
+	}
+	changed := false
+	for round := 0; round < maxRounds; round++ {
+		again := false
+		for _, pass := range passes {
+			if pass.Run(program) {
+				again = true
+			}
+		}
+		if !again {
+			break
+		}
+		changed = true
+	}
+	return changed
+}
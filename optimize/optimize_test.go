@@ -0,0 +1,124 @@
+package optimize
+
+import (
+	"bytes"
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object2"
+	"interpreter/parser"
+	"interpreter/resolver"
+	"testing"
+)
+
+func parse(t *testing.T, src string) *ast.Program {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%q: %v", src, p.Errors())
+	}
+	return program
+}
+
+func TestPasses(t *testing.T) {
+	tests := []struct {
+		pass     Pass
+		input    string
+		expected string
+	}{
+		{Passes[1], `let day = 60 * 60 * 24; let s = "a" + "b"; let b = !(1 < 2); -5`, `let day = 86400;let s = ab;let b = false;-5`},
+		{Passes[1], "let big = 9223372036854775807 + 1; 2 ** 64", "let big = 9223372036854775808;18446744073709551616"},
+		// errors and huge values are left for run time
+		{Passes[1], `1 / 0; "a" - "b"; 2 ** 65; x + 1 * 2`, `(1 / 0)(a - b)(2 ** 65)(x + 2)`},
+		{Passes[2], "let a = if (true) { 1 } else { 2 }; if (0) { puts(1); puts(2) } else { 3 } if (false) { 4 } 5", "let a = 1;puts(1)puts(2)5"},
+		// declarations stay in their block, the last if gives the value null
+		{Passes[2], "if (true) { let x = 1; x } else { 2 } if (false) { 1 }", "iftrue let x = 1;xiffalse 1"},
+		{Passes[2], "if (false) { 1 } else { let y = 2; y }", "iftrue let y = 2;y"},
+		{Passes[2], "if (false) { 1 }", "iffalse 1"},
+		{Passes[0], "let sq = fn(x) { x * x }; fn add(a, b) { a + len(b) } sq(3) + add(1, \"ab\") + sq(sq)", "let sq = fn(x)(x * x);fn add(a,b)(a + len(b))(((3 * 3) + (1 + len(ab))) + (sq * sq))"},
+		// not the functions that may be recursive, reassigned or not yet bound
+		{Passes[0], "let f = fn(x) { g(x) }; let g = fn(x) { x }; let h = fn(x) { x }; h = g; f(1) + h(1)", "let f = fn(x)g(x);let g = fn(x)x;let h = fn(x)x;(h = g)(f(1) + h(1))"},
+		{Passes[0], "fn before() { early(1) } let early = fn(x) { x }; fn later() { early(1) } early(2)", "fn before()early(1)let early = fn(x)x;fn later()early(1)2"},
+		{Passes[0], "let f = fn(x, y = 1) { x }; let g = fn(x) { puts(x) }; let h = fn(x) { x }; f(1); g(1); h(1 + 2)", "let f = fn(x,y = 1)x;let g = fn(x)puts(x);let h = fn(x)x;f(1)g(1)h((1 + 2))"},
+		// the builtin could be hidden where the call is
+		{Passes[0], `let f = fn(x) { len(x) }; let g = fn(len) { f("ab") }; g(1)`, "let f = fn(x)len(x);let g = fn(len)f(ab);g(1)"},
+	}
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		Optimize(program, tt.pass)
+		if program.String() != tt.expected {
+			t.Errorf("%s %q:\nexpected=%q\ngot=     %q", tt.pass.Name, tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	program := parse(t, `let sq = fn(x) { x * x }; if (sq(4) > 10) { "big" } else { "small" }`)
+	if !Optimize(program) {
+		t.Fatal("nothing optimized")
+	}
+	if program.String() != "let sq = fn(x)(x * x);big" {
+		t.Errorf("optimized program wrong. got=%q", program.String())
+	}
+	if Optimize(program) {
+		t.Errorf("optimized again")
+	}
+}
+
+// run resolves and evaluates program, it returns what it printed and its
+// value, the message only for an error
+func run(t *testing.T, program *ast.Program) (string, string) {
+	r := resolver.New(evaluator.NewGlobalScope())
+	r.Resolve(program)
+	if len(r.Errors()) > 0 {
+		t.Fatalf("resolver errors: %v", r.Errors())
+	}
+	var out bytes.Buffer
+	result := evaluator.Eval(program, evaluator.NewEnvironment(&evaluator.Context{Stdout: &out}))
+	if err, ok := result.(*object2.Error); ok {
+		return out.String(), "ERROR: " + err.Message
+	}
+	if result == nil {
+		return out.String(), "nil"
+	}
+	return out.String(), result.Inspect()
+}
+
+// the optimized program does what the program does
+func TestDifferential(t *testing.T) {
+	programs := []string{
+		"let day = 60 * 60 * 24; fn seconds(days) { days * day } seconds(7)",
+		"let sq = fn(x) { x * x }; let cube = fn(x) { x * sq(x) }; [sq(3), cube(2), sq(-4), sq(9223372036854775807)]",
+		`let sq = fn(x) { x * x }; sq("a")`,
+		`let greet = fn(name) { "hello " + name }; puts(greet("you")); greet(1)`,
+		"let f = fn(x) { 10 / x }; puts(f(5)); f(0)",
+		"fn fact(n) { if (n == 0) { 1 } else { n * fact(n - 1) } } fact(25)",
+		"fn count(n) { if (n == 0) { return 0; } if (true) { count(n - 1) } } count(100000)",
+		"fn f() { if (true) { let x = 1; x + 1 } else { 0 } } f()",
+		"fn f() { if (false) { 1 } } f()",
+		"fn f() { if (1) { return 5; } 6 } f()",
+		"let x = 1; if (false) { puts(\"never\") } else { puts(\"always\") } x",
+		`let pick = fn(h, k) { h[k] }; let h = {"a": 1 + 1}; [pick(h, "a"), pick(h, "b"), pick([1, 2], 1)]`,
+		`let size = fn(x) { len(x) }; [size("abc"), size([1, 2]), size(1)]`,
+		"let a = 1; let inc = fn(x) { x + 1 }; a = inc(a); inc(a)",
+		"let f = fn(x) { x }; let g = fn(x) { f(x) + f(x) }; g(2) ** 64",
+		"1 << 70; 1 / 0",
+		`match (2 * 3) { 6 => "six", _ => "other" }`,
+		"let check = fn(a, b) { a == b }; [check(1, 1), check(true, false), check(1, \"1\")]",
+		// the names bound in a branch share the slot of the function
+		"let c = false; let f = fn(x) { x + 1 }; if (c) { let f = fn(x) { x * 100 }; } puts(f(3));",
+		"let c = false; if (c) { let g = fn(x) { x * 100 }; } puts(g(3));",
+		"let k = fn(a, b) { a }; if (false) { let y = 1; } puts(k(1, y));",
+		"let k = fn(a, b) { b + a }; let c = false; if (c) { let y = 1; let z = 2; } k(y, z)",
+	}
+	for _, src := range programs {
+		wantOut, want := run(t, parse(t, src))
+		optimized := parse(t, src)
+		Optimize(optimized)
+		gotOut, got := run(t, optimized)
+		if got != want || gotOut != wantOut {
+			t.Errorf("%q optimized to %q gives %q printing %q, want %q printing %q",
+				src, optimized.String(), got, gotOut, want, wantOut)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package optimize
+
+import "interpreter/ast"
+
+// rewrite replaces every expression below node by what f returns for it,
+// children first, so f sees the rewritten children of an expression. The
+// target of an assignment and the patterns, but for their default values,
+// are left alone.
+func rewrite(node ast.Node, f func(ast.Expression) ast.Expression) {
+	expression := func(e ast.Expression) ast.Expression {
+		return rewriteExpression(e, f)
+	}
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			rewrite(s, f)
+		}
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			rewrite(s, f)
+		}
+	case *ast.LetStatement:
+		if node.Pattern != nil {
+			rewrite(node.Pattern, f)
+		}
+		node.Value = expression(node.Value)
+	case *ast.ReturnStatement:
+		node.ReturnValue = expression(node.ReturnValue)
+	case *ast.ExpressionStatement:
+		node.Expression = expression(node.Expression)
+	case *ast.FunctionDeclaration:
+		rewrite(node.Function, f)
+	case *ast.ImplStatement:
+		for _, method := range node.Methods {
+			rewrite(method, f)
+		}
+	case *ast.PrefixExpression:
+		node.Right = expression(node.Right)
+	case *ast.InfixExpression:
+		node.Left = expression(node.Left)
+		node.Right = expression(node.Right)
+	case *ast.IfExpression:
+		node.Condition = expression(node.Condition)
+		rewrite(node.Consequence, f)
+		if node.Alternative != nil {
+			rewrite(node.Alternative, f)
+		}
+	case *ast.FunctionLiteral:
+		for i := range node.Defaults {
+			node.Defaults[i] = expression(node.Defaults[i])
+		}
+		rewrite(node.Body, f)
+	case *ast.CallExpression:
+		node.Function = expression(node.Function)
+		expressions(node.Arguments, f)
+	case *ast.InterpolatedString:
+		expressions(node.Parts, f)
+	case *ast.ArrayLiteral:
+		expressions(node.Elements, f)
+	case *ast.IndexExpression:
+		node.Left = expression(node.Left)
+		node.Index = expression(node.Index)
+	case *ast.HashLiteral:
+		pairs := make(map[ast.Expression]ast.Expression, len(node.Pairs))
+		for i, key := range node.Keys {
+			value := node.Pairs[key]
+			node.Keys[i] = expression(key)
+			pairs[node.Keys[i]] = expression(value)
+		}
+		node.Pairs = pairs
+	case *ast.SliceExpression:
+		node.Left = expression(node.Left)
+		node.Start = expression(node.Start)
+		node.End = expression(node.End)
+		node.Step = expression(node.Step)
+	case *ast.RangeExpression:
+		node.Start = expression(node.Start)
+		node.End = expression(node.End)
+	case *ast.SpreadExpression:
+		node.Value = expression(node.Value)
+	case *ast.KeywordArgument:
+		node.Value = expression(node.Value)
+	case *ast.AssignExpression:
+		if _, ok := node.Target.(*ast.Identifier); !ok {
+			rewrite(node.Target, f)
+		}
+		node.Value = expression(node.Value)
+	case *ast.DotExpression:
+		node.Left = expression(node.Left)
+	case *ast.MatchExpression:
+		node.Value = expression(node.Value)
+		for _, arm := range node.Arms {
+			rewrite(arm.Pattern, f)
+			arm.Guard = expression(arm.Guard)
+			rewrite(arm.Body, f)
+		}
+	case *ast.ArrayPattern:
+		for _, el := range node.Elements {
+			rewrite(el, f)
+		}
+	case *ast.HashPattern:
+		for _, value := range node.Values {
+			rewrite(value, f)
+		}
+	case *ast.VariantPattern:
+		for _, el := range node.Elements {
+			rewrite(el, f)
+		}
+	case *ast.DefaultPattern:
+		rewrite(node.Pattern, f)
+		node.Default = expression(node.Default)
+	}
+}
+
+func rewriteExpression(e ast.Expression, f func(ast.Expression) ast.Expression) ast.Expression {
+	if e == nil {
+		return nil
+	}
+	rewrite(e, f)
+	return f(e)
+}
+
+func expressions(list []ast.Expression, f func(ast.Expression) ast.Expression) {
+	for i := range list {
+		list[i] = rewriteExpression(list[i], f)
+	}
+}
+
+// statementLists calls f for the statements of the program and of every
+// block in it, outer ones first, and keeps what f returns as their new
+// statements
+func statementLists(program *ast.Program, f func([]ast.Statement) []ast.Statement) {
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			node.Statements = f(node.Statements)
+		case *ast.BlockStatement:
+			node.Statements = f(node.Statements)
+		}
+		return true
+	})
+}
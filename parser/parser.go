@@ -5,6 +5,7 @@ import (
 	"interpreter/ast"
 	"interpreter/lexer"
 	"interpreter/token2"
+	"math/big"
 	"strconv"
 )
 
@@ -14,6 +15,8 @@ type Parser struct {
 	curToken  token2.Token
 	peekToken token2.Token
 	errors    []string
+	// the token each error was found at
+	errorTokens []token2.Token
 
 	// prefix function and infix function
 	prefixParseFns map[token2.TokenType]prefixParseFn
@@ -23,11 +26,18 @@ type Parser struct {
 const (
 	_ int = iota // set increment number
 	LOWEST
+	ASSIGN      // x = 1
 	EQUALS      //==
 	LESSGREATER // > or <
+	RANGE       // 1..10 or 1..<10
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
+	SHIFT       // << or >>
 	SUM         // +
-	PRODUCT     // *
+	PRODUCT     // * / %
 	PREFIX      //-X or +X
+	POWER       // ** binds tighter than prefix operators: -2 ** 2 == -(2 ** 2)
 	CALL        // myFunction(X)
 	INDEX
 )
@@ -38,16 +48,36 @@ type (
 )
 
 var precedences = map[token2.TokenType]int{
+	token2.ASSIGN:   ASSIGN,
 	token2.EQ:       EQUALS,
 	token2.NOT_EQ:   EQUALS,
 	token2.LT:       LESSGREATER,
 	token2.GT:       LESSGREATER,
+	token2.DOTDOT:   RANGE,
+	token2.DOTDOTLT: RANGE,
 	token2.PLUS:     SUM,
 	token2.MINUS:    SUM,
 	token2.SLASH:    PRODUCT,
 	token2.ASTERISK: PRODUCT,
+	token2.PERCENT:  PRODUCT,
+	token2.POWER:    POWER,
+	token2.BIT_AND:  BIT_AND,
+	token2.BIT_OR:   BIT_OR,
+	token2.BIT_XOR:  BIT_XOR,
+	token2.LSHIFT:   SHIFT,
+	token2.RSHIFT:   SHIFT,
 	token2.LPAREN:   CALL,
 	token2.LBRACKET: INDEX,
+	token2.DOT:      INDEX,
+}
+
+// Precedence returns how tightly the infix operator t binds, LOWEST when t
+// is not an infix operator
+func Precedence(t token2.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -74,26 +104,40 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token2.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token2.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token2.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token2.BIT_NOT, p.parsePrefixExpression)
 	p.registerPrefix(token2.TRUE, p.parseBoolean)
 	p.registerPrefix(token2.FALSE, p.parseBoolean)
 	p.registerPrefix(token2.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token2.IF, p.parseIfExpression)
 	p.registerPrefix(token2.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token2.STRING, p.parseStringLiteral)
+	p.registerPrefix(token2.INTERP_START, p.parseInterpolatedString)
 	p.registerPrefix(token2.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token2.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token2.MATCH, p.parseMatchExpression)
 
 	p.infixParseFns = make(map[token2.TokenType]infixParseFn)
 	p.registerInfix(token2.PLUS, p.parseInfixExpression)
 	p.registerInfix(token2.MINUS, p.parseInfixExpression)
 	p.registerInfix(token2.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token2.SLASH, p.parseInfixExpression)
+	p.registerInfix(token2.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token2.POWER, p.parseInfixExpression)
+	p.registerInfix(token2.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token2.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token2.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token2.LSHIFT, p.parseInfixExpression)
+	p.registerInfix(token2.RSHIFT, p.parseInfixExpression)
 	p.registerInfix(token2.EQ, p.parseInfixExpression)
 	p.registerInfix(token2.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token2.GT, p.parseInfixExpression)
 	p.registerInfix(token2.LT, p.parseInfixExpression)
 	p.registerInfix(token2.LPAREN, p.parseCallExpression)
 	p.registerInfix(token2.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token2.DOTDOT, p.parseRangeExpression)
+	p.registerInfix(token2.DOTDOTLT, p.parseRangeExpression)
+	p.registerInfix(token2.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token2.DOT, p.parseDotExpression)
 	// read two token to initialize curToken and peekToken
 	p.nextToken()
 	p.nextToken()
@@ -106,6 +150,34 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 		Value: p.curToken.Literal,
 	}
 }
+
+// the text segments become string literals, empty ones are left out
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	expression := &ast.InterpolatedString{Token: p.curToken}
+	for {
+		if p.curToken.Literal != "" {
+			expression.Parts = append(expression.Parts, p.parseStringLiteral())
+		}
+		if p.curTokenIs(token2.INTERP_END) {
+			return expression
+		}
+		p.nextToken()
+		if p.curTokenIs(token2.INTERP_MID) || p.curTokenIs(token2.INTERP_END) {
+			p.errorAt(p.curToken, "empty expression in string interpolation")
+			return nil
+		}
+		part := p.parseExpression(LOWEST)
+		if part == nil {
+			return nil
+		}
+		expression.Parts = append(expression.Parts, part)
+		if p.peekTokenIs(token2.INTERP_MID) {
+			p.nextToken()
+		} else if !p.expectPeek(token2.INTERP_END) {
+			return nil
+		}
+	}
+}
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
@@ -113,6 +185,10 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 		Left:     left,
 	}
 	precedence := p.curPrecedence()
+	// ** is right associative: 2 ** 3 ** 2 == 2 ** (3 ** 2)
+	if p.curTokenIs(token2.POWER) {
+		precedence--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 	return expression
@@ -136,14 +212,19 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	literal := &ast.IntegerLiteral{Token: p.curToken}
+	// base 0 accepts the 0x, 0o and 0b prefixes and _ between digits
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
-	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+	if err == nil {
+		literal.Value = value
+		return literal
+	}
+	if bigValue, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+		literal.Big = bigValue
+		return literal
 	}
-	literal.Value = value
-	return literal
+	msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
+	p.errorAt(p.curToken, msg)
+	return nil
 }
 
 func (p *Parser) nextToken() {
@@ -166,36 +247,181 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token2.LET:
+	case token2.LET, token2.CONST:
 		return p.parseLetStatement()
 	case token2.RETURN:
 		return p.parseReturnStatement()
+	case token2.FUNCTION:
+		// fn followed by a name declares a function, otherwise it is a literal
+		if p.peekTokenIs(token2.IDENT) {
+			return p.parseFunctionDeclaration()
+		}
+		return p.parseExpressionStatement()
+	case token2.STRUCT:
+		return p.parseStructStatement()
+	case token2.IMPL:
+		return p.parseImplStatement()
+	case token2.ENUM:
+		return p.parseEnumStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-// parse let statement
+// parse let statement, const statements share the syntax
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	statement := &ast.LetStatement{Token: p.curToken}
-	if !p.expectPeek(token2.IDENT) {
-		return nil
-	}
-	statement.Name = &ast.Identifier{
-		Token: p.curToken,
-		Value: p.curToken.Literal,
+	// let [a, b] = ... and let {a, b} = ... destructure the value
+	if p.peekTokenIs(token2.LBRACKET) || p.peekTokenIs(token2.LBRACE) {
+		p.nextToken()
+		statement.Pattern = p.parsePattern()
+		if statement.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token2.IDENT) {
+			return nil
+		}
+		statement.Name = &ast.Identifier{
+			Token: p.curToken,
+			Value: p.curToken.Literal,
+		}
+		var ok bool
+		if statement.Type, ok = p.parseTypeAnnotation(); !ok {
+			return nil
+		}
 	}
 	if !p.expectPeek(token2.ASSIGN) {
 		return nil
 	}
 	p.nextToken()
 	statement.Value = p.parseExpression(LOWEST)
-	for !p.curTokenIs(token2.SEMICOLON) {
+	for !p.curTokenIs(token2.SEMICOLON) && !p.curTokenIs(token2.EOF) {
+		p.nextToken()
+	}
+	return statement
+}
+
+func (p *Parser) parseFunctionDeclaration() ast.Statement {
+	declaration := &ast.FunctionDeclaration{Token: p.curToken}
+	p.nextToken()
+	declaration.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	declaration.Function = &ast.FunctionLiteral{Token: declaration.Token, Name: declaration.Name.Value}
+	if !p.expectPeek(token2.LPAREN) {
+		return nil
+	}
+	if !p.parseFunctionParameters(declaration.Function) {
+		return nil
+	}
+	if !p.expectPeek(token2.LBRACE) {
+		return nil
+	}
+	declaration.Function.Body = p.parseBlockStatement()
+	if p.peekTokenIs(token2.SEMICOLON) {
+		p.nextToken()
+	}
+	return declaration
+}
+
+func (p *Parser) parseStructStatement() ast.Statement {
+	statement := &ast.StructStatement{Token: p.curToken}
+	if !p.expectPeek(token2.IDENT) {
+		return nil
+	}
+	statement.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token2.LBRACE) {
+		return nil
+	}
+	for !p.peekTokenIs(token2.RBRACE) {
+		if !p.expectPeek(token2.IDENT) {
+			return nil
+		}
+		statement.Fields = append(statement.Fields, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		if !p.peekTokenIs(token2.RBRACE) && !p.expectPeek(token2.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token2.RBRACE) {
+		return nil
+	}
+	if p.peekTokenIs(token2.SEMICOLON) {
 		p.nextToken()
 	}
 	return statement
 }
 
+// parse enum Name { A(x, y), B }, a variant without payload has no parentheses
+func (p *Parser) parseEnumStatement() ast.Statement {
+	statement := &ast.EnumStatement{Token: p.curToken}
+	if !p.expectPeek(token2.IDENT) {
+		return nil
+	}
+	statement.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token2.LBRACE) {
+		return nil
+	}
+	for !p.peekTokenIs(token2.RBRACE) {
+		if !p.expectPeek(token2.IDENT) {
+			return nil
+		}
+		variant := &ast.EnumVariant{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+		if p.peekTokenIs(token2.LPAREN) {
+			p.nextToken()
+			for !p.peekTokenIs(token2.RPAREN) {
+				if !p.expectPeek(token2.IDENT) {
+					return nil
+				}
+				variant.Fields = append(variant.Fields, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+				if !p.peekTokenIs(token2.RPAREN) && !p.expectPeek(token2.COMMA) {
+					return nil
+				}
+			}
+			p.nextToken()
+		}
+		statement.Variants = append(statement.Variants, variant)
+		if !p.peekTokenIs(token2.RBRACE) && !p.expectPeek(token2.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token2.RBRACE) {
+		return nil
+	}
+	if p.peekTokenIs(token2.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
+}
+
+// the body of an impl holds nothing but method declarations
+func (p *Parser) parseImplStatement() ast.Statement {
+	statement := &ast.ImplStatement{Token: p.curToken}
+	if !p.expectPeek(token2.IDENT) {
+		return nil
+	}
+	statement.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token2.LBRACE) {
+		return nil
+	}
+	for !p.peekTokenIs(token2.RBRACE) {
+		if !p.expectPeek(token2.FUNCTION) {
+			return nil
+		}
+		if !p.peekTokenIs(token2.IDENT) {
+			p.peekError(token2.IDENT)
+			return nil
+		}
+		method, ok := p.parseFunctionDeclaration().(*ast.FunctionDeclaration)
+		if !ok {
+			return nil
+		}
+		statement.Methods = append(statement.Methods, method)
+	}
+	if !p.expectPeek(token2.RBRACE) {
+		return nil
+	}
+	return statement
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	statement := &ast.ReturnStatement{
 		Token: p.curToken,
@@ -204,7 +430,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	statement.ReturnValue = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(token2.SEMICOLON) {
+	for !p.curTokenIs(token2.SEMICOLON) && !p.curTokenIs(token2.EOF) {
 		p.nextToken()
 	}
 	return statement
@@ -232,10 +458,20 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// ErrorTokens returns the token each of Errors was found at
+func (p *Parser) ErrorTokens() []token2.Token {
+	return p.errorTokens
+}
+
+func (p *Parser) errorAt(token token2.Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.errorTokens = append(p.errorTokens, token)
+}
+
 func (p *Parser) peekError(t token2.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.peekToken, msg)
 }
 
 // fin in prefix function
@@ -278,7 +514,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 func (p *Parser) noPrefixParseFnError(t token2.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken, msg)
 }
 
 func (p *Parser) parseBoolean() ast.Expression {
@@ -333,6 +569,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	block.End = p.curToken
 	return block
 }
 
@@ -341,7 +578,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.expectPeek(token2.LPAREN) {
 		return nil
 	}
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 	if !p.expectPeek(token2.LBRACE) {
 		return nil
 	}
@@ -349,33 +588,105 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
-	if p.peekTokenIs(token2.RPAREN) {
-		p.nextToken()
-		return identifiers
-	}
-	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
-	for p.peekTokenIs(token2.COMMA) {
-		p.nextToken()
+// parse (x, y: int = 10, ...rest) -> int into lit, parameters with a default
+// come after the ones without and the rest parameter comes last
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
+	for !p.peekTokenIs(token2.RPAREN) {
 		p.nextToken()
+		if p.curTokenIs(token2.ELLIPSIS) {
+			if !p.expectPeek(token2.IDENT) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+		if !p.curTokenIs(token2.IDENT) {
+			p.errorAt(p.curToken, fmt.Sprintf("expected parameter name, got %s instead", p.curToken.Type))
+			return false
+		}
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		paramType, ok := p.parseTypeAnnotation()
+		if !ok {
+			return false
+		}
+		var defaultValue ast.Expression
+		if p.peekTokenIs(token2.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			defaultValue = p.parseExpression(LOWEST)
+		} else if len(lit.Defaults) > 0 && lit.Defaults[len(lit.Defaults)-1] != nil {
+			msg := fmt.Sprintf("parameter %s without default follows a parameter with default", ident.Value)
+			p.errorAt(p.curToken, msg)
+			return false
+		}
+		lit.Parameters = append(lit.Parameters, ident)
+		lit.ParameterTypes = append(lit.ParameterTypes, paramType)
+		lit.Defaults = append(lit.Defaults, defaultValue)
+		if !p.peekTokenIs(token2.RPAREN) && !p.expectPeek(token2.COMMA) {
+			return false
+		}
 	}
 	if !p.expectPeek(token2.RPAREN) {
-		return nil
+		return false
 	}
-	return identifiers
+	if p.peekTokenIs(token2.RARROW) {
+		p.nextToken()
+		p.nextToken()
+		if lit.ReturnType = p.parseType(); lit.ReturnType == nil {
+			return false
+		}
+	}
+	return true
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token2.RPAREN)
+	exp.Arguments = p.parseCallArguments()
+	if exp.Arguments == nil {
+		return nil
+	}
 	return exp
 }
 
+// like parseExpressionList, but an argument may be spread with ... or
+// passed by name with `name: value` after the positional ones
+func (p *Parser) parseCallArguments() []ast.Expression {
+	args := []ast.Expression{}
+	keywords := false
+	for !p.peekTokenIs(token2.RPAREN) {
+		p.nextToken()
+		var arg ast.Expression
+		switch {
+		case p.curTokenIs(token2.IDENT) && p.peekTokenIs(token2.COLON):
+			keyword := &ast.KeywordArgument{Token: p.curToken, Name: p.curToken.Literal}
+			p.nextToken()
+			p.nextToken()
+			keyword.Value = p.parseExpression(LOWEST)
+			arg = keyword
+			keywords = true
+		case keywords:
+			p.errorAt(p.curToken, "positional argument follows keyword argument")
+			return nil
+		case p.curTokenIs(token2.ELLIPSIS):
+			spread := &ast.SpreadExpression{Token: p.curToken}
+			p.nextToken()
+			spread.Value = p.parseExpression(LOWEST)
+			arg = spread
+		default:
+			arg = p.parseExpression(LOWEST)
+		}
+		args = append(args, arg)
+		if !p.peekTokenIs(token2.RPAREN) && !p.expectPeek(token2.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token2.RPAREN) {
+		return nil
+	}
+	return args
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token2.RBRACKET)
@@ -410,13 +721,83 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 		return nil
 	}
 	p.nextToken()
+	// arr[:end] has no start expression
+	if p.curTokenIs(token2.COLON) {
+		return p.parseSliceExpression(indexExpression.Token, left, nil)
+	}
 	indexExpression.Index = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token2.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(indexExpression.Token, left, indexExpression.Index)
+	}
 	if !p.expectPeek(token2.RBRACKET) {
 		return nil
 	}
 	return indexExpression
 }
 
+// parse the rest of arr[start:end:step], the current token is the first ':'
+func (p *Parser) parseSliceExpression(token token2.Token, left ast.Expression, start ast.Expression) ast.Expression {
+	slice := &ast.SliceExpression{Token: token, Left: left, Start: start}
+	if !p.peekTokenIs(token2.COLON) && !p.peekTokenIs(token2.RBRACKET) {
+		p.nextToken()
+		slice.End = p.parseExpression(LOWEST)
+	}
+	if p.peekTokenIs(token2.COLON) {
+		p.nextToken()
+		if !p.peekTokenIs(token2.RBRACKET) {
+			p.nextToken()
+			slice.Step = p.parseExpression(LOWEST)
+		}
+	}
+	if !p.expectPeek(token2.RBRACKET) {
+		return nil
+	}
+	return slice
+}
+
+func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
+	expression := &ast.DotExpression{Token: p.curToken, Left: left}
+	if !p.expectPeek(token2.IDENT) {
+		return nil
+	}
+	expression.Name = p.curToken.Literal
+	return expression
+}
+
+// assignment is right associative: a = b = 1 assigns 1 to both
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	// the target did not parse, its error is reported already
+	if target == nil {
+		return nil
+	}
+	expression := &ast.AssignExpression{Token: p.curToken, Target: target}
+	switch target.(type) {
+	case *ast.Identifier, *ast.DotExpression:
+	default:
+		// a target that only partly parsed has nil children, String would
+		// walk into them
+		msg := fmt.Sprintf("invalid assignment target: %q", target.TokenLiteral())
+		p.errorAt(p.curToken, msg)
+		return nil
+	}
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+	return expression
+}
+
+func (p *Parser) parseRangeExpression(left ast.Expression) ast.Expression {
+	expression := &ast.RangeExpression{
+		Token:     p.curToken,
+		Start:     left,
+		Inclusive: p.curTokenIs(token2.DOTDOT),
+	}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.End = p.parseExpression(precedence)
+	return expression
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hashLiteral := &ast.HashLiteral{Token: p.curToken}
 	hashLiteral.Pairs = make(map[ast.Expression]ast.Expression)
@@ -432,6 +813,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		hashLiteral.Pairs[key] = value
+		hashLiteral.Keys = append(hashLiteral.Keys, key)
 		//ingenious method &&
 		if !p.peekTokenIs(token2.RBRACE) && !p.expectPeek(token2.COMMA) {
 			return nil
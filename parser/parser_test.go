@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"interpreter/ast"
 	"interpreter/lexer"
+	"interpreter/token2"
 	"testing"
 )
 
@@ -355,6 +356,62 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a * [1,2,3,4][b * c] * d",
 			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
 		},
+		{
+			"1..n + 1",
+			"(1..(n + 1))",
+		},
+		{
+			"a < 1..<b",
+			"(a < (1..<b))",
+		},
+		{
+			"a[1:2] + b[::-1]",
+			"((a[1:2]) + (b[::(-1)]))",
+		},
+		{
+			"a[:n - 1]",
+			"(a[:(n - 1)])",
+		},
+		{
+			"x = y = a + b",
+			"(x = (y = (a + b)))",
+		},
+		{
+			"x = a == b",
+			"(x = (a == b))",
+		},
+		{
+			"a * b % c",
+			"((a * b) % c)",
+		},
+		{
+			"-a ** b",
+			"(-(a ** b))",
+		},
+		{
+			"a ** b ** c",
+			"(a ** (b ** c))",
+		},
+		{
+			"a ** -b",
+			"(a ** (-b))",
+		},
+		{
+			"a | b ^ c & d",
+			"(a | (b ^ (c & d)))",
+		},
+		{
+			"a & b == c",
+			"((a & b) == c)",
+		},
+		{
+			"a << b + c >> d",
+			"((a << (b + c)) >> d)",
+		},
+		{
+			"~a & b",
+			"((~a) & b)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -584,6 +641,43 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 1;", "let x: int = 1;"},
+		{"let xs: [[string]] = [];", "let xs: [[string]] = [];"},
+		{"fn(a: int, b: {string: bool} = {}, ...c) -> bool { true }", "fn(a: int,b: {string: bool} = {},...c) -> bool true"},
+		{"fn f(g: fn(int, string) -> int, h: fn()) { g }", "fn f(g: fn(int, string) -> int,h: fn())g"},
+		{"fn(x) -> fn(int) -> [int] { x }", "fn(x) -> fn(int) -> [int] x"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: = 1;", "expected type, got = instead"},
+		{"fn(a: [int) {}", "expected next token to be ], got ) instead"},
+		{"fn() -> {}", "expected type, got } instead"},
+	}
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q: expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -806,3 +900,516 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		start interface{}
+		end   interface{}
+		step  interface{}
+	}{
+		{"arr[1:2]", 1, 2, nil},
+		{"arr[1:]", 1, nil, nil},
+		{"arr[:2]", nil, 2, nil},
+		{"arr[:]", nil, nil, nil},
+		{"arr[::3]", nil, nil, 3},
+		{"arr[1:2:3]", 1, 2, 3},
+		{"arr[1::3]", 1, nil, 3},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		slice, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+		if !testIdentifier(t, slice.Left, "arr") {
+			return
+		}
+		parts := []ast.Expression{slice.Start, slice.End, slice.Step}
+		for i, expected := range []interface{}{tt.start, tt.end, tt.step} {
+			if expected == nil {
+				if parts[i] != nil {
+					t.Errorf("%q: part %d should be empty. got=%s", tt.input, i, parts[i])
+				}
+				continue
+			}
+			testLiteralExpression(t, parts[i], expected)
+		}
+	}
+}
+
+func TestParsingRangeExpressions(t *testing.T) {
+	tests := []struct {
+		input     string
+		inclusive bool
+	}{
+		{"1..10", true},
+		{"1..<10", false},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		rng, ok := stmt.Expression.(*ast.RangeExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.RangeExpression. got=%T", stmt.Expression)
+		}
+		if rng.Inclusive != tt.inclusive {
+			t.Errorf("rng.Inclusive not %t. got=%t", tt.inclusive, rng.Inclusive)
+		}
+		testIntegerLiteral(t, rng.Start, 1)
+		testIntegerLiteral(t, rng.End, 10)
+	}
+}
+
+func TestIntegerLiteralForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0x1F", "31"},
+		{"0XfF", "255"},
+		{"0o17", "15"},
+		{"0b1011", "11"},
+		{"1_000_000", "1000000"},
+		{"0xFFFF_FFFF", "4294967295"},
+		{"9223372036854775807", "9223372036854775807"},
+		{"9223372036854775808", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"0x1_0000_0000_0000_0000", "18446744073709551616"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		got := fmt.Sprintf("%d", literal.Value)
+		if literal.Big != nil {
+			got = literal.Big.String()
+		}
+		if got != tt.expected {
+			t.Errorf("%q: expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+		if literal.TokenLiteral() != tt.input {
+			t.Errorf("literal.TokenLiteral not %s. got=%s", tt.input, literal.TokenLiteral())
+		}
+	}
+}
+
+func TestInvalidIntegerLiterals(t *testing.T) {
+	for _, input := range []string{"1__0", "1_", "0x", "0b102"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected a parser error", input)
+		}
+	}
+}
+
+func TestParsingMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { 1 => a, _ => b }", "match (x) { 1 => a, _ => b }"},
+		{"match (x) { -1 => a, \"s\" => b, true => c }", "match (x) { (-1) => a, s => b, true => c }"},
+		{"match (x) { [] => 0, [h, ...t] => h, [a, ...] => a }", "match (x) { [] => 0, [h, ...t] => h, [a, ..._] => a }"},
+		{`match (x) { {"type": "user", "name": n} => n }`, "match (x) { {type: user, name: n} => n }"},
+		{"match (x) { n if n > 0 => n, n => -n, }", "match (x) { n if (n > 0) => n, n => (-n) }"},
+		{"match (x) { n => { let y = n; y } _ => 0 }", "match (x) { n => let y = n;y, _ => 0 }"},
+		{"match (x) { [[a, b], {1: c}] => a + b + c }", "match (x) { [[a, b], {1: c}] => ((a + b) + c) }"},
+		{"match (x) { Ok(v) => v, Err(_) => 0, None => 1 }", "match (x) { Ok(v) => v, Err(_) => 0, None => 1 }"},
+		{"match (x) { Pair(Some(a), [b]) => a, Unit() => 0 }", "match (x) { Pair(Some(a), [b]) => a, Unit() => 0 }"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: program.Statements does not contain 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.MatchExpression); !ok {
+			t.Fatalf("exp not *ast.MatchExpression. got=%T", stmt.Expression)
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestInvalidMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { a + 1 => a }", "expected next token to be =>, got + instead"},
+		{"match (x) { (a) => a }", "unexpected ( in pattern"},
+		{"match (x) { Ok(1 + 1) => a }", "expected next token to be ,, got + instead"},
+		{"match (x) { [...t, a] => a }", "expected next token to be ], got , instead"},
+		{"match (x) { 1 => a 2 => b }", "expected next token to be ,, got INT instead"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q: expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestParsingDestructuringLet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = pair;", "let [a, b] = pair;"},
+		{"let [a, b, ...rest] = list;", "let [a, b, ...rest] = list;"},
+		{"let [a, _, ...] = list;", "let [a, _, ..._] = list;"},
+		{"let {name, age: years} = person;", "let {name: name, age: years} = person;"},
+		{"let [x = 1, y = x + 1] = p;", "let [x = 1, y = (x + 1)] = p;"},
+		{"let {name = \"anon\", tags: [first, ...]} = person;", "let {name: name = anon, tags: [first, ..._]} = person;"},
+		{`let {"key": v, 1: w} = h;`, "let {key: v, 1: w} = h;"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("statement not *ast.LetStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Pattern == nil || stmt.Name != nil {
+			t.Errorf("%q: expected a pattern and no name. got pattern=%v, name=%v", tt.input, stmt.Pattern, stmt.Name)
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestParsingFunctionSignatures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x, y = 10) { x };", "fn(x,y = 10)x"},
+		{"fn(first, ...others) { first };", "fn(first,...others)first"},
+		{"fn(x = 1, y = x + 1, ...z) { y };", "fn(x = 1,y = (x + 1),...z)y"},
+		{"fn(...args) { args };", "fn(...args)args"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FunctionLiteral. got=%T", stmt.Expression)
+		}
+		if len(function.Defaults) != len(function.Parameters) {
+			t.Errorf("%q: Defaults not parallel to Parameters. got=%d, want=%d",
+				tt.input, len(function.Defaults), len(function.Parameters))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestParsingCallArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(...arr)", "f(...arr)"},
+		{"f(1, ...rest, 2)", "f(1, ...rest, 2)"},
+		{"f(1, y: 2, z: a + b)", "f(1, y: 2, z: (a + b))"},
+		{"f(x: {1: 2})", "f(x: {1:2})"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestInvalidFunctionSignatures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x = 1, y) { x }", "parameter y without default follows a parameter with default"},
+		{"fn(...a, b) { a }", "expected next token to be ), got , instead"},
+		{"fn(1) { 1 }", "expected parameter name, got INT instead"},
+		{"f(y: 1, 2)", "positional argument follows keyword argument"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q: expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestParsingFunctionDeclarations(t *testing.T) {
+	input := `fn add(x, y = 1) { x + y }
+fn(x) { x };
+fn noop() {};`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
+	}
+	declaration, ok := program.Statements[0].(*ast.FunctionDeclaration)
+	if !ok {
+		t.Fatalf("statement not *ast.FunctionDeclaration. got=%T", program.Statements[0])
+	}
+	if declaration.Name.Value != "add" || declaration.Function.Name != "add" {
+		t.Errorf("declaration name not add. got=%q, %q", declaration.Name.Value, declaration.Function.Name)
+	}
+	if declaration.String() != "fn add(x,y = 1)(x + y)" {
+		t.Errorf("declaration.String() wrong. got=%q", declaration.String())
+	}
+	// without a name fn is still a function literal
+	if _, ok := program.Statements[1].(*ast.ExpressionStatement); !ok {
+		t.Errorf("statement not *ast.ExpressionStatement. got=%T", program.Statements[1])
+	}
+	if _, ok := program.Statements[2].(*ast.FunctionDeclaration); !ok {
+		t.Errorf("statement not *ast.FunctionDeclaration. got=%T", program.Statements[2])
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	input := "const x = 5; const [a, b] = pair;"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	for _, statement := range program.Statements {
+		let, ok := statement.(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("statement not *ast.LetStatement. got=%T", statement)
+		}
+		if let.Token.Type != token2.CONST {
+			t.Errorf("let.Token not CONST. got=%q", let.Token.Type)
+		}
+	}
+	if program.String() != "const x = 5;const [a, b] = pair;" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	p := New(lexer.New("1 + 2 = 3"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != `invalid assignment target: "+"` {
+		t.Errorf("expected an invalid assignment target error, got=%v", p.Errors())
+	}
+}
+
+func TestAssignmentToUnparsedTarget(t *testing.T) {
+	p := New(lexer.New("09 = 1"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != `could not parse "09" as integer` {
+		t.Errorf("expected an integer error, got=%v", p.Errors())
+	}
+}
+
+// an invalid target that only partly parsed is reported without panicking
+func TestAssignmentToPartlyParsedTarget(t *testing.T) {
+	inputs := []string{
+		"(a +) = 1",
+		"99999999999999999999 !<<= 99999999999999999998",
+		"(,(p.x) = ((a.b) + 1))",
+		"status wrong. got=%=d",
+		"statement not *ast.EnumStatement. .got=%T",
+		"let [x = 1, y = x + 1..] = p;",
+		"match  [a = 1 + true] = [];",
+	}
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: no errors", input)
+		}
+	}
+}
+
+func TestParsingStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y }", "struct Point { x, y }"},
+		{"struct Point { x, y, };", "struct Point { x, y }"},
+		{"struct Empty {}", "struct Empty {  }"},
+		{
+			"impl Point { fn norm(self) { self.x * self.x } fn zero() { Point(0, 0) } }",
+			"impl Point { fn norm(self)((self.x) * (self.x)) fn zero()Point(0, 0) }",
+		},
+		{"p.x.y", "((p.x).y)"},
+		{"p.norm(1)", "(p.norm)(1)"},
+		{"p.x[0] + a.b", "(((p.x)[0]) + (a.b))"},
+		{"p.x = a.b + 1", "((p.x) = ((a.b) + 1))"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestInvalidStructs(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"struct { x }", "expected next token to be IDENT, got { instead"},
+		{"struct P { 1 }", "expected next token to be IDENT, got INT instead"},
+		{"impl P { let x = 1 }", "expected next token to be FUNCTION, got LET instead"},
+		{"p.1", "expected next token to be IDENT, got INT instead"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expectedError {
+			t.Errorf("input %q: expected error %q, got=%v", tt.input, tt.expectedError, p.Errors())
+		}
+	}
+}
+
+func TestParsingEnums(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum Result { Ok(value), Err(msg), None }", "enum Result { Ok(value), Err(msg), None }"},
+		{"enum Color { Red, Green, };", "enum Color { Red, Green }"},
+		{"enum Shape { Rect(w, h,), Empty() }", "enum Shape { Rect(w, h), Empty }"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: program.Statements does not contain 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+		if _, ok := program.Statements[0].(*ast.EnumStatement); !ok {
+			t.Fatalf("statement not *ast.EnumStatement. got=%T", program.Statements[0])
+		}
+		if program.String() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestInvalidEnums(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"enum { A }", "expected next token to be IDENT, got { instead"},
+		{"enum E { A B }", "expected next token to be ,, got IDENT instead"},
+		{"enum E { A(1) }", "expected next token to be IDENT, got INT instead"},
+		{"enum E { A(x", "expected next token to be ,, got EOF instead"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expectedError {
+			t.Errorf("input %q: expected error %q, got=%v", tt.input, tt.expectedError, p.Errors())
+		}
+	}
+}
+
+func TestParsingInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedParts int
+		expected      string
+	}{
+		{`"Hello ${name}!"`, 3, "Hello ${name}!"},
+		{`"${a}${b}"`, 2, "${a}${b}"},
+		{`"${count + 1} items"`, 2, "${(count + 1)} items"},
+		{`"x ${"y ${z}"}"`, 2, "x ${y ${z}}"},
+		{`"${f({"a": 1})}"`, 1, "${f({a:1})}"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		str, ok := stmt.Expression.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+		}
+		if len(str.Parts) != tt.expectedParts {
+			t.Errorf("input %q: expected %d parts, got=%d", tt.input, tt.expectedParts, len(str.Parts))
+		}
+		if str.String() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, str.String())
+		}
+	}
+}
+
+func TestInvalidInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`"a ${} b"`, "empty expression in string interpolation"},
+		{`"a ${x y} b"`, "expected next token to be INTERP_END, got IDENT instead"},
+		{`"a ${x`, "expected next token to be INTERP_END, got EOF instead"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expectedError {
+			t.Errorf("input %q: expected error %q, got=%v", tt.input, tt.expectedError, p.Errors())
+		}
+	}
+}
+
+func TestReturnAtEndOfInput(t *testing.T) {
+	p := New(lexer.New("return"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected an error for a return without value")
+	}
+}
@@ -0,0 +1,240 @@
+package parser
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/token2"
+	"unicode"
+)
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+	if !p.expectPeek(token2.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+	if !p.expectPeek(token2.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token2.LBRACE) {
+		return nil
+	}
+	for !p.peekTokenIs(token2.RBRACE) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+		// the comma after a block body is optional
+		blockBody := p.curTokenIs(token2.RBRACE)
+		if p.peekTokenIs(token2.COMMA) {
+			p.nextToken()
+		} else if !blockBody && !p.peekTokenIs(token2.RBRACE) {
+			p.peekError(token2.COMMA)
+			return nil
+		}
+	}
+	if !p.expectPeek(token2.RBRACE) {
+		return nil
+	}
+	return expression
+}
+
+// parse `pattern if guard => body`, body is an expression or a { block }.
+// A hash literal body has to be wrapped in parentheses.
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parsePattern()}
+	if arm.Pattern == nil {
+		return nil
+	}
+	if p.peekTokenIs(token2.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token2.ARROW) {
+		return nil
+	}
+	p.nextToken()
+	if p.curTokenIs(token2.LBRACE) {
+		arm.Body = p.parseBlockStatement()
+		return arm
+	}
+	statement := &ast.ExpressionStatement{Token: p.curToken}
+	statement.Expression = p.parseExpression(LOWEST)
+	arm.Body = &ast.BlockStatement{Token: statement.Token, Statements: []ast.Statement{statement}}
+	return arm
+}
+
+// parsePattern parses the pattern starting at the current token and leaves
+// the last token of the pattern as the current one
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token2.IDENT:
+		if p.curToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		if unicode.IsUpper(rune(p.curToken.Literal[0])) {
+			return p.parseVariantPattern()
+		}
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token2.INT, token2.STRING, token2.TRUE, token2.FALSE, token2.MINUS:
+		token := p.curToken
+		value := p.parsePatternLiteral()
+		if value == nil {
+			return nil
+		}
+		return &ast.LiteralPattern{Token: token, Value: value}
+	case token2.LBRACKET:
+		return p.parseArrayPattern()
+	case token2.LBRACE:
+		return p.parseHashPattern()
+	default:
+		p.patternError()
+		return nil
+	}
+}
+
+// an integer (optionally negative), string or boolean literal
+func (p *Parser) parsePatternLiteral() ast.Expression {
+	switch p.curToken.Type {
+	case token2.INT:
+		return p.parseIntegerLiteral()
+	case token2.STRING:
+		return p.parseStringLiteral()
+	case token2.TRUE, token2.FALSE:
+		return p.parseBoolean()
+	case token2.MINUS:
+		expression := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
+		if !p.expectPeek(token2.INT) {
+			return nil
+		}
+		expression.Right = p.parseIntegerLiteral()
+		if expression.Right == nil {
+			return nil
+		}
+		return expression
+	default:
+		p.patternError()
+		return nil
+	}
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+	for !p.peekTokenIs(token2.RBRACKET) {
+		p.nextToken()
+		if p.curTokenIs(token2.ELLIPSIS) {
+			// a bare ... ignores the remaining elements
+			pattern.Rest = &ast.WildcardPattern{Token: p.curToken}
+			if p.peekTokenIs(token2.IDENT) {
+				p.nextToken()
+				pattern.Rest = p.parsePattern()
+			}
+			// the rest pattern must be the last one
+			break
+		}
+		element := p.parseElementPattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+		if !p.peekTokenIs(token2.RBRACKET) && !p.expectPeek(token2.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token2.RBRACKET) {
+		return nil
+	}
+	return pattern
+}
+
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+	for !p.peekTokenIs(token2.RBRACE) {
+		p.nextToken()
+		var key ast.Expression
+		var value ast.Pattern
+		if p.curTokenIs(token2.IDENT) {
+			// a bare name is the string key, alone it also names the binding
+			key = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.peekTokenIs(token2.COLON) {
+				value = p.parseElementPattern()
+			}
+		} else {
+			key = p.parsePatternLiteral()
+		}
+		if key == nil {
+			return nil
+		}
+		if value == nil {
+			if !p.expectPeek(token2.COLON) {
+				return nil
+			}
+			p.nextToken()
+			value = p.parseElementPattern()
+		}
+		if value == nil {
+			return nil
+		}
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+		if !p.peekTokenIs(token2.RBRACE) && !p.expectPeek(token2.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token2.RBRACE) {
+		return nil
+	}
+	return pattern
+}
+
+// Ok(v, _) or None, the payload patterns follow the variant name in
+// parentheses
+func (p *Parser) parseVariantPattern() ast.Pattern {
+	pattern := &ast.VariantPattern{
+		Token: p.curToken,
+		Name:  &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+	}
+	if !p.peekTokenIs(token2.LPAREN) {
+		return pattern
+	}
+	p.nextToken()
+	pattern.Elements = []ast.Pattern{}
+	for !p.peekTokenIs(token2.RPAREN) {
+		p.nextToken()
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+		if !p.peekTokenIs(token2.RPAREN) && !p.expectPeek(token2.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	return pattern
+}
+
+// an element of an array or hash pattern may carry a default value
+func (p *Parser) parseElementPattern() ast.Pattern {
+	pattern := p.parsePattern()
+	if pattern == nil || !p.peekTokenIs(token2.ASSIGN) {
+		return pattern
+	}
+	p.nextToken()
+	withDefault := &ast.DefaultPattern{Token: p.curToken, Pattern: pattern}
+	p.nextToken()
+	withDefault.Default = p.parseExpression(LOWEST)
+	if withDefault.Default == nil {
+		return nil
+	}
+	return withDefault
+}
+
+func (p *Parser) patternError() {
+	msg := fmt.Sprintf("unexpected %s in pattern", p.curToken.Type)
+	p.errorAt(p.curToken, msg)
+}
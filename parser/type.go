@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/token2"
+)
+
+// parseTypeAnnotation parses `: type` when it follows the current token, it
+// returns nil without an annotation and ok is false on a syntax error
+func (p *Parser) parseTypeAnnotation() (ast.Type, bool) {
+	if !p.peekTokenIs(token2.COLON) {
+		return nil, true
+	}
+	p.nextToken()
+	p.nextToken()
+	t := p.parseType()
+	return t, t != nil
+}
+
+// parseType parses the type starting at the current token and leaves the last
+// token of the type as the current one
+func (p *Parser) parseType() ast.Type {
+	switch p.curToken.Type {
+	case token2.IDENT:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token2.LBRACKET:
+		t := &ast.ArrayType{Token: p.curToken}
+		p.nextToken()
+		if t.Element = p.parseType(); t.Element == nil || !p.expectPeek(token2.RBRACKET) {
+			return nil
+		}
+		return t
+	case token2.LBRACE:
+		t := &ast.HashType{Token: p.curToken}
+		p.nextToken()
+		if t.Key = p.parseType(); t.Key == nil || !p.expectPeek(token2.COLON) {
+			return nil
+		}
+		p.nextToken()
+		if t.Value = p.parseType(); t.Value == nil || !p.expectPeek(token2.RBRACE) {
+			return nil
+		}
+		return t
+	case token2.FUNCTION:
+		t := &ast.FunctionType{Token: p.curToken, Parameters: []ast.Type{}}
+		if !p.expectPeek(token2.LPAREN) {
+			return nil
+		}
+		for !p.peekTokenIs(token2.RPAREN) {
+			p.nextToken()
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+			t.Parameters = append(t.Parameters, param)
+			if !p.peekTokenIs(token2.RPAREN) && !p.expectPeek(token2.COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+		if p.peekTokenIs(token2.RARROW) {
+			p.nextToken()
+			p.nextToken()
+			if t.Return = p.parseType(); t.Return == nil {
+				return nil
+			}
+		}
+		return t
+	default:
+		p.errorAt(p.curToken, fmt.Sprintf("expected type, got %s instead", p.curToken.Type))
+		return nil
+	}
+}
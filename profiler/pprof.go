@@ -0,0 +1,185 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"fmt"
+	"interpreter/evaluator"
+	"io"
+)
+
+// the fields of profile.proto that are written, see
+// https://github.com/google/pprof/blob/main/proto/profile.proto
+const (
+	profileSampleType  = 1
+	profileSample      = 2
+	profileLocation    = 4
+	profileFunction    = 5
+	profileStringTable = 6
+	profileDuration    = 10
+	profilePeriodType  = 11
+	profilePeriod      = 12
+	profileDefaultType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocation = 1
+	sampleValue    = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunction = 1
+	lineLine     = 2
+
+	functionID        = 1
+	functionName      = 2
+	functionSystem    = 3
+	functionFilename  = 4
+	functionStartLine = 5
+)
+
+// buffer encodes protocol buffer messages
+type buffer struct {
+	data []byte
+}
+
+func (b *buffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *buffer) key(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// int writes a varint field, zero is left out as the default
+func (b *buffer) int(field int, x int64) {
+	if x == 0 {
+		return
+	}
+	b.key(field, 0)
+	b.varint(uint64(x))
+}
+
+func (b *buffer) bytes(field int, data []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+// packed writes a repeated varint field as one packed field
+func (b *buffer) packed(field int, xs []int64) {
+	var values buffer
+	for _, x := range xs {
+		values.varint(uint64(x))
+	}
+	b.bytes(field, values.data)
+}
+
+// message writes the message built by build as field
+func (b *buffer) message(field int, build func(*buffer)) {
+	var m buffer
+	build(&m)
+	b.bytes(field, m.data)
+}
+
+// Pprof writes p as a gzipped pprof profile. Every sample has the calls, the
+// time and the allocations of its stack, time is the default. Name is the
+// file of the program.
+func Pprof(w io.Writer, p *evaluator.Profile, name string) error {
+	table := []string{""}
+	index := make(map[string]int64)
+	str := func(s string) int64 {
+		if s == "" {
+			return 0
+		}
+		i, ok := index[s]
+		if !ok {
+			i = int64(len(table))
+			index[s] = i
+			table = append(table, s)
+		}
+		return i
+	}
+	valueType := func(kind, unit string) func(*buffer) {
+		k, u := str(kind), str(unit)
+		return func(b *buffer) {
+			b.int(valueTypeType, k)
+			b.int(valueTypeUnit, u)
+		}
+	}
+
+	var out buffer
+	out.message(profileSampleType, valueType("calls", "count"))
+	out.message(profileSampleType, valueType("time", "nanoseconds"))
+	out.message(profileSampleType, valueType("allocations", "count"))
+
+	var total int64
+	for _, sample := range p.Samples() {
+		// pprof wants the innermost location first
+		locations := make([]int64, len(sample.Stack))
+		for i, fn := range sample.Stack {
+			locations[len(locations)-1-i] = int64(fn) + 1
+		}
+		values := []int64{sample.Calls, int64(sample.Time), sample.Allocations}
+		total += int64(sample.Time)
+		out.message(profileSample, func(b *buffer) {
+			b.packed(sampleLocation, locations)
+			b.packed(sampleValue, values)
+		})
+	}
+	// a location and a function for each function, with the same id
+	for i, fn := range p.Functions {
+		id, line := int64(i)+1, int64(fn.Line)
+		out.message(profileLocation, func(b *buffer) {
+			b.int(locationID, id)
+			b.message(locationLine, func(b *buffer) {
+				b.int(lineFunction, id)
+				b.int(lineLine, line)
+			})
+		})
+		filename := ""
+		if fn.Line > 0 {
+			filename = name
+		}
+		n, file := str(pprofName(fn)), str(filename)
+		out.message(profileFunction, func(b *buffer) {
+			b.int(functionID, id)
+			b.int(functionName, n)
+			b.int(functionSystem, n)
+			b.int(functionFilename, file)
+			b.int(functionStartLine, line)
+		})
+	}
+	out.int(profileDuration, total)
+	out.message(profilePeriodType, valueType("time", "nanoseconds"))
+	out.int(profilePeriod, 1)
+	out.int(profileDefaultType, str("time"))
+	// the strings last, all of them are known now
+	for _, s := range table {
+		out.bytes(profileStringTable, []byte(s))
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(out.data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// pprofName is the name of fn without angle brackets, pprof takes them for
+// C++ template arguments and drops them. Anonymous functions are told apart
+// by their line.
+func pprofName(fn evaluator.ProfileFunction) string {
+	switch fn.Name {
+	case "<main>":
+		return "main"
+	case "<anonymous>":
+		return fmt.Sprintf("anonymous@%d", fn.Line)
+	}
+	return fn.Name
+}
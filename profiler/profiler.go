@@ -0,0 +1,86 @@
+/*
+	Report the profiles of Monkey programs.
+		- the evaluator records every call of a Monkey function or builtin
+		  in the Profile of its evaluator.Context, with the stack it was
+		  called from, the time spent in it and the values it created
+		- Flat adds them up by function, the total time of a function is the
+		  time of every stack it is on, counted once for a recursive call
+		- Pprof writes them as a gzipped pprof protobuf, go tool pprof draws
+		  call graphs and flame graphs of the Monkey functions from it
+*/
+package profiler
+
+import (
+	"fmt"
+	"interpreter/evaluator"
+	"io"
+	"sort"
+	"time"
+)
+
+// Function is the profile of one function summed over its stacks
+type Function struct {
+	evaluator.ProfileFunction
+	Calls       int64
+	Self        time.Duration
+	Total       time.Duration
+	Allocations int64
+}
+
+// Functions sums the samples of p by function, the ones with the most time
+// of their own first
+func Functions(p *evaluator.Profile) []*Function {
+	functions := make([]*Function, len(p.Functions))
+	for i, fn := range p.Functions {
+		functions[i] = &Function{ProfileFunction: fn}
+	}
+	for _, sample := range p.Samples() {
+		leaf := functions[sample.Stack[len(sample.Stack)-1]]
+		leaf.Calls += sample.Calls
+		leaf.Self += sample.Time
+		leaf.Allocations += sample.Allocations
+		seen := make(map[int]bool)
+		for _, index := range sample.Stack {
+			if !seen[index] {
+				seen[index] = true
+				functions[index].Total += sample.Time
+			}
+		}
+	}
+	sort.SliceStable(functions, func(i, j int) bool {
+		a, b := functions[i], functions[j]
+		if a.Self != b.Self {
+			return a.Self > b.Self
+		}
+		return a.Total > b.Total
+	})
+	return functions
+}
+
+// Flat writes a line for each function of p, the ones with the most time of
+// their own first. Name is the file of the program.
+func Flat(w io.Writer, p *evaluator.Profile, name string) {
+	functions := Functions(p)
+	var total time.Duration
+	for _, sample := range p.Samples() {
+		total += sample.Time
+	}
+	percent := func(d time.Duration) float64 {
+		if total == 0 {
+			return 0
+		}
+		return 100 * float64(d) / float64(total)
+	}
+	fmt.Fprintf(w, "%10s %12s %7s %12s %7s %10s  %s\n", "calls", "self", "self%", "total", "total%", "allocs", "function")
+	for _, fn := range functions {
+		fmt.Fprintf(w, "%10d %12s %6.1f%% %12s %6.1f%% %10d  %s\n",
+			fn.Calls, fn.Self, percent(fn.Self), fn.Total, percent(fn.Total), fn.Allocations, where(fn.ProfileFunction, name))
+	}
+}
+
+func where(fn evaluator.ProfileFunction, name string) string {
+	if fn.Line == 0 {
+		return fn.Name
+	}
+	return fmt.Sprintf("%s (%s:%d)", fn.Name, name, fn.Line)
+}
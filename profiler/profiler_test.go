@@ -0,0 +1,164 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"interpreter/resolver"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const src = `fn fib(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }
+let run = fn(f) { let r = f(); r };
+run(fn() { fib(6) });
+len("x")
+`
+
+func profile(t *testing.T) *evaluator.Profile {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	r := resolver.New(evaluator.NewGlobalScope())
+	r.Resolve(program)
+	if len(p.Errors()) > 0 || len(r.Errors()) > 0 {
+		t.Fatalf("errors: %v %v", p.Errors(), r.Errors())
+	}
+	profile := evaluator.NewProfile()
+	evaluator.Eval(program, evaluator.NewEnvironment(&evaluator.Context{Profile: profile}))
+	profile.Stop()
+	return profile
+}
+
+func TestFunctions(t *testing.T) {
+	p := profile(t)
+	byName := make(map[string]*Function)
+	for _, fn := range Functions(p) {
+		byName[fn.Name] = fn
+	}
+	var total time.Duration
+	for _, sample := range p.Samples() {
+		total += sample.Time
+	}
+	tests := []struct {
+		name   string
+		calls  int64
+		allocs int64
+	}{
+		{"<main>", 1, 4},
+		{"fib", 25, 110},
+		{"len", 1, 0},
+	}
+	for _, tt := range tests {
+		fn := byName[tt.name]
+		if fn == nil {
+			t.Errorf("no function %s", tt.name)
+			continue
+		}
+		if fn.Calls != tt.calls || fn.Allocations != tt.allocs {
+			t.Errorf("%s wrong. got calls=%d allocs=%d", tt.name, fn.Calls, fn.Allocations)
+		}
+		if fn.Self > fn.Total || fn.Total > total {
+			t.Errorf("%s times wrong. got self=%s total=%s of %s", tt.name, fn.Self, fn.Total, total)
+		}
+	}
+	// the program is on every stack, recursive calls count once
+	if byName["<main>"].Total != total {
+		t.Errorf("total of <main> wrong. got=%s, want=%s", byName["<main>"].Total, total)
+	}
+
+	var out bytes.Buffer
+	Flat(&out, p, "fib.mk")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 || !strings.HasSuffix(lines[0], "allocs  function") {
+		t.Fatalf("flat report wrong. got=\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "  fib (fib.mk:1)\n") || !strings.Contains(out.String(), "  <anonymous> (fib.mk:3)\n") {
+		t.Errorf("flat report names wrong. got=\n%s", out.String())
+	}
+}
+
+// field is one field of a protocol buffer message
+type field struct {
+	number int
+	value  uint64 // of a varint
+	data   []byte // of a length delimited field
+}
+
+func varint(data []byte) (uint64, []byte) {
+	var x uint64
+	for shift := 0; ; shift += 7 {
+		b := data[0]
+		data = data[1:]
+		x |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return x, data
+		}
+	}
+}
+
+func fields(t *testing.T, data []byte) []field {
+	list := []field{}
+	for len(data) > 0 {
+		var key uint64
+		key, data = varint(data)
+		f := field{number: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.value, data = varint(data)
+		case 2:
+			var n uint64
+			n, data = varint(data)
+			f.data, data = data[:n], data[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		list = append(list, f)
+	}
+	return list
+}
+
+func TestPprof(t *testing.T) {
+	p := profile(t)
+	var out bytes.Buffer
+	if err := Pprof(&out, p, "fib.mk"); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := []string{}
+	samples, locations, functions := 0, 0, 0
+	for _, f := range fields(t, data) {
+		switch f.number {
+		case profileStringTable:
+			table = append(table, string(f.data))
+		case profileSample:
+			samples++
+			for _, value := range fields(t, f.data) {
+				if value.number == sampleValue && len(value.data) < 3 {
+					t.Errorf("sample without its 3 values: %v", value.data)
+				}
+			}
+		case profileLocation:
+			locations++
+		case profileFunction:
+			functions++
+		}
+	}
+	if samples != len(p.Samples()) || locations != len(p.Functions) || functions != len(p.Functions) {
+		t.Errorf("counts wrong. got samples=%d locations=%d functions=%d", samples, locations, functions)
+	}
+	want := []string{"", "calls", "count", "time", "nanoseconds", "allocations", "main", "anonymous@2", "fib.mk", "anonymous@3", "fib", "len"}
+	if strings.Join(table, ",") != strings.Join(want, ",") {
+		t.Errorf("string table wrong.\ngot= %q\nwant=%q", table, want)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"interpreter/lexer"
 	"interpreter/object2"
 	"interpreter/parser"
+	"interpreter/resolver"
 	"io"
 )
 
@@ -18,6 +19,8 @@ const PROMPT = ">>>"
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object2.NewEnvironment()
+	// globals defined on earlier lines stay visible to later ones
+	scope := evaluator.NewGlobalScope()
 	for {
 		fmt.Fprintf(out, PROMPT)
 		scanned := scanner.Scan()
@@ -34,6 +37,17 @@ func Start(in io.Reader, out io.Writer) {
 			printParserErrors(out, p.Errors())
 			continue
 		}
+		r := resolver.New(scope)
+		// redefining a global on a later line is fine at the prompt
+		r.AllowRedeclaration()
+		r.Resolve(program)
+		if len(r.Errors()) != 0 {
+			printErrors(out, "resolver", r.Errors())
+			continue
+		}
+		for _, msg := range r.Warnings() {
+			io.WriteString(out, "warning: "+msg+"\n")
+		}
 
 		evaluated := evaluator.Eval(program, env)
 		if evaluated != nil {
@@ -61,9 +75,13 @@ const MONKEY_FACE = `            __,__
 `
 
 func printParserErrors(out io.Writer, errors []string) {
+	printErrors(out, "parser", errors)
+}
+
+func printErrors(out io.Writer, stage string, errors []string) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
-	io.WriteString(out, " parser errors:\n")
+	io.WriteString(out, " "+stage+" errors:\n")
 	for _, msg := range errors {
 		io.WriteString(out, "\t"+msg+"\n")
 	}
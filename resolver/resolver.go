@@ -0,0 +1,566 @@
+/*
+	Resolve every identifier of a program to the environment slot it lives in
+	before evaluation, so the evaluator never looks a name up by string.
+		depth: how many function environments to walk out of
+		slot:  the index inside that environment
+	Undefined identifiers and identifiers used before their definition are
+	reported here instead of at run time, and so are a let declaring a name
+	twice in the same block and assignments to constants.
+	A match over the variants of an enum that leaves some variant out is
+	reported as a warning, see Warnings.
+*/
+package resolver
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/token2"
+	"strings"
+)
+
+// Scope holds the names bound in one function environment, or in the
+// global environment when outer is nil
+type Scope struct {
+	outer    *Scope
+	slots    map[string]int
+	size     int
+	builtins map[string]bool
+	// slots bound by const
+	consts map[int]bool
+	// slots bound to enum variants
+	variants map[int]*variant
+	// the identifier that declared each slot, the last one when a slot is
+	// declared in more than one block
+	decls map[int]*ast.Identifier
+	// names declared in the outermost block of the scope, kept with the scope
+	// so that REPL lines share it
+	declared *block
+	// references that were not found yet, settled when the scope is closed
+	pending []pending
+}
+
+// block holds the names declared directly in one block. Blocks do not get
+// environments of their own, a name declared in an if branch lives in the
+// function, but declaring it twice in the same block is an error.
+type block struct {
+	names map[string]bool
+}
+
+func newBlock() *block {
+	return &block{names: make(map[string]bool)}
+}
+
+// enum and variant describe an enum declaration, they are all the resolver
+// needs to tell whether a match covers every variant
+type enum struct {
+	name     string
+	variants []string
+}
+
+type variant struct {
+	enum *enum
+	name string
+}
+
+type pending struct {
+	ident  *ast.Identifier
+	origin *Scope
+	assign bool // the reference is the target of an assignment
+}
+
+func NewScope(outer *Scope) *Scope {
+	return &Scope{
+		outer:    outer,
+		slots:    make(map[string]int),
+		builtins: make(map[string]bool),
+		consts:   make(map[int]bool),
+		variants: make(map[int]*variant),
+		decls:    make(map[int]*ast.Identifier),
+		declared: newBlock(),
+	}
+}
+
+// DefineBuiltin makes name resolvable as a builtin (slot -1)
+func (s *Scope) DefineBuiltin(name string) {
+	s.builtins[name] = true
+}
+
+// Define binds name in this scope and returns its slot, a name that is
+// already bound keeps its slot
+func (s *Scope) Define(name string) int {
+	if slot, ok := s.slots[name]; ok {
+		return slot
+	}
+	slot := s.size
+	s.slots[name] = slot
+	s.size++
+	return slot
+}
+
+// redefine binds name to a fresh slot, closures that captured the old
+// slot keep seeing the old binding
+func (s *Scope) redefine(name string) int {
+	slot := s.size
+	s.slots[name] = slot
+	s.size++
+	return slot
+}
+
+// find name walking outwards, builtins come last and have no owner
+func (s *Scope) lookup(name string) (depth int, slot int, owner *Scope, ok bool) {
+	for scope := s; scope != nil; scope = scope.outer {
+		if slot, ok := scope.slots[name]; ok {
+			return depth, slot, scope, true
+		}
+		if scope.builtins[name] {
+			return 0, -1, nil, true
+		}
+		depth++
+	}
+	return 0, 0, nil, false
+}
+
+type Resolver struct {
+	scope     *Scope
+	block     *block
+	redeclare bool
+	errors    []string
+	warnings  []string
+	// the token each error and warning was found at
+	errorTokens   []token2.Token
+	warningTokens []token2.Token
+	// the declaration of every identifier resolved
+	definitions map[*ast.Identifier]*ast.Identifier
+	// matches whose exhaustiveness is checked once every name is resolved
+	matches []matchCheck
+}
+
+// matchCheck keeps the scopes of the arms of a match, the names in the
+// patterns are resolved relative to them
+type matchCheck struct {
+	node   *ast.MatchExpression
+	scopes []*Scope
+}
+
+// New creates a resolver that binds top level names in scope. Keep the scope
+// around to resolve more programs against the same globals, like the REPL does.
+func New(scope *Scope) *Resolver {
+	return &Resolver{
+		scope:       scope,
+		errors:      []string{},
+		warnings:    []string{},
+		definitions: make(map[*ast.Identifier]*ast.Identifier),
+	}
+}
+
+func (r *Resolver) Errors() []string {
+	return r.errors
+}
+
+// Warnings are problems that do not stop the program from running
+func (r *Resolver) Warnings() []string {
+	return r.warnings
+}
+
+// ErrorTokens returns the token each of Errors was found at
+func (r *Resolver) ErrorTokens() []token2.Token {
+	return r.errorTokens
+}
+
+// WarningTokens returns the token each of Warnings was found at
+func (r *Resolver) WarningTokens() []token2.Token {
+	return r.warningTokens
+}
+
+// Definitions maps every identifier bound to a slot to the identifier that
+// declared it, a declaration maps to itself. Builtins and names that were
+// not found are left out.
+func (r *Resolver) Definitions() map[*ast.Identifier]*ast.Identifier {
+	return r.definitions
+}
+
+// AllowRedeclaration lets a global be declared again, binding the name to a
+// new slot instead of reporting an error. Meant for the REPL, where
+// redefining a name on a later line is common.
+func (r *Resolver) AllowRedeclaration() {
+	r.redeclare = true
+}
+
+// Resolve fills in Depth and Slot of every identifier in node
+func (r *Resolver) Resolve(node ast.Node) {
+	r.block = r.scope.declared
+	r.resolve(node)
+	// names not found in a nested scope wait in the scopes around it
+	for scope := r.scope; scope != nil; scope = scope.outer {
+		r.closeScope(scope)
+	}
+	// unresolved names would make the check guess
+	if len(r.errors) == 0 {
+		for _, check := range r.matches {
+			r.checkExhaustive(check)
+		}
+	}
+	r.matches = nil
+}
+
+func (r *Resolver) resolve(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Program:
+		r.resolveStatements(node.Statements)
+	case *ast.BlockStatement:
+		outer := r.block
+		r.block = newBlock()
+		r.resolveStatements(node.Statements)
+		r.block = outer
+	case *ast.FunctionDeclaration:
+		// the name was declared when the block was entered
+		r.resolveFunction(node.Function)
+	case *ast.StructStatement:
+		r.declare(node.Name, false)
+	case *ast.EnumStatement:
+		r.declare(node.Name, false)
+		decl := &enum{name: node.Name.Value}
+		for _, v := range node.Variants {
+			decl.variants = append(decl.variants, v.Name.Value)
+		}
+		for _, v := range node.Variants {
+			r.declare(v.Name, false)
+			r.scope.variants[v.Name.Slot] = &variant{enum: decl, name: v.Name.Value}
+		}
+	case *ast.ImplStatement:
+		r.resolveIdentifier(node.Name, false)
+		for _, method := range node.Methods {
+			r.resolveFunction(method.Function)
+		}
+	case *ast.ExpressionStatement:
+		r.resolveExpression(node.Expression)
+	case *ast.ReturnStatement:
+		r.resolveExpression(node.ReturnValue)
+	case *ast.LetStatement:
+		// the name is bound after the value, so `let x = x;` is an error
+		// while a function can still refer to itself
+		r.resolveExpression(node.Value)
+		constant := node.Token.Type == token2.CONST
+		if node.Pattern != nil {
+			r.declarePattern(node.Pattern, map[string]bool{}, constant)
+		} else {
+			r.declare(node.Name, constant)
+		}
+	case ast.Expression:
+		r.resolveExpression(node)
+	}
+}
+
+// function declarations are hoisted: their names are bound before the
+// statements of the block are resolved
+func (r *Resolver) resolveStatements(statements []ast.Statement) {
+	for _, statement := range statements {
+		if declaration, ok := statement.(*ast.FunctionDeclaration); ok {
+			r.declare(declaration.Name, false)
+		}
+	}
+	for _, statement := range statements {
+		r.resolve(statement)
+	}
+}
+
+func (r *Resolver) resolveExpression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		r.resolveIdentifier(exp, false)
+	case *ast.AssignExpression:
+		r.resolveExpression(exp.Value)
+		if target, ok := exp.Target.(*ast.Identifier); ok {
+			r.resolveIdentifier(target, true)
+		} else {
+			r.resolveExpression(exp.Target)
+		}
+	case *ast.PrefixExpression:
+		r.resolveExpression(exp.Right)
+	case *ast.InfixExpression:
+		r.resolveExpression(exp.Left)
+		r.resolveExpression(exp.Right)
+	case *ast.IfExpression:
+		r.resolveExpression(exp.Condition)
+		r.resolve(exp.Consequence)
+		if exp.Alternative != nil {
+			r.resolve(exp.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		r.resolveFunction(exp)
+	case *ast.CallExpression:
+		r.resolveExpression(exp.Function)
+		r.resolveExpressions(exp.Arguments)
+	case *ast.ArrayLiteral:
+		r.resolveExpressions(exp.Elements)
+	case *ast.InterpolatedString:
+		r.resolveExpressions(exp.Parts)
+	case *ast.IndexExpression:
+		r.resolveExpression(exp.Left)
+		r.resolveExpression(exp.Index)
+	case *ast.SliceExpression:
+		r.resolveExpressions([]ast.Expression{exp.Left, exp.Start, exp.End, exp.Step})
+	case *ast.RangeExpression:
+		r.resolveExpression(exp.Start)
+		r.resolveExpression(exp.End)
+	case *ast.HashLiteral:
+		for _, key := range exp.Keys {
+			r.resolveExpression(key)
+			r.resolveExpression(exp.Pairs[key])
+		}
+	case *ast.DotExpression:
+		r.resolveExpression(exp.Left)
+	case *ast.SpreadExpression:
+		r.resolveExpression(exp.Value)
+	case *ast.KeywordArgument:
+		r.resolveExpression(exp.Value)
+	case *ast.MatchExpression:
+		r.resolveExpression(exp.Value)
+		check := matchCheck{node: exp}
+		for _, arm := range exp.Arms {
+			check.scopes = append(check.scopes, r.resolveMatchArm(arm))
+		}
+		r.matches = append(r.matches, check)
+	}
+}
+
+// every arm gets a scope of its own, like a function body, because the
+// evaluator binds the names of the pattern in a fresh enclosed environment
+func (r *Resolver) resolveMatchArm(arm *ast.MatchArm) *Scope {
+	outerBlock := r.block
+	r.scope = NewScope(r.scope)
+	scope := r.scope
+	r.block = r.scope.declared
+	r.declarePattern(arm.Pattern, map[string]bool{}, false)
+	if arm.Guard != nil {
+		r.resolveExpression(arm.Guard)
+	}
+	r.resolveStatements(arm.Body.Statements)
+	r.closeScope(r.scope)
+	r.scope = r.scope.outer
+	r.block = outerBlock
+	return scope
+}
+
+// declare the names bound by pattern, seen catches a name bound twice.
+// Defaults are resolved on the way, so they see the names bound before them.
+func (r *Resolver) declarePattern(pattern ast.Pattern, seen map[string]bool, constant bool) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if seen[pattern.Value] {
+			r.errorAt(pattern.Token, "identifier bound more than once in pattern: %s", pattern.Value)
+			return
+		}
+		seen[pattern.Value] = true
+		r.declare(pattern, constant)
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			r.declarePattern(element, seen, constant)
+		}
+		if pattern.Rest != nil {
+			r.declarePattern(pattern.Rest, seen, constant)
+		}
+	case *ast.HashPattern:
+		for _, value := range pattern.Values {
+			r.declarePattern(value, seen, constant)
+		}
+	case *ast.VariantPattern:
+		r.resolveIdentifier(pattern.Name, false)
+		for _, element := range pattern.Elements {
+			r.declarePattern(element, seen, constant)
+		}
+	case *ast.DefaultPattern:
+		r.resolveExpression(pattern.Default)
+		r.declarePattern(pattern.Pattern, seen, constant)
+	}
+}
+
+func (r *Resolver) resolveExpressions(exps []ast.Expression) {
+	for _, exp := range exps {
+		if exp != nil {
+			r.resolveExpression(exp)
+		}
+	}
+}
+
+func (r *Resolver) resolveFunction(fn *ast.FunctionLiteral) {
+	outerBlock := r.block
+	r.scope = NewScope(r.scope)
+	r.block = r.scope.declared
+	// a default value sees the parameters before it
+	for i, param := range fn.Parameters {
+		if i < len(fn.Defaults) && fn.Defaults[i] != nil {
+			r.resolveExpression(fn.Defaults[i])
+		}
+		r.declare(param, false)
+	}
+	if fn.Rest != nil {
+		r.declare(fn.Rest, false)
+	}
+	r.resolveStatements(fn.Body.Statements)
+	r.closeScope(r.scope)
+	r.scope = r.scope.outer
+	r.block = outerBlock
+}
+
+func (r *Resolver) declare(ident *ast.Identifier, constant bool) {
+	ident.Depth = 0
+	switch {
+	case !r.block.names[ident.Value]:
+		ident.Slot = r.scope.Define(ident.Value)
+	case r.redeclare && r.scope.outer == nil && r.block == r.scope.declared:
+		ident.Slot = r.scope.redefine(ident.Value)
+	default:
+		r.errorAt(ident.Token, "identifier already declared: %s", ident.Value)
+		ident.Slot = r.scope.Define(ident.Value)
+	}
+	r.scope.decls[ident.Slot] = ident
+	r.definitions[ident] = ident
+	r.block.names[ident.Value] = true
+	r.scope.consts[ident.Slot] = constant
+	delete(r.scope.variants, ident.Slot)
+}
+
+// assign is true when ident is the target of an assignment
+func (r *Resolver) resolveIdentifier(ident *ast.Identifier, assign bool) {
+	depth, slot, owner, ok := r.scope.lookup(ident.Value)
+	if !ok {
+		// may still be defined later, e.g. a function calling itself or
+		// another function declared after it
+		r.scope.pending = append(r.scope.pending, pending{ident: ident, origin: r.scope, assign: assign})
+		return
+	}
+	if assign {
+		r.checkAssignable(ident, owner, slot)
+	}
+	ident.Depth = depth
+	ident.Slot = slot
+	if owner != nil {
+		r.definitions[ident] = owner.decls[slot]
+	}
+}
+
+func (r *Resolver) checkAssignable(ident *ast.Identifier, owner *Scope, slot int) {
+	switch {
+	case owner == nil:
+		r.errorAt(ident.Token, "cannot assign to builtin: %s", ident.Value)
+	case owner.consts[slot]:
+		r.errorAt(ident.Token, "cannot assign to constant: %s", ident.Value)
+	}
+}
+
+// settle the references that were not found while scope was being resolved
+func (r *Resolver) closeScope(scope *Scope) {
+	unresolved := scope.pending
+	scope.pending = nil
+	for _, p := range unresolved {
+		slot, ok := scope.slots[p.ident.Value]
+		switch {
+		case ok && p.origin == scope:
+			r.errorAt(p.ident.Token, "identifier used before definition: %s", p.ident.Value)
+		case ok:
+			// referenced from a nested function, which runs after the definition
+			if p.assign {
+				r.checkAssignable(p.ident, scope, slot)
+			}
+			p.ident.Depth = distance(p.origin, scope)
+			p.ident.Slot = slot
+			r.definitions[p.ident] = scope.decls[slot]
+		case scope.outer != nil:
+			scope.outer.pending = append(scope.outer.pending, p)
+		default:
+			r.errorAt(p.ident.Token, "identifier not found: %s", p.ident.Value)
+		}
+	}
+}
+
+// checkExhaustive warns about a match over the variants of one enum that
+// leaves some variants out. An arm counts when it has no guard and its
+// payload patterns match anything, a catch-all arm covers everything.
+func (r *Resolver) checkExhaustive(check matchCheck) {
+	var matched *enum
+	covered := map[string]bool{}
+	for i, arm := range check.node.Arms {
+		pattern, ok := arm.Pattern.(*ast.VariantPattern)
+		if !ok {
+			if arm.Guard == nil && irrefutable(arm.Pattern) {
+				return
+			}
+			continue
+		}
+		v := variantOf(check.scopes[i], pattern.Name)
+		switch {
+		case v == nil:
+			return
+		case matched == nil:
+			matched = v.enum
+		case matched != v.enum:
+			return
+		}
+		if arm.Guard != nil {
+			continue
+		}
+		all := true
+		for _, element := range pattern.Elements {
+			all = all && irrefutable(element)
+		}
+		if all {
+			covered[v.name] = true
+		}
+	}
+	if matched == nil {
+		return
+	}
+	missing := []string{}
+	for _, name := range matched.variants {
+		if !covered[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		r.warnAt(check.node.Token, "non-exhaustive match over %s: missing %s", matched.name, strings.Join(missing, ", "))
+	}
+}
+
+// the variant ident was resolved to from scope, if it names one
+func variantOf(scope *Scope, ident *ast.Identifier) *variant {
+	if ident.Slot < 0 {
+		return nil
+	}
+	for i := 0; i < ident.Depth && scope != nil; i++ {
+		scope = scope.outer
+	}
+	if scope == nil {
+		return nil
+	}
+	return scope.variants[ident.Slot]
+}
+
+// a pattern that matches any value
+func irrefutable(pattern ast.Pattern) bool {
+	switch pattern.(type) {
+	case *ast.Identifier, *ast.WildcardPattern:
+		return true
+	default:
+		return false
+	}
+}
+
+// number of environments between from and to
+func distance(from *Scope, to *Scope) int {
+	depth := 0
+	for scope := from; scope != to; scope = scope.outer {
+		depth++
+	}
+	return depth
+}
+
+func (r *Resolver) errorAt(token token2.Token, format string, a ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, a...))
+	r.errorTokens = append(r.errorTokens, token)
+}
+
+func (r *Resolver) warnAt(token token2.Token, format string, a ...interface{}) {
+	r.warnings = append(r.warnings, fmt.Sprintf(format, a...))
+	r.warningTokens = append(r.warningTokens, token)
+}
@@ -0,0 +1,243 @@
+package resolver
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+// collect every identifier that is referenced (not declared) in the program
+func references(node ast.Node, out *[]*ast.Identifier) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			references(s, out)
+		}
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			references(s, out)
+		}
+	case *ast.LetStatement:
+		references(node.Value, out)
+	case *ast.ReturnStatement:
+		references(node.ReturnValue, out)
+	case *ast.ExpressionStatement:
+		references(node.Expression, out)
+	case *ast.Identifier:
+		*out = append(*out, node)
+	case *ast.InfixExpression:
+		references(node.Left, out)
+		references(node.Right, out)
+	case *ast.IfExpression:
+		references(node.Condition, out)
+		references(node.Consequence, out)
+	case *ast.FunctionLiteral:
+		references(node.Body, out)
+	case *ast.FunctionDeclaration:
+		references(node.Function.Body, out)
+	case *ast.CallExpression:
+		references(node.Function, out)
+		for _, a := range node.Arguments {
+			references(a, out)
+		}
+	}
+}
+
+func TestResolveSlots(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // name@depth:slot of each reference in source order
+	}{
+		{"let a = 1; let b = 2; a + b;", []string{"a@0:0", "b@0:1"}},
+		{"let a = 1; let f = fn(x, y) { a + y }; f(1, 2);", []string{"a@1:0", "y@0:1", "f@0:1"}},
+		{"let f = fn(x) { fn(y) { x + y } };", []string{"x@1:0", "y@0:0"}},
+		{"let f = fn(x) { let z = x; z };", []string{"x@0:0", "z@0:1"}},
+		// recursion and a reference to a global defined later
+		{"let f = fn() { f() + g() }; let g = fn() { 1 };", []string{"f@1:0", "g@1:1"}},
+		// builtins resolve to slot -1 unless shadowed
+		{"len([]); let len = 1; len;", []string{"len@0:-1", "len@0:0"}},
+		// declarations are hoisted to the top of their block
+		{"even(1); fn even(n) { odd(n) } fn odd(n) { even(n) }", []string{"even@0:0", "odd@1:1", "n@0:0", "even@1:0", "n@0:0"}},
+		{"fn f() { g(); fn g() { 1 } }", []string{"g@0:0"}},
+		// a name declared in an if branch belongs to the function
+		{"let f = fn() { if (true) { let x = 1; } x };", []string{"x@0:0"}},
+	}
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		r := New(NewScope(nil))
+		r.scope.DefineBuiltin("len")
+		r.Resolve(program)
+		if len(r.Errors()) != 0 {
+			t.Errorf("%q: resolver errors: %v", tt.input, r.Errors())
+			continue
+		}
+		var refs []*ast.Identifier
+		references(program, &refs)
+		if len(refs) != len(tt.expected) {
+			t.Errorf("%q: expected %d references, got=%d", tt.input, len(tt.expected), len(refs))
+			continue
+		}
+		for i, ref := range refs {
+			got := fmt.Sprintf("%s@%d:%d", ref.Value, ref.Depth, ref.Slot)
+			if got != tt.expected[i] {
+				t.Errorf("%q: reference %d. expected=%s, got=%s", tt.input, i, tt.expected[i], got)
+			}
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"foobar;", "identifier not found: foobar"},
+		{"let f = fn() { y };", "identifier not found: y"},
+		{"let a = b; let b = 1;", "identifier used before definition: b"},
+		{"let x = x;", "identifier used before definition: x"},
+		{"let f = fn() { let a = b; let b = 1; };", "identifier used before definition: b"},
+		{"let f = fn(x) { x }; x;", "identifier not found: x"},
+		{"match (1) { [a, a] => a }", "identifier bound more than once in pattern: a"},
+		{"let a = 1; let a = 2;", "identifier already declared: a"},
+		{"let f = fn(x) { let x = 1; };", "identifier already declared: x"},
+		{"fn f() {} let f = 1;", "identifier already declared: f"},
+		{"const a = 1; a = 2;", "cannot assign to constant: a"},
+		{"const [a, b] = [1, 2]; b = 2;", "cannot assign to constant: b"},
+		{"let f = fn() { c = 2; }; const c = 1;", "cannot assign to constant: c"},
+		{"len = 1;", "cannot assign to builtin: len"},
+		{"y = 1;", "identifier not found: y"},
+		{"match (1) { [a] => a, _ => a }", "identifier not found: a"},
+		{"struct P { x } let P = 1;", "identifier already declared: P"},
+		{"enum E { A, A }", "identifier already declared: A"},
+		{"match (1) { Missing(x) => x }", "identifier not found: Missing"},
+		{"impl Q { fn f() { 1 } }", "identifier not found: Q"},
+		{"struct P { x } impl P { fn f(self) { y } }", "identifier not found: y"},
+	}
+	for _, tt := range tests {
+		r := New(NewScope(nil))
+		r.scope.DefineBuiltin("len")
+		r.Resolve(parse(t, tt.input))
+		if len(r.Errors()) != 1 {
+			t.Errorf("%q: expected 1 error, got=%v", tt.input, r.Errors())
+			continue
+		}
+		if r.Errors()[0] != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, r.Errors()[0])
+		}
+	}
+}
+
+// the REPL resolves line after line against the same global scope
+func TestResolveIncrementalGlobals(t *testing.T) {
+	scope := NewScope(nil)
+	lines := []string{"let a = 1;", "let b = a + 1;", "let f = fn() { a + b };", "f();"}
+	for _, line := range lines {
+		r := New(scope)
+		r.Resolve(parse(t, line))
+		if len(r.Errors()) != 0 {
+			t.Fatalf("%q: resolver errors: %v", line, r.Errors())
+		}
+	}
+	r := New(scope)
+	r.Resolve(parse(t, "c;"))
+	if len(r.Errors()) != 1 {
+		t.Fatalf("expected an error for an undefined global. got=%v", r.Errors())
+	}
+	// a failed line does not leave pending references behind
+	r = New(scope)
+	r.Resolve(parse(t, "a;"))
+	if len(r.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", r.Errors())
+	}
+}
+
+// blocks share the environment of their function, a name may be declared
+// again in another block
+func TestRedeclarationInOtherBlocks(t *testing.T) {
+	input := "let f = fn(a) { if (a) { let x = 1; x } else { let x = 2; x } }; let x = 3;"
+	r := New(NewScope(nil))
+	r.Resolve(parse(t, input))
+	if len(r.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", r.Errors())
+	}
+}
+
+func TestAllowRedeclaration(t *testing.T) {
+	scope := NewScope(nil)
+	first := parse(t, "let a = 1;")
+	r := New(scope)
+	r.Resolve(first)
+	second := parse(t, "let a = a + 1;")
+	r = New(scope)
+	r.AllowRedeclaration()
+	r.Resolve(second)
+	if len(r.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", r.Errors())
+	}
+	let := second.Statements[0].(*ast.LetStatement)
+	old := let.Value.(*ast.InfixExpression).Left.(*ast.Identifier)
+	if old.Slot != 0 || let.Name.Slot != 1 {
+		t.Errorf("expected the new binding in a new slot. got old=%d, new=%d", old.Slot, let.Name.Slot)
+	}
+	// only globals may be redeclared
+	r = New(scope)
+	r.AllowRedeclaration()
+	r.Resolve(parse(t, "let f = fn() { let b = 1; let b = 2; };"))
+	if len(r.Errors()) != 1 || r.Errors()[0] != "identifier already declared: b" {
+		t.Errorf("expected a redeclaration error, got=%v", r.Errors())
+	}
+}
+
+func TestExhaustiveMatch(t *testing.T) {
+	enum := "enum Result { Ok(value), Err(msg), None } "
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{enum + "match (x) { Ok(v) => v, Err(m) => m, None => 0 }", []string{}},
+		{enum + "match (x) { Ok(v) => v, _ => 0 }", []string{}},
+		{enum + "match (x) { Ok(v) => v, other => other }", []string{}},
+		{enum + "match (x) { Ok(v) => v }", []string{"non-exhaustive match over Result: missing Err, None"}},
+		{enum + "match (x) { Ok(v) if v > 0 => v, Err(_) => 0, None => 0 }", []string{"non-exhaustive match over Result: missing Ok"}},
+		{enum + "match (x) { Ok(1) => 1, Err(_) => 0, None => 0 }", []string{"non-exhaustive match over Result: missing Ok"}},
+		{enum + "match (x) { Ok(v) => v, n if n == None => 0, Err(_) => 1 }", []string{"non-exhaustive match over Result: missing None"}},
+		// the match is checked wherever it appears, even before the enum
+		{"let f = fn(x) { match (x) { On => 1 } }; enum Switch { On, Off }", []string{"non-exhaustive match over Switch: missing Off"}},
+		{enum + "let f = fn(x) { match (x) { [a] => a, None => 0 } };", []string{"non-exhaustive match over Result: missing Ok, Err"}},
+		// a match that is not over an enum is not checked
+		{"match (x) { 1 => 1, [a] => a }", []string{}},
+	}
+	for _, tt := range tests {
+		r := New(NewScope(nil))
+		r.scope.Define("x")
+		r.Resolve(parse(t, tt.input))
+		if len(r.Errors()) != 0 {
+			t.Errorf("%q: unexpected errors %v", tt.input, r.Errors())
+			continue
+		}
+		if fmt.Sprint(r.Warnings()) != fmt.Sprint(tt.expected) {
+			t.Errorf("%q: expected warnings=%v, got=%v", tt.input, tt.expected, r.Warnings())
+		}
+	}
+}
+
+// a name still unknown when resolution ends in a nested scope is reported,
+// not left to read whatever sits in its slot
+func TestUnresolvedInNestedScope(t *testing.T) {
+	r := New(NewScope(NewScope(nil)))
+	r.Resolve(parse(t, "nope"))
+	if len(r.Errors()) != 1 || r.Errors()[0] != "identifier not found: nope" {
+		t.Errorf("expected an error for nope, got=%v", r.Errors())
+	}
+}
@@ -0,0 +1,135 @@
+package tester
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Report writes the results for people: every failure with its message and
+// output, with verbose the passed tests too, then a line for each file
+func Report(w io.Writer, results []*FileResult, verbose bool) {
+	for _, file := range results {
+		if file.Err != nil {
+			fmt.Fprintf(w, "FAIL\t%s\n%s\n", file.Path, indent(file.Err.Error()))
+			continue
+		}
+		for _, test := range file.Tests {
+			switch {
+			case !test.Passed():
+				fmt.Fprintf(w, "--- FAIL: %s (%s)\n%s\n", test.Name, seconds(test.Duration), indent(test.Failure))
+			case verbose:
+				fmt.Fprintf(w, "--- PASS: %s (%s)\n", test.Name, seconds(test.Duration))
+			default:
+				continue
+			}
+			if test.Output != "" {
+				fmt.Fprint(w, indent(strings.TrimSuffix(test.Output, "\n"))+"\n")
+			}
+		}
+		status := "ok"
+		if file.Failed() > 0 {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d passed, %d failed (%s)\n",
+			status, file.Path, len(file.Tests)-file.Failed(), file.Failed(), seconds(file.Duration))
+	}
+}
+
+func indent(text string) string {
+	return "    " + strings.ReplaceAll(text, "\n", "\n    ")
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.2fs", d.Seconds())
+}
+
+// the JUnit XML elements, as read by CI servers
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit writes the results as JUnit XML, a suite for each file. A file that
+// could not be run is a suite with one test case in error.
+func JUnit(w io.Writer, results []*FileResult) error {
+	suites := junitSuites{}
+	var total time.Duration
+	for _, file := range results {
+		total += file.Duration
+		suite := junitSuite{Name: file.Path, Time: junitTime(file.Duration)}
+		if file.Err != nil {
+			suite.Tests, suite.Errors = 1, 1
+			suite.Cases = append(suite.Cases, junitCase{
+				Name:      file.Path,
+				ClassName: file.Path,
+				Time:      junitTime(0),
+				Error:     &junitMessage{Message: firstLine(file.Err.Error()), Text: file.Err.Error()},
+			})
+		}
+		for _, test := range file.Tests {
+			c := junitCase{Name: test.Name, ClassName: file.Path, Time: junitTime(test.Duration), SystemOut: test.Output}
+			if !test.Passed() {
+				c.Failure = &junitMessage{Message: firstLine(test.Failure), Text: test.Failure}
+				suite.Failures++
+			}
+			suite.Tests++
+			suite.Cases = append(suite.Cases, c)
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Suites = append(suites.Suites, suite)
+	}
+	suites.Time = junitTime(total)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func firstLine(text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return text[:i]
+	}
+	return text
+}
@@ -0,0 +1,195 @@
+/*
+	Run the tests written in Monkey.
+		- a test file ends in _test.mk, its top-level calls of the test builtin
+		  name the tests: test("name", fn() { ... })
+		- every test runs in a fresh environment: the file is evaluated again
+		  for each one and only that test's function is called
+		- a test fails when its function returns an error, which the assert
+		  builtins return when they do not hold
+	Results are reported for people by Report and for CI by JUnit.
+*/
+package tester
+
+import (
+	"bytes"
+	"errors"
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object2"
+	"interpreter/parser"
+	"interpreter/resolver"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Suffix ends the name of every test file
+const Suffix = "_test.mk"
+
+// Result is the outcome of one test
+type Result struct {
+	Name string
+	// Failure is the error of a failed test, empty when it passed
+	Failure string
+	// Output is what the test printed
+	Output   string
+	Duration time.Duration
+}
+
+func (r *Result) Passed() bool {
+	return r.Failure == ""
+}
+
+// FileResult holds the results of the tests of one file
+type FileResult struct {
+	Path string
+	// Err is set when the file could not be read, parsed or evaluated, no
+	// test ran then
+	Err    error
+	Tests  []*Result
+	Source string
+	// Program is the parsed file, nil when it did not parse
+	Program  *ast.Program
+	Duration time.Duration
+}
+
+// Failed is the number of tests that failed
+func (f *FileResult) Failed() int {
+	failed := 0
+	for _, test := range f.Tests {
+		if !test.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Discover returns the test files named by paths in order. A directory is
+// searched recursively for files ending in Suffix, a file is taken as it is.
+func Discover(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		found := []string{}
+		err = filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && strings.HasSuffix(name, Suffix) {
+				found = append(found, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// RunFile reads and runs the tests of the file at path, see Run
+func RunFile(path string, filter *regexp.Regexp, coverage *evaluator.Coverage) *FileResult {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return &FileResult{Path: path, Err: err}
+	}
+	return Run(path, string(src), filter, coverage)
+}
+
+// Run runs the tests in src whose name matches filter, all of them when
+// filter is nil. Output of the program outside the tests is dropped. The
+// statements run are counted in coverage unless it is nil.
+func Run(path string, src string, filter *regexp.Regexp, coverage *evaluator.Coverage) *FileResult {
+	start := time.Now()
+	result := &FileResult{Path: path, Source: src}
+	defer func() { result.Duration = time.Since(start) }()
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		result.Err = errors.New(strings.Join(p.Errors(), "\n"))
+		return result
+	}
+	result.Program = program
+	r := resolver.New(evaluator.NewGlobalScope())
+	r.Resolve(program)
+	if len(r.Errors()) > 0 {
+		result.Err = errors.New(strings.Join(r.Errors(), "\n"))
+		return result
+	}
+
+	// a first run only collects the names and counts the top level
+	names := []string{}
+	ctx := &evaluator.Context{
+		Stdout:   io.Discard,
+		Coverage: coverage,
+		Test: func(name string, run func() object2.Object) object2.Object {
+			names = append(names, name)
+			return evaluator.NULL
+		},
+	}
+	if err, ok := evaluator.Eval(program, evaluator.NewEnvironment(ctx)).(*object2.Error); ok {
+		result.Err = errors.New(err.Message)
+		return result
+	}
+
+	for i, name := range names {
+		if filter != nil && !filter.MatchString(name) {
+			continue
+		}
+		test := &Result{Name: name}
+		// the file again in a new environment, only the function of this
+		// test is called and counted
+		seen := 0
+		ctx := &evaluator.Context{Stdout: io.Discard}
+		ctx.Test = func(name string, run func() object2.Object) object2.Object {
+			seen++
+			if seen-1 != i {
+				return evaluator.NULL
+			}
+			var output bytes.Buffer
+			ctx.Stdout, ctx.Coverage = &output, coverage
+			start := time.Now()
+			if err, ok := run().(*object2.Error); ok {
+				test.Failure = failure(err)
+			}
+			test.Duration = time.Since(start)
+			ctx.Stdout, ctx.Coverage = io.Discard, nil
+			test.Output = output.String()
+			return evaluator.NULL
+		}
+		evaluator.Eval(program, evaluator.NewEnvironment(ctx))
+		result.Tests = append(result.Tests, test)
+	}
+	return result
+}
+
+// failure is the message of err followed by the functions it went through,
+// the function of the test itself is left out
+func failure(err *object2.Error) string {
+	var out bytes.Buffer
+	out.WriteString(err.Message)
+	stack := err.Stack
+	if len(stack) > 0 {
+		stack = stack[:len(stack)-1]
+	}
+	for _, frame := range stack {
+		out.WriteString("\nat " + frame)
+	}
+	return out.String()
+}
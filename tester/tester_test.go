@@ -0,0 +1,220 @@
+package tester
+
+import (
+	"bytes"
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const src = `let counter = [];
+fn add(a, b) { a + b }
+puts("top level");
+
+test("adds", fn() {
+    assert_eq(add(1, 2), 3);
+});
+test("fresh environment", fn() {
+    let counter = push(counter, 1);
+    assert_eq(len(counter), 1);
+});
+test("fails", fn() {
+    puts("checking");
+    assert_eq(add(1, 1), 3, "one and one");
+});
+test("errors", fn() {
+    fn helper(x) { x + true }
+    let n = helper(1);
+    n
+});
+test("fresh environment again", fn() {
+    assert_eq(counter, []);
+});
+`
+
+func names(tests []*Result) string {
+	list := []string{}
+	for _, test := range tests {
+		list = append(list, test.Name)
+	}
+	return strings.Join(list, ", ")
+}
+
+func TestRun(t *testing.T) {
+	result := Run("math_test.mk", src, nil, nil)
+	if result.Err != nil {
+		t.Fatalf("run failed: %s", result.Err)
+	}
+	if got := names(result.Tests); got != "adds, fresh environment, fails, errors, fresh environment again" {
+		t.Fatalf("tests wrong. got=%s", got)
+	}
+	want := []struct {
+		failure string
+		output  string
+	}{
+		{"", ""},
+		{"", ""},
+		{"assert_eq failed: one and one\n- 3\n+ 2", "checking\n"},
+		{"type mismatch: INTEGER + BOOLEAN\nat helper", ""},
+		{"", ""},
+	}
+	for i, test := range result.Tests {
+		if test.Failure != want[i].failure || test.Output != want[i].output {
+			t.Errorf("%s wrong. got failure=%q output=%q", test.Name, test.Failure, test.Output)
+		}
+	}
+	if result.Failed() != 2 {
+		t.Errorf("failed wrong. got=%d", result.Failed())
+	}
+}
+
+func TestRunFilter(t *testing.T) {
+	result := Run("math_test.mk", src, regexp.MustCompile("^fresh"), nil)
+	if got := names(result.Tests); got != "fresh environment, fresh environment again" {
+		t.Errorf("filtered tests wrong. got=%s", got)
+	}
+}
+
+// the file runs once per test, its top level is counted once all the same
+func TestRunCoverage(t *testing.T) {
+	coverage := evaluator.NewCoverage()
+	result := Run("math_test.mk", src, nil, coverage)
+	if result.Err != nil {
+		t.Fatalf("run failed: %s", result.Err)
+	}
+	for _, statement := range result.Program.Statements {
+		if hits := coverage.Hits(statement); hits != 1 {
+			t.Errorf("%q ran %d times", statement.String(), hits)
+		}
+	}
+	adds := result.Program.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	body := adds.Arguments[1].(*ast.FunctionLiteral).Body.Statements[0]
+	if hits := coverage.Hits(body); hits != 1 {
+		t.Errorf("%q ran %d times", body.String(), hits)
+	}
+}
+
+func TestRunFreshEnvironment(t *testing.T) {
+	src := `let counter = 0;
+test("first", fn() { counter = counter + 1; assert_eq(counter, 1); });
+test("second", fn() { counter = counter + 1; assert_eq(counter, 1); });
+`
+	result := Run("counter_test.mk", src, nil, nil)
+	if result.Err != nil || result.Failed() != 0 {
+		t.Errorf("tests share their environment. err=%v failed=%d", result.Err, result.Failed())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"test(", "expected next token"},
+		{"test(\"a\", fn() { nope })", "identifier not found: nope"},
+		{"let x = 1 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"test(\"a\", 1)", "second argument to `test` must be FUNCTION, got=INTEGER"},
+	}
+	for _, tt := range tests {
+		result := Run("bad_test.mk", tt.src, nil, nil)
+		if result.Err == nil || !strings.Contains(result.Err.Error(), tt.want) {
+			t.Errorf("%q: error wrong. got=%v, want=%s", tt.src, result.Err, tt.want)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b_test.mk", "a_test.mk", "lib.mk", "sub/c_test.mk"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := Discover([]string{dir, filepath.Join(dir, "lib.mk")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a_test.mk", "b_test.mk", "sub/c_test.mk", "lib.mk"}
+	if len(files) != len(want) {
+		t.Fatalf("files wrong. got=%v", files)
+	}
+	for i, file := range files {
+		if rel, _ := filepath.Rel(dir, file); filepath.ToSlash(rel) != want[i] {
+			t.Errorf("files[%d] wrong. got=%s, want=%s", i, rel, want[i])
+		}
+	}
+	if _, err := Discover([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("missing path not reported")
+	}
+}
+
+// results with fixed durations, the reports do not change between runs
+func reportResults() []*FileResult {
+	return []*FileResult{
+		{Path: "a_test.mk", Tests: []*Result{
+			{Name: "passes", Output: "hello\n"},
+			{Name: "fails", Failure: "assert failed\nat helper", Output: "out <1>\n"},
+		}},
+		{Path: "b_test.mk", Err: errString("identifier not found: x")},
+	}
+}
+
+type errString string
+
+func (e errString) Error() string { return string(e) }
+
+func TestReport(t *testing.T) {
+	var out bytes.Buffer
+	Report(&out, reportResults(), false)
+	want := `--- FAIL: fails (0.00s)
+    assert failed
+    at helper
+    out <1>
+FAIL	a_test.mk	1 passed, 1 failed (0.00s)
+FAIL	b_test.mk
+    identifier not found: x
+`
+	if out.String() != want {
+		t.Errorf("report wrong.\ngot=\n%s\nwant=\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	Report(&out, reportResults()[:1], true)
+	if !strings.HasPrefix(out.String(), "--- PASS: passes (0.00s)\n    hello\n--- FAIL: fails") {
+		t.Errorf("verbose report wrong. got=\n%s", out.String())
+	}
+}
+
+func TestJUnit(t *testing.T) {
+	var out bytes.Buffer
+	if err := JUnit(&out, reportResults()); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" errors="1" time="0.000">
+  <testsuite name="a_test.mk" tests="2" failures="1" errors="0" time="0.000">
+    <testcase name="passes" classname="a_test.mk" time="0.000">
+      <system-out>hello&#xA;</system-out>
+    </testcase>
+    <testcase name="fails" classname="a_test.mk" time="0.000">
+      <failure message="assert failed">assert failed&#xA;at helper</failure>
+      <system-out>out &lt;1&gt;&#xA;</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="b_test.mk" tests="1" failures="0" errors="1" time="0.000">
+    <testcase name="b_test.mk" classname="b_test.mk" time="0.000">
+      <error message="identifier not found: x">identifier not found: x</error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if out.String() != want {
+		t.Errorf("junit wrong.\ngot=\n%s\nwant=\n%s", out.String(), want)
+	}
+}
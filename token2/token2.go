@@ -6,8 +6,10 @@ package token2
 type TokenType string
 
 type Token struct {
-	Type    TokenType // token2 type
-	Literal string    // literal notation
+	Type    TokenType `json:"type"`    // token2 type
+	Literal string    `json:"literal"` // literal notation
+	Line    int       `json:"line"`    // line of the first character, from 1
+	Column  int       `json:"column"`  // column of the first character in bytes, from 1
 }
 
 // all token2 type
@@ -15,6 +17,12 @@ const (
 	STRING  = "STRING"  // string type
 	ILLEGAL = "ILLEGAL" // unknown token2
 	EOF     = "EOF"     // the end of file
+	COMMENT = "COMMENT" // // to the end of the line, kept aside by the lexer
+
+	// "a ${x} b ${y} c" is INTERP_START("a ") x INTERP_MID(" b ") y INTERP_END(" c")
+	INTERP_START = "INTERP_START"
+	INTERP_MID   = "INTERP_MID"
+	INTERP_END   = "INTERP_END"
 
 	// identifier + literal
 	IDENT = "IDENT" // add, foobar, x, y, ...
@@ -27,6 +35,16 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
+
+	// bitwise operator
+	BIT_AND = "&"
+	BIT_OR  = "|"
+	BIT_XOR = "^"
+	BIT_NOT = "~"
+	LSHIFT  = "<<"
+	RSHIFT  = ">>"
 
 	LT     = "<"
 	GT     = ">"
@@ -45,6 +63,16 @@ const (
 	LBRACKET = "["
 	RBRACKET = "]"
 	COLON    = ":"
+	// range literal 1..10 and 1..<10
+	DOTDOT   = ".."
+	DOTDOTLT = "..<"
+	// field access p.x
+	DOT = "."
+	// match arms and rest patterns
+	ARROW    = "=>"
+	ELLIPSIS = "..."
+	// the return type of a function, fn(x: int) -> int
+	RARROW = "->"
 	// keyword
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
@@ -53,6 +81,11 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	MATCH    = "MATCH"
+	CONST    = "CONST"
+	STRUCT   = "STRUCT"
+	IMPL     = "IMPL"
+	ENUM     = "ENUM"
 )
 
 var keywords = map[string]TokenType{
@@ -63,6 +96,11 @@ var keywords = map[string]TokenType{
 	"else":   ELSE,
 	"if":     IF,
 	"return": RETURN,
+	"match":  MATCH,
+	"const":  CONST,
+	"struct": STRUCT,
+	"impl":   IMPL,
+	"enum":   ENUM,
 }
 
 // find function mapping in keyword
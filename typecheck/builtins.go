@@ -0,0 +1,87 @@
+package typecheck
+
+import (
+	"interpreter/ast"
+	"interpreter/object2"
+)
+
+// builtinResult checks a call of a builtin function, given the types of its
+// arguments, and returns the type of its result
+type builtinResult func(c *checker, call *ast.CallExpression, args []*Type) *Type
+
+// the builtins of the evaluator and what their calls give
+var builtins = map[string]builtinResult{
+	"len": func(c *checker, call *ast.CallExpression, args []*Type) *Type {
+		if len(args) > 0 {
+			switch args[0].Kind {
+			case ANY, object2.STRING_OBJ, object2.ARRAY_OBJ, object2.RANGE_OBJ:
+			default:
+				c.report(startToken(call.Arguments[0]), "argument to `len` not supported, got=%s", args[0].Kind)
+			}
+		}
+		return integerType
+	},
+	"first": elementOf("first"),
+	"last":  elementOf("last"),
+	"rest": func(c *checker, call *ast.CallExpression, args []*Type) *Type {
+		return c.argument(call, args, "rest", object2.ARRAY_OBJ)
+	},
+	"push": func(c *checker, call *ast.CallExpression, args []*Type) *Type {
+		array := c.argument(call, args, "push", object2.ARRAY_OBJ)
+		if array.Kind == object2.ARRAY_OBJ && len(args) > 1 {
+			return arrayOf(join(array.Element, args[1]))
+		}
+		return array
+	},
+	"put": func(c *checker, call *ast.CallExpression, args []*Type) *Type {
+		hash := c.argument(call, args, "put", object2.HASH_OBJ)
+		if hash.Kind == object2.HASH_OBJ && len(args) > 2 {
+			return hashOf(join(hash.Key, args[1]), join(hash.Element, args[2]))
+		}
+		return hash
+	},
+	"delete": func(c *checker, call *ast.CallExpression, args []*Type) *Type {
+		return c.argument(call, args, "delete", object2.HASH_OBJ)
+	},
+	"type":         returns(stringType),
+	"to_string":    returns(stringType),
+	"puts":         returns(nullType),
+	"test":         returns(nullType),
+	"assert":       returns(nullType),
+	"assert_eq":    returns(nullType),
+	"assert_error": returns(nullType),
+}
+
+func returns(t *Type) builtinResult {
+	return func(c *checker, call *ast.CallExpression, args []*Type) *Type {
+		return t
+	}
+}
+
+// elementOf is first and last, which give an element of their array
+func elementOf(name string) builtinResult {
+	return func(c *checker, call *ast.CallExpression, args []*Type) *Type {
+		if array := c.argument(call, args, name, object2.ARRAY_OBJ); array.Kind == object2.ARRAY_OBJ {
+			return array.Element
+		}
+		return anyType
+	}
+}
+
+// argument reports the first argument of a builtin call when it is not of
+// kind, it returns its type or ANY when it is wrong
+func (c *checker) argument(call *ast.CallExpression, args []*Type, name string, kind object2.ObjectType) *Type {
+	if len(args) == 0 {
+		return anyType
+	}
+	if args[0].Kind != ANY && args[0].Kind != kind {
+		c.report(startToken(call.Arguments[0]), "argument to `%s` must be %s, got=%s", name, kind, args[0].Kind)
+		return anyType
+	}
+	return args[0]
+}
+
+// builtinType is the type of the builtin function called name
+func builtinType(name string) *Type {
+	return &Type{Kind: object2.FUNCTION_OBJ, Name: name}
+}
@@ -0,0 +1,545 @@
+package typecheck
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/object2"
+	"interpreter/token2"
+)
+
+// checker infers the types of one resolved program
+type checker struct {
+	// the declaration of every identifier, a declaration maps to itself
+	definitions map[*ast.Identifier]*ast.Identifier
+	// what is known of the value of a declaration
+	types map[*ast.Identifier]*Type
+	// the annotation of a declaration, assignments must respect it
+	declared map[*ast.Identifier]*Type
+	// declarations that are the target of an assignment
+	assigned map[*ast.Identifier]bool
+	// the structs and enums of the program, by name
+	named map[string]*Type
+	// the function being checked, innermost last
+	functions []*frame
+
+	diagnostics []Diagnostic
+}
+
+// frame is a function being checked
+type frame struct {
+	declared *Type // the annotated return type, nil without one
+	returned *Type // the join of the values returned so far
+}
+
+func newChecker(program *ast.Program, definitions map[*ast.Identifier]*ast.Identifier) *checker {
+	c := &checker{
+		definitions: definitions,
+		types:       make(map[*ast.Identifier]*Type),
+		declared:    make(map[*ast.Identifier]*Type),
+		assigned:    make(map[*ast.Identifier]bool),
+		named:       make(map[string]*Type),
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.AssignExpression:
+			if target, ok := node.Target.(*ast.Identifier); ok {
+				c.assigned[definitions[target]] = true
+			}
+		case *ast.StructStatement:
+			c.named[node.Name.Value] = &Type{Kind: object2.STRUCT_OBJ, Name: node.Name.Value}
+			c.types[node.Name] = &Type{Kind: object2.STRUCT_TYPE_OBJ, Name: node.Name.Value}
+		case *ast.EnumStatement:
+			value := &Type{Kind: object2.ENUM_OBJ, Name: node.Name.Value}
+			c.named[node.Name.Value] = value
+			for _, variant := range node.Variants {
+				if len(variant.Fields) == 0 {
+					c.types[variant.Name] = value
+				} else {
+					c.types[variant.Name] = &Type{Kind: object2.VARIANT_OBJ, Name: node.Name.Value}
+				}
+			}
+		}
+		return true
+	})
+	return c
+}
+
+func (c *checker) report(token token2.Token, format string, a ...interface{}) {
+	c.diagnostics = append(c.diagnostics, Diagnostic{
+		Line:    token.Line,
+		Column:  token.Column,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// expect reports got where want is expected and got does not fit
+func (c *checker) expect(at ast.Expression, got, want *Type, context string, a ...interface{}) {
+	if !assignable(got, want) {
+		c.report(startToken(at), "cannot use %s as %s in %s", got, want, fmt.Sprintf(context, a...))
+	}
+}
+
+// resolve returns the type an annotation stands for, nil gives ANY
+func (c *checker) resolve(t ast.Type) *Type {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		switch t.Name {
+		case "int":
+			return integerType
+		case "string":
+			return stringType
+		case "bool":
+			return booleanType
+		case "null":
+			return nullType
+		case "any":
+			return anyType
+		}
+		if named, ok := c.named[t.Name]; ok {
+			return named
+		}
+		c.report(t.Token, "unknown type: %s", t.Name)
+	case *ast.ArrayType:
+		return arrayOf(c.resolve(t.Element))
+	case *ast.HashType:
+		return hashOf(c.resolve(t.Key), c.resolve(t.Value))
+	case *ast.FunctionType:
+		fn := &Type{Kind: object2.FUNCTION_OBJ, Parameters: []*Type{}, Return: c.resolve(t.Return)}
+		for _, param := range t.Parameters {
+			fn.Parameters = append(fn.Parameters, c.resolve(param))
+		}
+		return fn
+	}
+	return anyType
+}
+
+// bind records what is known of the value of a declaration: its annotation
+// when it has one, else the value it is bound to unless it is assigned later
+func (c *checker) bind(ident *ast.Identifier, annotation *Type, value *Type) {
+	switch {
+	case annotation != nil:
+		c.declared[ident] = annotation
+		c.types[ident] = annotation
+	case c.assigned[ident]:
+		c.types[ident] = anyType
+	default:
+		c.types[ident] = value
+	}
+}
+
+// statements checks a block and returns the type of its value, the value of
+// its last expression statement
+func (c *checker) statements(statements []ast.Statement) *Type {
+	for _, statement := range statements {
+		if declaration, ok := statement.(*ast.FunctionDeclaration); ok {
+			c.types[declaration.Name] = c.signature(declaration.Function)
+		}
+	}
+	result := nullType
+	for _, statement := range statements {
+		result = c.statement(statement)
+	}
+	return result
+}
+
+func (c *checker) statement(statement ast.Statement) *Type {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		value := c.expression(statement.Value)
+		if statement.Name == nil {
+			return anyType
+		}
+		var annotation *Type
+		if statement.Type != nil {
+			annotation = c.resolve(statement.Type)
+			c.expect(statement.Value, value, annotation, "let %s", statement.Name.Value)
+		}
+		c.bind(statement.Name, annotation, value)
+		return anyType
+	case *ast.ReturnStatement:
+		value := c.expression(statement.ReturnValue)
+		if len(c.functions) > 0 {
+			fn := c.functions[len(c.functions)-1]
+			if fn.declared != nil && !assignable(value, fn.declared) {
+				c.report(statement.Token, "cannot use %s as %s in return", value, fn.declared)
+			}
+			fn.returned = join(fn.returned, value)
+		}
+		return anyType
+	case *ast.ExpressionStatement:
+		return c.expression(statement.Expression)
+	case *ast.BlockStatement:
+		return c.statements(statement.Statements)
+	case *ast.FunctionDeclaration:
+		signature := c.types[statement.Name]
+		if signature == nil {
+			signature = c.signature(statement.Function)
+		}
+		c.types[statement.Name] = c.function(statement.Function, signature)
+		return anyType
+	case *ast.ImplStatement:
+		for _, method := range statement.Methods {
+			c.function(method.Function, c.signature(method.Function))
+		}
+	}
+	return anyType
+}
+
+// signature returns the type of fn as its annotations give it
+func (c *checker) signature(fn *ast.FunctionLiteral) *Type {
+	t := &Type{Kind: object2.FUNCTION_OBJ, Parameters: []*Type{}, Return: anyType}
+	for i, param := range fn.Parameters {
+		var annotation ast.Type
+		if i < len(fn.ParameterTypes) {
+			annotation = fn.ParameterTypes[i]
+		}
+		t.Parameters = append(t.Parameters, c.resolve(annotation))
+		t.Names = append(t.Names, param.Value)
+	}
+	if fn.ReturnType != nil {
+		t.Return = c.resolve(fn.ReturnType)
+	}
+	return t
+}
+
+// function checks the body of fn, whose annotations gave signature, and
+// returns its type with the result inferred when it is not annotated
+func (c *checker) function(fn *ast.FunctionLiteral, signature *Type) *Type {
+	for i, param := range fn.Parameters {
+		var annotation *Type
+		if i < len(fn.ParameterTypes) && fn.ParameterTypes[i] != nil {
+			annotation = signature.Parameters[i]
+		}
+		if i < len(fn.Defaults) && fn.Defaults[i] != nil {
+			value := c.expression(fn.Defaults[i])
+			if annotation != nil {
+				c.expect(fn.Defaults[i], value, annotation, "default of %s", param.Value)
+			}
+		}
+		c.bind(param, annotation, signature.Parameters[i])
+	}
+	if fn.Rest != nil {
+		c.bind(fn.Rest, nil, arrayOf(anyType))
+	}
+
+	current := &frame{}
+	if fn.ReturnType != nil {
+		current.declared = signature.Return
+	}
+	c.functions = append(c.functions, current)
+	result := c.statements(fn.Body.Statements)
+	c.functions = c.functions[:len(c.functions)-1]
+
+	last := lastExpression(fn.Body)
+	if last == nil {
+		// the body ends with a return or a declaration
+		result = current.returned
+		if result == nil {
+			result = anyType
+		}
+	} else {
+		if current.declared != nil {
+			c.expect(last, result, current.declared, "return")
+		}
+		result = join(current.returned, result)
+	}
+	t := *signature
+	if current.declared == nil {
+		t.Return = result
+	}
+	return &t
+}
+
+// lastExpression returns the expression giving the value of block, nil when
+// the block does not end with one
+func lastExpression(block *ast.BlockStatement) ast.Expression {
+	if len(block.Statements) == 0 {
+		return nil
+	}
+	if statement, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement); ok {
+		return statement.Expression
+	}
+	return nil
+}
+
+func (c *checker) expression(expression ast.Expression) *Type {
+	switch e := expression.(type) {
+	case nil:
+		return nullType
+	case *ast.IntegerLiteral:
+		return integerType
+	case *ast.StringLiteral:
+		return stringType
+	case *ast.InterpolatedString:
+		for _, part := range e.Parts {
+			c.expression(part)
+		}
+		return stringType
+	case *ast.Boolean:
+		return booleanType
+	case *ast.Identifier:
+		if def, ok := c.definitions[e]; ok {
+			if t, ok := c.types[def]; ok {
+				return t
+			}
+			return anyType
+		}
+		if _, ok := builtins[e.Value]; ok {
+			return builtinType(e.Value)
+		}
+		return anyType
+	case *ast.PrefixExpression:
+		return c.prefix(e)
+	case *ast.InfixExpression:
+		return c.infix(e)
+	case *ast.IfExpression:
+		c.expression(e.Condition)
+		result := c.statements(e.Consequence.Statements)
+		if e.Alternative == nil {
+			return join(result, nullType)
+		}
+		return join(result, c.statements(e.Alternative.Statements))
+	case *ast.FunctionLiteral:
+		return c.function(e, c.signature(e))
+	case *ast.CallExpression:
+		return c.call(e)
+	case *ast.ArrayLiteral:
+		var element *Type
+		for _, el := range e.Elements {
+			t := c.expression(el)
+			if _, ok := el.(*ast.SpreadExpression); ok {
+				t = anyType
+			}
+			element = join(element, t)
+		}
+		if element == nil {
+			element = anyType
+		}
+		return arrayOf(element)
+	case *ast.HashLiteral:
+		var key, value *Type
+		for _, k := range e.Keys {
+			key = join(key, c.expression(k))
+			value = join(value, c.expression(e.Pairs[k]))
+		}
+		if key == nil {
+			key, value = anyType, anyType
+		}
+		return hashOf(key, value)
+	case *ast.IndexExpression:
+		return c.index(e)
+	case *ast.KeywordArgument:
+		return c.expression(e.Value)
+	case *ast.AssignExpression:
+		value := c.expression(e.Value)
+		if target, ok := e.Target.(*ast.Identifier); ok {
+			if want, ok := c.declared[c.definitions[target]]; ok {
+				c.expect(e.Value, value, want, "assignment to %s", target.Value)
+			}
+			return value
+		}
+		c.expression(e.Target)
+		return value
+	}
+	c.children(expression)
+	return anyType
+}
+
+// children checks the parts of a node the checker knows nothing about
+func (c *checker) children(node ast.Node) {
+	ast.Inspect(node, func(child ast.Node) bool {
+		if child == node {
+			return true
+		}
+		switch child := child.(type) {
+		case ast.Statement:
+			c.statement(child)
+			return false
+		case ast.Expression:
+			c.expression(child)
+			return false
+		}
+		return true
+	})
+}
+
+func (c *checker) prefix(e *ast.PrefixExpression) *Type {
+	right := c.expression(e.Right)
+	if e.Operator == "!" {
+		return booleanType
+	}
+	if right.Kind != ANY && right.Kind != object2.INTEGER_OBJ {
+		c.report(e.Token, "unknown operator: %s%s", e.Operator, right.Kind)
+		return anyType
+	}
+	return integerType
+}
+
+// the operators of integers that give a boolean
+var comparisons = map[string]bool{"<": true, ">": true, "==": true, "!=": true}
+
+// infix follows the rules of evalInfixExpression, an error is reported only
+// when both operands are known
+func (c *checker) infix(e *ast.InfixExpression) *Type {
+	left, right := c.expression(e.Left), c.expression(e.Right)
+	op := e.Operator
+	switch {
+	case left.Kind == ANY || right.Kind == ANY:
+		known := left
+		if known.Kind == ANY {
+			known = right
+		}
+		switch {
+		case comparisons[op]:
+			return booleanType
+		case known.Kind == object2.INTEGER_OBJ:
+			return integerType
+		case known.Kind == object2.STRING_OBJ && op == "+":
+			return stringType
+		}
+		return anyType
+	case left.Kind == object2.STRING_OBJ && right.Kind == object2.STRING_OBJ:
+		if op == "+" {
+			return stringType
+		}
+	case left.Kind == object2.INTEGER_OBJ && right.Kind == object2.INTEGER_OBJ:
+		if comparisons[op] {
+			return booleanType
+		}
+		return integerType
+	case op == "==" || op == "!=":
+		return booleanType
+	case left.Kind != right.Kind:
+		c.report(e.Token, "type mismatch: %s %s %s", left.Kind, op, right.Kind)
+		return anyType
+	}
+	c.report(e.Token, "unknown operator: %s %s %s", left.Kind, op, right.Kind)
+	return anyType
+}
+
+func (c *checker) index(e *ast.IndexExpression) *Type {
+	left, index := c.expression(e.Left), c.expression(e.Index)
+	switch left.Kind {
+	case ANY:
+		return anyType
+	case object2.HASH_OBJ:
+		return left.Element
+	case object2.ARRAY_OBJ, object2.STRING_OBJ, object2.RANGE_OBJ:
+		if index.Kind != ANY && index.Kind != object2.INTEGER_OBJ {
+			break
+		}
+		switch left.Kind {
+		case object2.ARRAY_OBJ:
+			return left.Element
+		case object2.STRING_OBJ:
+			return stringType
+		}
+		return integerType
+	}
+	c.report(e.Token, "index operator not supported: %s", left.Kind)
+	return anyType
+}
+
+func (c *checker) call(e *ast.CallExpression) *Type {
+	fn := c.expression(e.Function)
+	args := []*Type{}
+	for _, argument := range e.Arguments {
+		args = append(args, c.expression(argument))
+	}
+	name := "function"
+	if ident, ok := e.Function.(*ast.Identifier); ok {
+		name = ident.Value
+	}
+
+	switch fn.Kind {
+	case ANY:
+		return anyType
+	case object2.STRUCT_TYPE_OBJ:
+		return &Type{Kind: object2.STRUCT_OBJ, Name: fn.Name}
+	case object2.VARIANT_OBJ:
+		return &Type{Kind: object2.ENUM_OBJ, Name: fn.Name}
+	case object2.FUNCTION_OBJ:
+		if result, ok := builtins[fn.Name]; ok {
+			return result(c, e, args)
+		}
+	default:
+		c.report(e.Token, "not a function: %s", fn.Kind)
+		return anyType
+	}
+	if fn.Parameters == nil {
+		return anyType
+	}
+	position := 0
+	for i, argument := range e.Arguments {
+		switch argument := argument.(type) {
+		case *ast.SpreadExpression:
+			// the positions that follow are unknown
+			position = len(fn.Parameters)
+		case *ast.KeywordArgument:
+			for j, param := range fn.Names {
+				if param == argument.Name {
+					c.expect(argument.Value, args[i], fn.Parameters[j], "argument %s of %s", param, name)
+				}
+			}
+		default:
+			if position < len(fn.Parameters) {
+				// a function type of an annotation has no parameter names
+				param := fmt.Sprint(position + 1)
+				if position < len(fn.Names) {
+					param = fn.Names[position]
+				}
+				c.expect(argument, args[i], fn.Parameters[position], "argument %s of %s", param, name)
+			}
+			position++
+		}
+	}
+	return fn.Return
+}
+
+// startToken returns the first token of expression, where a report about
+// it points
+func startToken(expression ast.Expression) token2.Token {
+	switch e := expression.(type) {
+	case *ast.InfixExpression:
+		return startToken(e.Left)
+	case *ast.CallExpression:
+		return startToken(e.Function)
+	case *ast.IndexExpression:
+		return startToken(e.Left)
+	case *ast.SliceExpression:
+		return startToken(e.Left)
+	case *ast.DotExpression:
+		return startToken(e.Left)
+	case *ast.AssignExpression:
+		return startToken(e.Target)
+	case *ast.RangeExpression:
+		return startToken(e.Start)
+	case *ast.Identifier:
+		return e.Token
+	case *ast.IntegerLiteral:
+		return e.Token
+	case *ast.StringLiteral:
+		return e.Token
+	case *ast.InterpolatedString:
+		return e.Token
+	case *ast.Boolean:
+		return e.Token
+	case *ast.PrefixExpression:
+		return e.Token
+	case *ast.IfExpression:
+		return e.Token
+	case *ast.FunctionLiteral:
+		return e.Token
+	case *ast.ArrayLiteral:
+		return e.Token
+	case *ast.HashLiteral:
+		return e.Token
+	case *ast.SpreadExpression:
+		return e.Token
+	case *ast.KeywordArgument:
+		return e.Token
+	case *ast.MatchExpression:
+		return e.Token
+	}
+	return token2.Token{}
+}
@@ -0,0 +1,82 @@
+/*
+	Check the types of a Monkey program without running it.
+		- annotations are optional: let x: int = 1; fn(a: [string]) -> bool.
+		  The types are int, string, bool, null, any, [T], {K: V},
+		  fn(T, ...) -> R and the names of the structs and enums declared
+		- the types of unannotated bindings and results are inferred from
+		  the values they are given, locally: a binding that is assigned
+		  somewhere and everything the checker cannot follow is ANY, which
+		  fits everywhere
+		- a mismatch is reported where the wrong value starts, with the
+		  messages the evaluator would give when it runs into it
+*/
+package typecheck
+
+import (
+	"errors"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"interpreter/resolver"
+	"sort"
+	"strings"
+)
+
+// Diagnostic is one type error or resolver warning found in a file
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// Check returns the type errors of program in source order. definitions
+// are those of the resolver that resolved it.
+func Check(program *ast.Program, definitions map[*ast.Identifier]*ast.Identifier) []Diagnostic {
+	c := newChecker(program, definitions)
+	c.statements(program.Statements)
+	return sorted(c.diagnostics)
+}
+
+func sorted(diagnostics []Diagnostic) []Diagnostic {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diagnostics
+}
+
+// Source checks src, read from file. Parser and resolver errors are returned
+// as an error, nothing is checked then. The warnings of the resolver come
+// with the type errors.
+func Source(file string, src string) ([]Diagnostic, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+	r := resolver.New(evaluator.NewGlobalScope())
+	r.Resolve(program)
+	if len(r.Errors()) > 0 {
+		return nil, errors.New(strings.Join(r.Errors(), "\n"))
+	}
+	diagnostics := []Diagnostic{}
+	for i, msg := range r.Warnings() {
+		token := r.WarningTokens()[i]
+		diagnostics = append(diagnostics, Diagnostic{Line: token.Line, Column: token.Column, Message: "warning: " + msg})
+	}
+	diagnostics = append(diagnostics, Check(program, r.Definitions())...)
+	for i := range diagnostics {
+		diagnostics[i].File = file
+	}
+	return sorted(diagnostics), nil
+}
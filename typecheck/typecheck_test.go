@@ -0,0 +1,125 @@
+package typecheck
+
+import (
+	"strings"
+	"testing"
+)
+
+func check(t *testing.T, src string) []string {
+	diagnostics, err := Source("a.mk", src)
+	if err != nil {
+		t.Fatalf("%q: %s", src, err)
+	}
+	got := []string{}
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+	return got
+}
+
+func TestMismatches(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		// the elements of a literal that differ join to ANY
+		{`let x: int = "a"; let y: [string] = [1, 2]; let z: {string: int} = {"a": 1}; let w: [string] = ["a", 1];`,
+			[]string{"a.mk:1:14: cannot use STRING as INTEGER in let x", "a.mk:1:37: cannot use [INTEGER] as [STRING] in let y"}},
+		{"let n: int = 1; n = true; let m = 1; m = true;",
+			[]string{"a.mk:1:21: cannot use BOOLEAN as INTEGER in assignment to n"}},
+		{`fn add(a: int, b: int = "0") -> int { a + b } add(1, "2"); add(b: true, a: 1); add(...[1, "2"]);`,
+			[]string{
+				"a.mk:1:25: cannot use STRING as INTEGER in default of b",
+				"a.mk:1:54: cannot use STRING as INTEGER in argument b of add",
+				"a.mk:1:67: cannot use BOOLEAN as INTEGER in argument b of add",
+			}},
+		{"let f = fn(xs: [string]) -> bool { len(xs) }; fn g(n) -> string { if (n) { return 1; } \"ok\" }",
+			[]string{"a.mk:1:36: cannot use INTEGER as BOOLEAN in return", "a.mk:1:76: cannot use INTEGER as STRING in return"}},
+		// the result of a function without annotation is inferred
+		{"let f = fn(x) { [x + 1] }; let s: string = f(1); let g = fn() { if (true) { return 1; } 2 }; let h: bool = g();",
+			[]string{"a.mk:1:44: cannot use [INTEGER] as STRING in let s", "a.mk:1:108: cannot use INTEGER as BOOLEAN in let h"}},
+		{`1 + "x"; "a" - "b"; true < false; -"s"; ~[1]; !"s"; 1 == "x";`,
+			[]string{
+				"a.mk:1:3: type mismatch: INTEGER + STRING",
+				"a.mk:1:14: unknown operator: STRING - STRING",
+				"a.mk:1:26: unknown operator: BOOLEAN < BOOLEAN",
+				"a.mk:1:35: unknown operator: -STRING",
+				"a.mk:1:41: unknown operator: ~ARRAY",
+			}},
+		{`5(1); let h = {"a": 1}; let s: string = h["a"]; true[0]; [1]["a"]; let c: int = "abc"[0];`,
+			[]string{
+				"a.mk:1:2: not a function: INTEGER",
+				"a.mk:1:41: cannot use INTEGER as STRING in let s",
+				"a.mk:1:53: index operator not supported: BOOLEAN",
+				"a.mk:1:61: index operator not supported: ARRAY",
+				"a.mk:1:81: cannot use STRING as INTEGER in let c",
+			}},
+		{`len(1); first("x"); let n: string = len([1]); let e: string = last([1, 2]); let k: fn(int) -> int = len; let p: {string: int} = put({"a": 1}, "b", "c");`,
+			[]string{
+				"a.mk:1:5: argument to `len` not supported, got=INTEGER",
+				"a.mk:1:15: argument to `first` must be ARRAY, got=STRING",
+				"a.mk:1:37: cannot use INTEGER as STRING in let n",
+				"a.mk:1:63: cannot use INTEGER as STRING in let e",
+			}},
+		{"let apply = fn(f: fn(int) -> int, x: int) -> int { f(x) }; apply(fn(s: string) { s }, 1); apply(fn(n: int) -> int { n }, 1); apply(len, \"x\");",
+			[]string{"a.mk:1:66: cannot use fn(STRING) -> STRING as fn(INTEGER) -> INTEGER in argument f of apply", "a.mk:1:137: cannot use STRING as INTEGER in argument x of apply"}},
+		{"struct P { x, y } enum O { Some(v), None } let p: P = P(1, 2); let q: int = p; let o: O = None; let s: O = Some(1); let t: P = Some(1); let u: Nope = 1;",
+			[]string{"a.mk:1:77: cannot use P as INTEGER in let q", "a.mk:1:128: cannot use O as P in let t", "a.mk:1:144: unknown type: Nope"}},
+	}
+	for _, tt := range tests {
+		got := check(t, tt.src)
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%q wrong.\ngot=\n%s\nwant=\n%s", tt.src, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+// what the checker cannot follow is ANY, correct programs give no error
+func TestNoFalsePositives(t *testing.T) {
+	src := `fn fib(n: int) -> int { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }
+let counter = fn() { let c = 0; fn() { c = c + 1; c } };
+let next = counter();
+let a: int = next() + 1;
+let w = 1; w = "s"; let v: string = w;
+struct Point { x, y }
+impl Point { fn norm(self) -> int { self.x * self.x + self.y * self.y } }
+let d: int = Point(1, 2).norm();
+let r: int = match (a) { 1 => 2, _ => 0 };
+let map = fn(xs: [int], f: fn(int) -> int) -> [int] {
+  let loop = fn(i, acc) { if (i == len(xs)) { acc } else { loop(i + 1, push(acc, f(xs[i]))) } };
+  loop(0, [])
+};
+let doubled: [int] = map([1, 2], fn(x: int) -> int { x * 2 });
+let empty: {string: [int]} = {};
+let third: int = (1..10)[2];
+let s: string = "${third}" + type(1);
+let loose: any = "x";
+let n: int = loose;
+test("fib", fn() { assert_eq(fib(3), 2) });
+`
+	if got := check(t, src); len(got) != 0 {
+		t.Errorf("unexpected errors:\n%s", strings.Join(got, "\n"))
+	}
+	if _, err := Source("a.mk", "let x: = 1;"); err == nil || err.Error() != "expected type, got = instead" {
+		t.Errorf("parser error wrong. got=%v", err)
+	}
+}
+
+func TestResolverWarnings(t *testing.T) {
+	src := "enum E { A, B }\nlet n: int = match (A) { A => 1 };\nlet s: string = 1;"
+	diagnostics, err := Source("a.mk", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+	expected := []string{
+		"a.mk:2:14: warning: non-exhaustive match over E: missing B",
+		"a.mk:3:17: cannot use INTEGER as STRING in let s",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("diagnostics wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
@@ -0,0 +1,127 @@
+package typecheck
+
+import (
+	"interpreter/object2"
+	"strings"
+)
+
+// ANY is the kind of a value the checker knows nothing about, it is
+// compatible with every type
+const ANY object2.ObjectType = "ANY"
+
+// Type is what the checker knows about the values of an expression. Kind is
+// the object type of the values, the other fields refine it and are nil
+// when unknown.
+type Type struct {
+	Kind       object2.ObjectType
+	Element    *Type   // of an ARRAY, the value of a HASH
+	Key        *Type   // of a HASH
+	Parameters []*Type // of a FUNCTION, nil when unknown
+	Names      []string
+	Return     *Type  // of a FUNCTION
+	Name       string // of a STRUCT or an ENUM, the builtin of a FUNCTION
+}
+
+var (
+	anyType     = &Type{Kind: ANY}
+	integerType = &Type{Kind: object2.INTEGER_OBJ}
+	stringType  = &Type{Kind: object2.STRING_OBJ}
+	booleanType = &Type{Kind: object2.BOOLEAN_OBJ}
+	nullType    = &Type{Kind: object2.NULL_OBJ}
+)
+
+func arrayOf(element *Type) *Type {
+	return &Type{Kind: object2.ARRAY_OBJ, Element: element}
+}
+
+func hashOf(key, value *Type) *Type {
+	return &Type{Kind: object2.HASH_OBJ, Key: key, Element: value}
+}
+
+// String renders t with the object type names the evaluator reports,
+// [INTEGER] is an array of integers and {STRING: BOOLEAN} a hash
+func (t *Type) String() string {
+	switch t.Kind {
+	case object2.ARRAY_OBJ:
+		if t.Element.Kind == ANY {
+			return "ARRAY"
+		}
+		return "[" + t.Element.String() + "]"
+	case object2.HASH_OBJ:
+		if t.Key.Kind == ANY && t.Element.Kind == ANY {
+			return "HASH"
+		}
+		return "{" + t.Key.String() + ": " + t.Element.String() + "}"
+	case object2.FUNCTION_OBJ:
+		if t.Parameters == nil {
+			return "FUNCTION"
+		}
+		params := []string{}
+		for _, param := range t.Parameters {
+			params = append(params, param.String())
+		}
+		return "fn(" + strings.Join(params, ", ") + ") -> " + t.Return.String()
+	case object2.STRUCT_OBJ, object2.ENUM_OBJ:
+		if t.Name != "" {
+			return t.Name
+		}
+	}
+	return string(t.Kind)
+}
+
+// assignable reports whether a value of type from may be used where to is
+// expected. ANY fits everywhere, parameters are compared the other way
+// round.
+func assignable(from, to *Type) bool {
+	if from.Kind == ANY || to.Kind == ANY {
+		return true
+	}
+	if from.Kind != to.Kind {
+		return false
+	}
+	switch from.Kind {
+	case object2.ARRAY_OBJ:
+		return assignable(from.Element, to.Element)
+	case object2.HASH_OBJ:
+		return assignable(from.Key, to.Key) && assignable(from.Element, to.Element)
+	case object2.FUNCTION_OBJ:
+		if from.Parameters == nil || to.Parameters == nil {
+			return true
+		}
+		for i := 0; i < len(from.Parameters) && i < len(to.Parameters); i++ {
+			if !assignable(to.Parameters[i], from.Parameters[i]) {
+				return false
+			}
+		}
+		return assignable(from.Return, to.Return)
+	case object2.STRUCT_OBJ, object2.ENUM_OBJ:
+		return from.Name == "" || to.Name == "" || from.Name == to.Name
+	}
+	return true
+}
+
+// join returns a type for values that are of type a or of type b, a is nil
+// when there is nothing to join yet
+func join(a, b *Type) *Type {
+	if a == nil {
+		return b
+	}
+	if a.Kind != b.Kind || a.Kind == ANY {
+		return anyType
+	}
+	switch a.Kind {
+	case object2.ARRAY_OBJ:
+		return arrayOf(join(a.Element, b.Element))
+	case object2.HASH_OBJ:
+		return hashOf(join(a.Key, b.Key), join(a.Element, b.Element))
+	case object2.FUNCTION_OBJ:
+		if a.String() != b.String() {
+			return &Type{Kind: object2.FUNCTION_OBJ}
+		}
+	case object2.STRUCT_OBJ, object2.ENUM_OBJ:
+		if a.Name != b.Name {
+			return &Type{Kind: a.Kind}
+		}
+	}
+	return a
+}